@@ -31,17 +31,18 @@ type Config struct {
 	DeviceID string `koanf:"device_id" json:"device_id" yaml:"device_id"`
 
 	// Convenience keys:
-	// If Controller is set, only that controller is enabled (http|mqtt|modbus).
+	// If Controller is set, only that controller is enabled (http|mqtt|modbus|bacnet|knx|haystack).
 	// If Addr is set alongside Controller, it is copied to the chosen controller's addr.
 	Controller string `koanf:"controller" json:"controller" yaml:"controller"`
 	Addr       string `koanf:"addr" json:"addr" yaml:"addr"`
 
 	Controllers struct {
-		HTTP   HTTPConfig   `koanf:"http" json:"http" yaml:"http"`
-		MQTT   MQTTConfig   `koanf:"mqtt" json:"mqtt" yaml:"mqtt"`
-		MODBUS Modbusconfig `koanf:"modbus" json:"modbus" yaml:"modbus"`
-		BACNET BacnetConfig `koanf:"bacnet" json:"bacnet" yaml:"bacnet"`
-		KNX    KNXConfig    `koanf:"knx" json:"knx" yaml:"knx"`
+		HTTP     HTTPConfig     `koanf:"http" json:"http" yaml:"http"`
+		MQTT     MQTTConfig     `koanf:"mqtt" json:"mqtt" yaml:"mqtt"`
+		MODBUS   Modbusconfig   `koanf:"modbus" json:"modbus" yaml:"modbus"`
+		BACNET   BacnetConfig   `koanf:"bacnet" json:"bacnet" yaml:"bacnet"`
+		KNX      KNXConfig      `koanf:"knx" json:"knx" yaml:"knx"`
+		Haystack HaystackConfig `koanf:"haystack" json:"haystack" yaml:"haystack"`
 	} `koanf:"controllers" json:"controllers" yaml:"controllers"`
 
 	Thermostat ThermostatConfig      `koanf:"thermostat" json:"thermostat" yaml:"thermostat"`
@@ -80,11 +81,20 @@ type HeatLossConfig struct {
 }
 
 type WeatherProviderConfig struct {
-	Type            string        `koanf:"type" json:"type" yaml:"type"` // static | open-meteo
+	Type            string        `koanf:"type" json:"type" yaml:"type"` // static | file | open-meteo | mqtt
 	RefreshInterval time.Duration `koanf:"refresh_interval" json:"refresh_interval" yaml:"refresh_interval"`
 
+	// Chain lists provider types in priority order (e.g. open-meteo, file,
+	// static); when set it replaces Type with a fallback chain.
+	Chain []string `koanf:"chain" json:"chain" yaml:"chain"`
+	// FailureThreshold is the number of consecutive failures before the chain
+	// moves to the next provider.
+	FailureThreshold int `koanf:"failure_threshold" json:"failure_threshold" yaml:"failure_threshold"`
+
 	Static    StaticWeatherConfig    `koanf:"static" json:"static" yaml:"static"`
+	File      FileWeatherConfig      `koanf:"file" json:"file" yaml:"file"`
 	OpenMeteo OpenMeteoWeatherConfig `koanf:"open_meteo" json:"open_meteo" yaml:"open_meteo"`
+	MQTT      MQTTWeatherConfig      `koanf:"mqtt" json:"mqtt" yaml:"mqtt"`
 }
 
 type StaticWeatherConfig struct {
@@ -92,27 +102,130 @@ type StaticWeatherConfig struct {
 	OutdoorTemperature *float64 `koanf:"outdoor_temperature" json:"outdoor_temperature" yaml:"outdoor_temperature"`
 }
 
+type FileWeatherConfig struct {
+	Path string `koanf:"path" json:"path" yaml:"path"` // file holding a single number
+}
+
 type OpenMeteoWeatherConfig struct {
-	Latitude  float64 `koanf:"latitude" json:"latitude" yaml:"latitude"`
-	Longitude float64 `koanf:"longitude" json:"longitude" yaml:"longitude"`
+	Latitude  float64       `koanf:"latitude" json:"latitude" yaml:"latitude"`
+	Longitude float64       `koanf:"longitude" json:"longitude" yaml:"longitude"`
+	MaxStale  time.Duration `koanf:"max_stale" json:"max_stale" yaml:"max_stale"` // 0 keeps serving the last value forever
+}
+
+type MQTTWeatherConfig struct {
+	Addr     string        `koanf:"addr" json:"addr" yaml:"addr"` // broker url
+	ClientID string        `koanf:"client_id" json:"client_id" yaml:"client_id"`
+	Topic    string        `koanf:"topic" json:"topic" yaml:"topic"`
+	JSONPath string        `koanf:"json_path" json:"json_path" yaml:"json_path"` // e.g. "outdoor.temperature"; empty for a plain numeric payload
+	QoS      byte          `koanf:"qos" json:"qos" yaml:"qos"`
+	MaxAge   time.Duration `koanf:"max_age" json:"max_age" yaml:"max_age"` // 0 never expires the last received value
+	Username string        `koanf:"username" json:"username" yaml:"username"`
+	Password string        `koanf:"password" json:"password" yaml:"password"`
 }
 
 type HTTPConfig struct {
-	Enabled bool   `koanf:"enabled" json:"enabled" yaml:"enabled"`
-	Addr    string `koanf:"addr" json:"addr" yaml:"addr"`
+	Enabled   bool   `koanf:"enabled" json:"enabled" yaml:"enabled"`
+	Addr      string `koanf:"addr" json:"addr" yaml:"addr"`
+	SwaggerUI bool   `koanf:"swagger_ui" json:"swagger_ui" yaml:"swagger_ui"`
+	Dashboard bool   `koanf:"dashboard" json:"dashboard" yaml:"dashboard"`
+
+	Auth  HTTPAuthConfig  `koanf:"auth" json:"auth" yaml:"auth"`
+	TLS   HTTPTLSConfig   `koanf:"tls" json:"tls" yaml:"tls"`
+	Chaos HTTPChaosConfig `koanf:"chaos" json:"chaos" yaml:"chaos"`
+}
+
+// HTTPChaosConfig injects latency and faults into /v1 requests; rates are
+// probabilities between 0 and 1. Also adjustable at runtime via /v1/chaos.
+type HTTPChaosConfig struct {
+	Latency     time.Duration `koanf:"latency" json:"latency" yaml:"latency"`
+	Jitter      time.Duration `koanf:"jitter" json:"jitter" yaml:"jitter"`
+	ErrorRate   float64       `koanf:"error_rate" json:"error_rate" yaml:"error_rate"`
+	ErrorStatus int           `koanf:"error_status" json:"error_status" yaml:"error_status"`
+	DropRate    float64       `koanf:"drop_rate" json:"drop_rate" yaml:"drop_rate"`
+	HangRate    float64       `koanf:"hang_rate" json:"hang_rate" yaml:"hang_rate"`
+}
+
+// HTTPTLSConfig serves HTTPS from cert_file/key_file or a generated
+// self-signed certificate; client_ca_file enables mutual TLS.
+type HTTPTLSConfig struct {
+	CertFile     string `koanf:"cert_file" json:"cert_file" yaml:"cert_file"`
+	KeyFile      string `koanf:"key_file" json:"key_file" yaml:"key_file"`
+	ClientCAFile string `koanf:"client_ca_file" json:"client_ca_file" yaml:"client_ca_file"`
+	SelfSigned   bool   `koanf:"self_signed" json:"self_signed" yaml:"self_signed"`
+}
+
+// HTTPAuthConfig enables authentication when any credential is configured.
+// Scopes are "read" (default) or "read_write".
+type HTTPAuthConfig struct {
+	APIKeys []HTTPAPIKeyConfig    `koanf:"api_keys" json:"api_keys" yaml:"api_keys"`
+	Basic   []HTTPBasicUserConfig `koanf:"basic" json:"basic" yaml:"basic"`
+	JWT     HTTPJWTConfig         `koanf:"jwt" json:"jwt" yaml:"jwt"`
+}
+
+type HTTPAPIKeyConfig struct {
+	Name  string `koanf:"name" json:"name" yaml:"name"`
+	Key   string `koanf:"key" json:"key" yaml:"key"`
+	Scope string `koanf:"scope" json:"scope" yaml:"scope"`
+}
+
+type HTTPBasicUserConfig struct {
+	Username string `koanf:"username" json:"username" yaml:"username"`
+	Password string `koanf:"password" json:"password" yaml:"password"`
+	Scope    string `koanf:"scope" json:"scope" yaml:"scope"`
+}
+
+type HTTPJWTConfig struct {
+	HMACSecret    string `koanf:"hmac_secret" json:"hmac_secret" yaml:"hmac_secret"`
+	PublicKeyFile string `koanf:"public_key_file" json:"public_key_file" yaml:"public_key_file"`
+	Issuer        string `koanf:"issuer" json:"issuer" yaml:"issuer"`
+	Audience      string `koanf:"audience" json:"audience" yaml:"audience"`
 }
 
 type MQTTConfig struct {
-	Enabled         bool          `koanf:"enabled" json:"enabled" yaml:"enabled"`
-	Addr            string        `koanf:"addr" json:"addr" yaml:"addr"`
-	ClientID        string        `koanf:"client_id" json:"client_id" yaml:"client_id"`
-	BaseTopic       string        `koanf:"base_topic" json:"base_topic" yaml:"base_topic"`
-	QoS             byte          `koanf:"qos" json:"qos" yaml:"qos"`
-	RetainSnapshot  bool          `koanf:"retain_snapshot" json:"retain_snapshot" yaml:"retain_snapshot"`
-	PublishMode     string        `koanf:"publish_mode" json:"publish_mode" yaml:"publish_mode"`
-	PublishInterval time.Duration `koanf:"publish_interval" json:"publish_interval" yaml:"publish_interval"`
-	Username        string        `koanf:"username" json:"username" yaml:"username"`
-	Password        string        `koanf:"password" json:"password" yaml:"password"`
+	Enabled             bool               `koanf:"enabled" json:"enabled" yaml:"enabled"`
+	Addr                string             `koanf:"addr" json:"addr" yaml:"addr"`
+	ClientID            string             `koanf:"client_id" json:"client_id" yaml:"client_id"`
+	EmbeddedBrokerAddr  string             `koanf:"embedded_broker_addr" json:"embedded_broker_addr" yaml:"embedded_broker_addr"` // e.g. ":1883"; replaces addr
+	ProtocolVersion     int                `koanf:"protocol_version" json:"protocol_version" yaml:"protocol_version"`             // 4 (MQTT 3.1.1) | 5
+	BaseTopic           string             `koanf:"base_topic" json:"base_topic" yaml:"base_topic"`
+	QoS                 byte               `koanf:"qos" json:"qos" yaml:"qos"`
+	RetainSnapshot      bool               `koanf:"retain_snapshot" json:"retain_snapshot" yaml:"retain_snapshot"`
+	PublishMode         string             `koanf:"publish_mode" json:"publish_mode" yaml:"publish_mode"`
+	PublishInterval     time.Duration      `koanf:"publish_interval" json:"publish_interval" yaml:"publish_interval"`
+	AttributeTopics     bool               `koanf:"attribute_topics" json:"attribute_topics" yaml:"attribute_topics"`
+	Deadband            float64            `koanf:"deadband" json:"deadband" yaml:"deadband"`
+	Username            string             `koanf:"username" json:"username" yaml:"username"`
+	Password            string             `koanf:"password" json:"password" yaml:"password"`
+	CAFile              string             `koanf:"ca_file" json:"ca_file" yaml:"ca_file"`       // TLS options, for ssl:// and wss:// brokers
+	CertFile            string             `koanf:"cert_file" json:"cert_file" yaml:"cert_file"` // client certificate (mutual TLS)
+	KeyFile             string             `koanf:"key_file" json:"key_file" yaml:"key_file"`
+	InsecureSkipVerify  bool               `koanf:"insecure_skip_verify" json:"insecure_skip_verify" yaml:"insecure_skip_verify"`
+	KeepAlive           time.Duration      `koanf:"keep_alive" json:"keep_alive" yaml:"keep_alive"`
+	HeartbeatInterval   time.Duration      `koanf:"heartbeat_interval" json:"heartbeat_interval" yaml:"heartbeat_interval"` // 0 disables heartbeats
+	HeartbeatTopic      string             `koanf:"heartbeat_topic" json:"heartbeat_topic" yaml:"heartbeat_topic"`
+	PayloadFormat       string             `koanf:"payload_format" json:"payload_format" yaml:"payload_format"` // json | sparkplug_b | homie | template
+	SparkplugGroupID    string             `koanf:"sparkplug_group_id" json:"sparkplug_group_id" yaml:"sparkplug_group_id"`
+	SparkplugEdgeNodeID string             `koanf:"sparkplug_edge_node_id" json:"sparkplug_edge_node_id" yaml:"sparkplug_edge_node_id"`
+	Template            MQTTTemplateConfig `koanf:"template" json:"template" yaml:"template"` // payload schema for payload_format: template
+	HomeAssistant       bool               `koanf:"home_assistant" json:"home_assistant" yaml:"home_assistant"`
+	DiscoveryPrefix     string             `koanf:"discovery_prefix" json:"discovery_prefix" yaml:"discovery_prefix"`
+}
+
+// MQTTTemplateConfig maps snapshot attributes to a vendor payload schema,
+// e.g. a Zigbee2MQTT thermostat's.
+type MQTTTemplateConfig struct {
+	SnapshotTopic string                    `koanf:"snapshot_topic" json:"snapshot_topic" yaml:"snapshot_topic"` // {base_topic}/snapshot by default
+	CommandTopic  string                    `koanf:"command_topic" json:"command_topic" yaml:"command_topic"`    // {base_topic}/set by default
+	Fields        []MQTTTemplateFieldConfig `koanf:"fields" json:"fields" yaml:"fields"`
+}
+
+type MQTTTemplateFieldConfig struct {
+	Attribute string         `koanf:"attribute" json:"attribute" yaml:"attribute"`
+	Path      string         `koanf:"path" json:"path" yaml:"path"` // dot-separated, e.g. state.setpoint
+	Unit      string         `koanf:"unit" json:"unit" yaml:"unit"` // °C | °F
+	Scale     float64        `koanf:"scale" json:"scale" yaml:"scale"`
+	Values    map[string]any `koanf:"values" json:"values" yaml:"values"` // attribute value -> vendor value
+	Off       any            `koanf:"off" json:"off" yaml:"off"`          // published while disabled
 }
 
 type Modbusconfig struct {
@@ -138,6 +251,14 @@ type KNXConfig struct {
 	GAMiddle        int           `koanf:"ga_middle" json:"ga_middle" yaml:"ga_middle"`
 }
 
+type HaystackConfig struct {
+	Enabled        bool          `koanf:"enabled" json:"enabled" yaml:"enabled"`
+	Addr           string        `koanf:"addr" json:"addr" yaml:"addr"`
+	SampleInterval time.Duration `koanf:"sample_interval" json:"sample_interval" yaml:"sample_interval"`
+	HistorySize    int           `koanf:"history_size" json:"history_size" yaml:"history_size"`
+	WatchLease     time.Duration `koanf:"watch_lease" json:"watch_lease" yaml:"watch_lease"`
+}
+
 func LoadConfig(path string) (Config, error) {
 	k := koanf.New(".")
 
@@ -187,7 +308,7 @@ func LoadConfig(path string) (Config, error) {
 				if key == "" {
 					return "", nil // ignore
 				}
-				return key, v
+				return key, envValueTransform(key, v)
 			},
 		}),
 		nil,
@@ -237,6 +358,17 @@ func envKeyTransform(k string) string {
 		}
 		ctrl := parts[1]
 		field := strings.Join(parts[2:], "_")
+		if ctrl == "http" {
+			if rest, ok := strings.CutPrefix(field, "auth_jwt_"); ok {
+				return "controllers.http.auth.jwt." + rest
+			}
+			if rest, ok := strings.CutPrefix(field, "tls_"); ok {
+				return "controllers.http.tls." + rest
+			}
+			if rest, ok := strings.CutPrefix(field, "chaos_"); ok {
+				return "controllers.http.chaos." + rest
+			}
+		}
 		return "controllers." + ctrl + "." + field
 
 	case "thermostat":
@@ -263,7 +395,7 @@ func envKeyTransform(k string) string {
 		field := strings.Join(parts[2:], "_")
 		return "heat_loss." + field
 
-	case "weather": // weather_provider_<field...> -> weather_provider.<field>, with nested open_meteo.*/static.*
+	case "weather": // weather_provider_<field...> -> weather_provider.<field>, with nested open_meteo.*/static.*/file.*/mqtt.*
 		if len(parts) < 3 {
 			return key
 		}
@@ -273,8 +405,12 @@ func envKeyTransform(k string) string {
 			return "weather_provider.open_meteo." + strings.TrimPrefix(field, "open_meteo_")
 		case strings.HasPrefix(field, "static_"):
 			return "weather_provider.static." + strings.TrimPrefix(field, "static_")
+		case strings.HasPrefix(field, "file_"):
+			return "weather_provider.file." + strings.TrimPrefix(field, "file_")
+		case strings.HasPrefix(field, "mqtt_"):
+			return "weather_provider.mqtt." + strings.TrimPrefix(field, "mqtt_")
 		default:
-			// type, refresh_interval
+			// type, refresh_interval, chain, failure_threshold
 			return "weather_provider." + field
 		}
 
@@ -292,6 +428,24 @@ func envKeyTransform(k string) string {
 	}
 }
 
+// listEnvKeys are list-valued keys, given as comma-separated env vars.
+var listEnvKeys = map[string]bool{
+	"weather_provider.chain": true,
+}
+
+func envValueTransform(key, v string) any {
+	if !listEnvKeys[key] {
+		return v
+	}
+	var items []string
+	for item := range strings.SplitSeq(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func normalize(cfg *Config) {
 	// Convenience: controller + addr -> enable and disable all others
 	if cfg.Controller != "" {
@@ -301,6 +455,7 @@ func normalize(cfg *Config) {
 		cfg.Controllers.MODBUS.Enabled = false
 		cfg.Controllers.BACNET.Enabled = false
 		cfg.Controllers.KNX.Enabled = false
+		cfg.Controllers.Haystack.Enabled = false
 
 		switch c {
 		case "http":
@@ -328,6 +483,11 @@ func normalize(cfg *Config) {
 			if cfg.Addr != "" {
 				cfg.Controllers.KNX.Addr = cfg.Addr
 			}
+		case "haystack":
+			cfg.Controllers.Haystack.Enabled = true
+			if cfg.Addr != "" {
+				cfg.Controllers.Haystack.Addr = cfg.Addr
+			}
 		}
 	}
 
@@ -342,21 +502,39 @@ func normalize(cfg *Config) {
 func validate(cfg Config) error {
 	if cfg.Controller != "" {
 		switch strings.ToLower(strings.TrimSpace(cfg.Controller)) {
-		case "http", "mqtt", "modbus", "bacnet", "knx":
+		case "http", "mqtt", "modbus", "bacnet", "knx", "haystack":
 		default:
-			return fmt.Errorf("invalid controller %q (expected http|mqtt|modbus|bacnet|knx)", cfg.Controller)
+			return fmt.Errorf("invalid controller %q (expected http|mqtt|modbus|bacnet|knx|haystack)", cfg.Controller)
 		}
 	}
 
 	if cfg.Controllers.HTTP.Enabled && strings.TrimSpace(cfg.Controllers.HTTP.Addr) == "" {
 		return errors.New("http controller enabled but controllers.http.addr is empty")
 	}
-	if cfg.Controllers.MQTT.Enabled && strings.TrimSpace(cfg.Controllers.MQTT.Addr) == "" {
-		return errors.New("mqtt controller enabled but controllers.mqtt.addr is empty")
+	if cfg.Controllers.MQTT.Enabled && strings.TrimSpace(cfg.Controllers.MQTT.Addr) == "" && cfg.Controllers.MQTT.EmbeddedBrokerAddr == "" {
+		return errors.New("mqtt controller enabled but controllers.mqtt.addr and embedded_broker_addr are empty")
 	}
 	if cfg.Controllers.MODBUS.Enabled && strings.TrimSpace(cfg.Controllers.MODBUS.Addr) == "" {
 		return errors.New("modbus controller enabled but controllers.modbus.addr is empty")
 	}
+	if cfg.Controllers.Haystack.Enabled && strings.TrimSpace(cfg.Controllers.Haystack.Addr) == "" {
+		return errors.New("haystack controller enabled but controllers.haystack.addr is empty")
+	}
+
+	if tls := cfg.Controllers.HTTP.TLS; tls.CertFile != "" || tls.KeyFile != "" {
+		switch {
+		case tls.SelfSigned:
+			return errors.New("controllers.http.tls.self_signed cannot be combined with cert_file/key_file")
+		case tls.CertFile == "" || tls.KeyFile == "":
+			return errors.New("controllers.http.tls.cert_file and key_file must be set together")
+		}
+	} else if tls.ClientCAFile != "" && !tls.SelfSigned {
+		return errors.New("controllers.http.tls.client_ca_file requires cert_file/key_file or self_signed")
+	}
+
+	if mqtt := cfg.Controllers.MQTT; (mqtt.CertFile == "") != (mqtt.KeyFile == "") {
+		return errors.New("controllers.mqtt.cert_file and key_file must be set together")
+	}
 
 	if cfg.Regulator.Interval < 0 {
 		return errors.New("regulator.interval must be >= 0")
@@ -368,29 +546,60 @@ func validate(cfg Config) error {
 		return errors.New("controllers.modbus.sync_interval must be >= 0")
 	}
 
-	switch weatherType(cfg) {
-	case "static":
-	case "open-meteo":
-		if lat := cfg.Weather.OpenMeteo.Latitude; lat < -90 || lat > 90 {
-			return fmt.Errorf("weather_provider.open_meteo.latitude %v out of range [-90, 90]", lat)
-		}
-		if lon := cfg.Weather.OpenMeteo.Longitude; lon < -180 || lon > 180 {
-			return fmt.Errorf("weather_provider.open_meteo.longitude %v out of range [-180, 180]", lon)
+	for _, raw := range weatherTypes(cfg) {
+		switch normalizeWeatherType(raw) {
+		case "static":
+		case "file":
+			if strings.TrimSpace(cfg.Weather.File.Path) == "" {
+				return errors.New("file weather provider selected but weather_provider.file.path is empty")
+			}
+		case "open-meteo":
+			if lat := cfg.Weather.OpenMeteo.Latitude; lat < -90 || lat > 90 {
+				return fmt.Errorf("weather_provider.open_meteo.latitude %v out of range [-90, 90]", lat)
+			}
+			if lon := cfg.Weather.OpenMeteo.Longitude; lon < -180 || lon > 180 {
+				return fmt.Errorf("weather_provider.open_meteo.longitude %v out of range [-180, 180]", lon)
+			}
+			if cfg.Weather.OpenMeteo.MaxStale < 0 {
+				return errors.New("weather_provider.open_meteo.max_stale must be >= 0")
+			}
+		case "mqtt":
+			if strings.TrimSpace(cfg.Weather.MQTT.Topic) == "" {
+				return errors.New("mqtt weather provider selected but weather_provider.mqtt.topic is empty")
+			}
+			if cfg.Weather.MQTT.QoS > 1 {
+				return errors.New("weather_provider.mqtt.qos must be 0 or 1")
+			}
+			if cfg.Weather.MQTT.MaxAge < 0 {
+				return errors.New("weather_provider.mqtt.max_age must be >= 0")
+			}
+		default:
+			return fmt.Errorf("invalid weather provider type %q (expected static|file|open-meteo|mqtt)", raw)
 		}
-	default:
-		return fmt.Errorf("invalid weather_provider.type %q (expected static|open-meteo)", cfg.Weather.Type)
 	}
 	if cfg.Weather.RefreshInterval < 0 {
 		return errors.New("weather_provider.refresh_interval must be >= 0")
 	}
+	if cfg.Weather.FailureThreshold < 0 {
+		return errors.New("weather_provider.failure_threshold must be >= 0")
+	}
 
 	return nil
 }
 
-// weatherType normalizes the provider type; empty defaults to "static", unknown
-// values are returned verbatim for callers to reject.
-func weatherType(cfg Config) string {
-	switch t := strings.ToLower(strings.TrimSpace(cfg.Weather.Type)); t {
+// weatherTypes returns the configured provider types in priority order: the
+// chain when set, otherwise the single type.
+func weatherTypes(cfg Config) []string {
+	if len(cfg.Weather.Chain) > 0 {
+		return cfg.Weather.Chain
+	}
+	return []string{cfg.Weather.Type}
+}
+
+// normalizeWeatherType normalizes a provider type; empty defaults to "static",
+// unknown values are returned verbatim for callers to reject.
+func normalizeWeatherType(t string) string {
+	switch t := strings.ToLower(strings.TrimSpace(t)); t {
 	case "", "static":
 		return "static"
 	case "open-meteo", "open_meteo", "openmeteo":
@@ -483,23 +692,66 @@ func (c Config) HeatLossParams() (thermostat.HeatLossSimulatorParams, error) {
 	return params, nil
 }
 
-// WeatherProvider builds the provider selected by weather_provider.type.
+// WeatherProvider builds the provider selected by weather_provider.type, or a
+// fallback chain when weather_provider.chain is set.
 func (c Config) WeatherProvider(logger *slog.Logger) (thermostat.WeatherProvider, error) {
-	switch weatherType(c) {
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	if len(c.Weather.Chain) == 0 {
+		return c.weatherProvider(c.Weather.Type, logger)
+	}
+
+	providers := make([]thermostat.WeatherProvider, 0, len(c.Weather.Chain))
+	for _, t := range c.Weather.Chain {
+		p, err := c.weatherProvider(t, logger.With("provider", normalizeWeatherType(t)))
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, p)
+	}
+	return weather.NewFallback(weather.FallbackConfig{
+		Providers:        providers,
+		FailureThreshold: c.Weather.FailureThreshold,
+		Logger:           logger,
+	})
+}
+
+func (c Config) weatherProvider(t string, logger *slog.Logger) (thermostat.WeatherProvider, error) {
+	switch normalizeWeatherType(t) {
 	case "static":
 		temp := c.HeatLoss.OutdoorTemperature
 		if c.Weather.Static.OutdoorTemperature != nil {
 			temp = *c.Weather.Static.OutdoorTemperature
 		}
 		return weather.NewStatic(temp), nil
+	case "file":
+		return weather.NewFile(c.Weather.File.Path), nil
 	case "open-meteo":
 		return weather.NewOpenMeteo(weather.OpenMeteoConfig{
 			Latitude:        c.Weather.OpenMeteo.Latitude,
 			Longitude:       c.Weather.OpenMeteo.Longitude,
 			RefreshInterval: c.Weather.RefreshInterval,
+			MaxStale:        c.Weather.OpenMeteo.MaxStale,
 			Logger:          logger,
 		}), nil
+	case "mqtt":
+		clientID := c.Weather.MQTT.ClientID
+		if clientID == "" && c.DeviceID != "" {
+			clientID = "thermocktat-weather-" + c.DeviceID
+		}
+		return weather.NewMQTT(weather.MQTTConfig{
+			BrokerURL: c.Weather.MQTT.Addr,
+			ClientID:  clientID,
+			Topic:     c.Weather.MQTT.Topic,
+			JSONPath:  c.Weather.MQTT.JSONPath,
+			QoS:       c.Weather.MQTT.QoS,
+			MaxAge:    c.Weather.MQTT.MaxAge,
+			Username:  c.Weather.MQTT.Username,
+			Password:  c.Weather.MQTT.Password,
+			Logger:    logger,
+		})
 	default:
-		return nil, fmt.Errorf("invalid weather_provider.type %q (expected static|open-meteo)", c.Weather.Type)
+		return nil, fmt.Errorf("invalid weather provider type %q (expected static|file|open-meteo|mqtt)", t)
 	}
 }
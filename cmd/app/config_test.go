@@ -37,6 +37,15 @@ func TestEnvKeyTransform_Controllers(t *testing.T) {
 		{"CONTROLLERS__ADDR", "controllers..addr"}, // edge case
 		{"controllers_HTTP_addr", "controllers.http.addr"},
 		{"CONTROLLERS_MQTT_PUBLISH_MODE", "controllers.mqtt.publish_mode"},
+		{"CONTROLLERS_HTTP_SWAGGER_UI", "controllers.http.swagger_ui"},
+		{"CONTROLLERS_HTTP_DASHBOARD", "controllers.http.dashboard"},
+		{"CONTROLLERS_HTTP_AUTH_JWT_HMAC_SECRET", "controllers.http.auth.jwt.hmac_secret"},
+		{"CONTROLLERS_HTTP_AUTH_JWT_PUBLIC_KEY_FILE", "controllers.http.auth.jwt.public_key_file"},
+		{"CONTROLLERS_HTTP_TLS_CLIENT_CA_FILE", "controllers.http.tls.client_ca_file"},
+		{"CONTROLLERS_HTTP_TLS_SELF_SIGNED", "controllers.http.tls.self_signed"},
+		{"CONTROLLERS_HTTP_CHAOS_ERROR_RATE", "controllers.http.chaos.error_rate"},
+		{"CONTROLLERS_MQTT_INSECURE_SKIP_VERIFY", "controllers.mqtt.insecure_skip_verify"},
+		{"CONTROLLERS_MQTT_EMBEDDED_BROKER_ADDR", "controllers.mqtt.embedded_broker_addr"},
 	}
 
 	for _, tt := range tests {
@@ -82,6 +91,10 @@ func TestLoadConfigEmbeddedDefaults(t *testing.T) {
 	if config.Controllers.HTTP.Addr != defaultHttpAddr {
 		t.Fatalf("LoadConfig() = %v, want %v", config.Controllers.HTTP.Addr, defaultHttpAddr)
 	}
+	// Unauthenticated UIs are opt-in.
+	if config.Controllers.HTTP.SwaggerUI || config.Controllers.HTTP.Dashboard {
+		t.Fatalf("swagger_ui=%v dashboard=%v, want both disabled by default", config.Controllers.HTTP.SwaggerUI, config.Controllers.HTTP.Dashboard)
+	}
 }
 
 func TestLoadConfigEnvVarOverride(t *testing.T) {
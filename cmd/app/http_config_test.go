@@ -0,0 +1,92 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig_HTTPAuthFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := `
+controllers:
+  http:
+    auth:
+      api_keys:
+        - {name: bms, key: k1, scope: read_write}
+      basic:
+        - {username: viewer, password: pw}
+`
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TMK_CONTROLLERS_HTTP_AUTH_JWT_HMAC_SECRET", "s3cret")
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	auth := cfg.Controllers.HTTP.Auth
+	if len(auth.APIKeys) != 1 || auth.APIKeys[0].Key != "k1" || auth.APIKeys[0].Scope != "read_write" {
+		t.Fatalf("api_keys = %+v", auth.APIKeys)
+	}
+	if len(auth.Basic) != 1 || auth.Basic[0].Username != "viewer" {
+		t.Fatalf("basic = %+v", auth.Basic)
+	}
+	if auth.JWT.HMACSecret != "s3cret" {
+		t.Fatalf("jwt.hmac_secret = %q", auth.JWT.HMACSecret)
+	}
+}
+
+func TestLoadConfig_HTTPTLSFromEnv(t *testing.T) {
+	t.Setenv("TMK_CONTROLLERS_HTTP_TLS_SELF_SIGNED", "true")
+	t.Setenv("TMK_CONTROLLERS_HTTP_TLS_CLIENT_CA_FILE", "/etc/thermocktat/ca.pem")
+
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if tls := cfg.Controllers.HTTP.TLS; !tls.SelfSigned || tls.ClientCAFile != "/etc/thermocktat/ca.pem" {
+		t.Fatalf("tls = %+v", tls)
+	}
+}
+
+func TestLoadConfig_HTTPTLSInvalidCombinations(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{"cert without key", map[string]string{"TMK_CONTROLLERS_HTTP_TLS_CERT_FILE": "cert.pem"}},
+		{"cert and self-signed", map[string]string{
+			"TMK_CONTROLLERS_HTTP_TLS_CERT_FILE":   "cert.pem",
+			"TMK_CONTROLLERS_HTTP_TLS_KEY_FILE":    "key.pem",
+			"TMK_CONTROLLERS_HTTP_TLS_SELF_SIGNED": "true",
+		}},
+		{"client CA alone", map[string]string{"TMK_CONTROLLERS_HTTP_TLS_CLIENT_CA_FILE": "ca.pem"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			if _, err := LoadConfig(""); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestLoadConfig_HTTPChaosFromEnv(t *testing.T) {
+	t.Setenv("TMK_CONTROLLERS_HTTP_CHAOS_LATENCY", "250ms")
+	t.Setenv("TMK_CONTROLLERS_HTTP_CHAOS_ERROR_RATE", "0.1")
+
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	chaos := cfg.Controllers.HTTP.Chaos
+	if chaos.Latency != 250*time.Millisecond || chaos.ErrorRate != 0.1 || chaos.ErrorStatus != 503 {
+		t.Fatalf("chaos = %+v", chaos)
+	}
+}
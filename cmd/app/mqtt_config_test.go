@@ -0,0 +1,43 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfig_MQTTTemplateFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := `
+controllers:
+  mqtt:
+    payload_format: template
+    template:
+      snapshot_topic: zigbee2mqtt/room101
+      fields:
+        - {attribute: temperature_setpoint, path: occupied_heating_setpoint}
+        - {attribute: temperature_setpoint, path: display.setpoint, unit: "°F", scale: 10}
+        - attribute: mode
+          path: system_mode
+          values: {fan: fan_only}
+          off: "off"
+`
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	tpl := cfg.Controllers.MQTT.Template
+	if tpl.SnapshotTopic != "zigbee2mqtt/room101" || len(tpl.Fields) != 3 {
+		t.Fatalf("template = %+v", tpl)
+	}
+	if f := tpl.Fields[1]; f.Path != "display.setpoint" || f.Unit != "°F" || f.Scale != 10 {
+		t.Fatalf("fields[1] = %+v", f)
+	}
+	if f := tpl.Fields[2]; f.Values["fan"] != "fan_only" || f.Off != "off" {
+		t.Fatalf("fields[2] = %+v", f)
+	}
+}
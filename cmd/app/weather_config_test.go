@@ -2,6 +2,8 @@ package app
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/Agrid-Dev/thermocktat/internal/weather"
@@ -17,6 +19,12 @@ func TestEnvKeyTransform_WeatherProvider(t *testing.T) {
 		{"WEATHER_PROVIDER_OPEN_METEO_LATITUDE", "weather_provider.open_meteo.latitude"},
 		{"WEATHER_PROVIDER_OPEN_METEO_LONGITUDE", "weather_provider.open_meteo.longitude"},
 		{"WEATHER_PROVIDER_STATIC_OUTDOOR_TEMPERATURE", "weather_provider.static.outdoor_temperature"},
+		{"WEATHER_PROVIDER_FILE_PATH", "weather_provider.file.path"},
+		{"WEATHER_PROVIDER_CHAIN", "weather_provider.chain"},
+		{"WEATHER_PROVIDER_FAILURE_THRESHOLD", "weather_provider.failure_threshold"},
+		{"WEATHER_PROVIDER_OPEN_METEO_MAX_STALE", "weather_provider.open_meteo.max_stale"},
+		{"WEATHER_PROVIDER_MQTT_TOPIC", "weather_provider.mqtt.topic"},
+		{"WEATHER_PROVIDER_MQTT_JSON_PATH", "weather_provider.mqtt.json_path"},
 		{"WEATHER_PROVIDER", "weather_provider"}, // not enough parts -> passthrough
 	}
 
@@ -120,3 +128,98 @@ func TestLoadConfig_InvalidLatitudeRejected(t *testing.T) {
 		t.Fatal("expected error for out-of-range latitude")
 	}
 }
+
+func TestWeatherProvider_ChainBuildsFallback(t *testing.T) {
+	cfg := Config{
+		HeatLoss: HeatLossConfig{OutdoorTemperature: 10},
+		Weather: WeatherProviderConfig{
+			Chain: []string{"file", "static"},
+			File:  FileWeatherConfig{Path: filepath.Join(t.TempDir(), "missing")},
+		},
+	}
+
+	p, err := cfg.WeatherProvider(nil)
+	if err != nil {
+		t.Fatalf("WeatherProvider: %v", err)
+	}
+	fb, ok := p.(*weather.Fallback)
+	if !ok {
+		t.Fatalf("provider = %T, want *weather.Fallback", p)
+	}
+	if fb.Name() != "file" {
+		t.Fatalf("active provider = %q, want file", fb.Name())
+	}
+}
+
+func TestLoadConfig_ChainFromEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outdoor")
+	if err := os.WriteFile(path, []byte("7"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TMK_WEATHER_PROVIDER_CHAIN", "open-meteo,file,static")
+	t.Setenv("TMK_WEATHER_PROVIDER_FILE_PATH", path)
+
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	want := []string{"open-meteo", "file", "static"}
+	if len(cfg.Weather.Chain) != len(want) {
+		t.Fatalf("chain = %v, want %v", cfg.Weather.Chain, want)
+	}
+	for i := range want {
+		if cfg.Weather.Chain[i] != want[i] {
+			t.Fatalf("chain = %v, want %v", cfg.Weather.Chain, want)
+		}
+	}
+}
+
+func TestLoadConfig_FileProviderRequiresPath(t *testing.T) {
+	t.Setenv("TMK_WEATHER_PROVIDER_TYPE", "file")
+
+	if _, err := LoadConfig(""); err == nil {
+		t.Fatal("expected error for file provider without path")
+	}
+}
+
+func TestLoadConfig_InvalidChainEntryRejected(t *testing.T) {
+	t.Setenv("TMK_WEATHER_PROVIDER_CHAIN", "static,nope")
+
+	if _, err := LoadConfig(""); err == nil {
+		t.Fatal("expected error for unknown chain entry")
+	}
+}
+
+func TestWeatherProvider_MQTTSelected(t *testing.T) {
+	cfg := Config{
+		DeviceID: "room101",
+		Weather: WeatherProviderConfig{
+			Type: "mqtt",
+			MQTT: MQTTWeatherConfig{Topic: "site/weather", JSONPath: "outdoor.temperature"},
+		},
+	}
+
+	p, err := cfg.WeatherProvider(nil)
+	if err != nil {
+		t.Fatalf("WeatherProvider: %v", err)
+	}
+	if _, ok := p.(*weather.MQTT); !ok {
+		t.Fatalf("provider = %T, want *weather.MQTT", p)
+	}
+	if _, ok := p.(weather.Runner); !ok {
+		t.Fatal("mqtt provider must be runnable")
+	}
+}
+
+func TestLoadConfig_MQTTProviderRequiresTopic(t *testing.T) {
+	t.Setenv("TMK_WEATHER_PROVIDER_TYPE", "mqtt")
+
+	if _, err := LoadConfig(""); err == nil {
+		t.Fatal("expected error for mqtt provider without topic")
+	}
+
+	t.Setenv("TMK_WEATHER_PROVIDER_MQTT_TOPIC", "site/weather")
+	if _, err := LoadConfig(""); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+}
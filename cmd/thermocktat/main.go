@@ -6,17 +6,20 @@ import (
 	"flag"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"github.com/Agrid-Dev/thermocktat/cmd/app"
 	"github.com/Agrid-Dev/thermocktat/internal/buildinfo"
 	bacnetctrl "github.com/Agrid-Dev/thermocktat/internal/controllers/bacnet"
+	haystackctrl "github.com/Agrid-Dev/thermocktat/internal/controllers/haystack"
 	httpctrl "github.com/Agrid-Dev/thermocktat/internal/controllers/http"
 	knxctrl "github.com/Agrid-Dev/thermocktat/internal/controllers/knx"
 	modbusctrl "github.com/Agrid-Dev/thermocktat/internal/controllers/modbus"
 	mqttctrl "github.com/Agrid-Dev/thermocktat/internal/controllers/mqtt"
 	"github.com/Agrid-Dev/thermocktat/internal/logging"
 	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+	"github.com/Agrid-Dev/thermocktat/internal/weather"
 )
 
 func main() {
@@ -49,6 +52,7 @@ func main() {
 		"modbus", cfg.Controllers.MODBUS.Enabled,
 		"bacnet", cfg.Controllers.BACNET.Enabled,
 		"knx", cfg.Controllers.KNX.Enabled,
+		"haystack", cfg.Controllers.Haystack.Enabled,
 	)
 
 	snap, err := cfg.Snapshot()
@@ -74,7 +78,12 @@ func main() {
 		os.Exit(1)
 	}
 
-	weatherLog := root.With("component", "weather", "provider", cfg.Weather.Type)
+	weatherLog := root.With("component", "weather")
+	if len(cfg.Weather.Chain) > 0 {
+		weatherLog = weatherLog.With("chain", strings.Join(cfg.Weather.Chain, ","))
+	} else {
+		weatherLog = weatherLog.With("provider", cfg.Weather.Type)
+	}
 	weatherProvider, err := cfg.WeatherProvider(weatherLog)
 	if err != nil {
 		root.Error("weather provider init failed", "err", err)
@@ -94,6 +103,16 @@ func main() {
 		}
 	}()
 
+	// keep push-based weather providers (e.g. mqtt) connected
+	if runner, ok := weatherProvider.(weather.Runner); ok {
+		go func() {
+			if err := runner.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				weatherLog.Error("weather provider exited", "err", err)
+				cancel()
+			}
+		}()
+	}
+
 	// start outdoor-temperature refresh
 	go func() {
 		if err := th.RunWeatherRefresh(ctx, weatherProvider, cfg.Weather.RefreshInterval); err != nil && !errors.Is(err, context.Canceled) {
@@ -104,9 +123,37 @@ func main() {
 
 	if cfg.Controllers.HTTP.Enabled {
 		log := root.With("controller", "http")
-		srv := httpctrl.New(th, cfg.Controllers.HTTP.Addr, deviceID, log)
+		srv, err := httpctrl.New(th, httpctrl.Config{
+			DeviceID:  deviceID,
+			Addr:      cfg.Controllers.HTTP.Addr,
+			SwaggerUI: cfg.Controllers.HTTP.SwaggerUI,
+			Dashboard: cfg.Controllers.HTTP.Dashboard,
+			Auth:      httpAuthConfig(cfg.Controllers.HTTP.Auth),
+			TLS: httpctrl.TLSConfig{
+				CertFile:     cfg.Controllers.HTTP.TLS.CertFile,
+				KeyFile:      cfg.Controllers.HTTP.TLS.KeyFile,
+				ClientCAFile: cfg.Controllers.HTTP.TLS.ClientCAFile,
+				SelfSigned:   cfg.Controllers.HTTP.TLS.SelfSigned,
+			},
+			Chaos: httpctrl.ChaosConfig{
+				Latency:     cfg.Controllers.HTTP.Chaos.Latency,
+				Jitter:      cfg.Controllers.HTTP.Chaos.Jitter,
+				ErrorRate:   cfg.Controllers.HTTP.Chaos.ErrorRate,
+				ErrorStatus: cfg.Controllers.HTTP.Chaos.ErrorStatus,
+				DropRate:    cfg.Controllers.HTTP.Chaos.DropRate,
+				HangRate:    cfg.Controllers.HTTP.Chaos.HangRate,
+			},
+		}, log)
+		if err != nil {
+			root.Error("http init failed", "err", err)
+			os.Exit(1)
+		}
 		go func() {
-			log.Info("controller started", "addr", cfg.Controllers.HTTP.Addr)
+			log.Info("controller started",
+				"addr", cfg.Controllers.HTTP.Addr,
+				"tls", cfg.Controllers.HTTP.TLS.CertFile != "" || cfg.Controllers.HTTP.TLS.SelfSigned,
+				"mtls", cfg.Controllers.HTTP.TLS.ClientCAFile != "",
+			)
 			if err := srv.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
 				log.Error("controller exited", "err", err)
 				cancel()
@@ -117,16 +164,35 @@ func main() {
 	if cfg.Controllers.MQTT.Enabled {
 		log := root.With("controller", "mqtt")
 		mc, err := mqttctrl.New(th, mqttctrl.Config{
-			DeviceID:        deviceID,
-			BrokerURL:       cfg.Controllers.MQTT.Addr,
-			ClientID:        cfg.Controllers.MQTT.ClientID,
-			BaseTopic:       cfg.Controllers.MQTT.BaseTopic,
-			QoS:             cfg.Controllers.MQTT.QoS,
-			RetainSnapshot:  cfg.Controllers.MQTT.RetainSnapshot,
-			PublishInterval: cfg.Controllers.MQTT.PublishInterval,
-			PublishMode:     cfg.Controllers.MQTT.PublishMode,
-			Username:        cfg.Controllers.MQTT.Username,
-			Password:        cfg.Controllers.MQTT.Password,
+			DeviceID:           deviceID,
+			BrokerURL:          cfg.Controllers.MQTT.Addr,
+			ClientID:           cfg.Controllers.MQTT.ClientID,
+			EmbeddedBrokerAddr: cfg.Controllers.MQTT.EmbeddedBrokerAddr,
+			ProtocolVersion:    cfg.Controllers.MQTT.ProtocolVersion,
+			BaseTopic:          cfg.Controllers.MQTT.BaseTopic,
+			QoS:                cfg.Controllers.MQTT.QoS,
+			RetainSnapshot:     cfg.Controllers.MQTT.RetainSnapshot,
+			PublishInterval:    cfg.Controllers.MQTT.PublishInterval,
+			PublishMode:        cfg.Controllers.MQTT.PublishMode,
+			AttributeTopics:    cfg.Controllers.MQTT.AttributeTopics,
+			Deadband:           cfg.Controllers.MQTT.Deadband,
+			Username:           cfg.Controllers.MQTT.Username,
+			Password:           cfg.Controllers.MQTT.Password,
+			TLS: mqttctrl.TLSConfig{
+				CAFile:             cfg.Controllers.MQTT.CAFile,
+				CertFile:           cfg.Controllers.MQTT.CertFile,
+				KeyFile:            cfg.Controllers.MQTT.KeyFile,
+				InsecureSkipVerify: cfg.Controllers.MQTT.InsecureSkipVerify,
+			},
+			KeepAlive:           cfg.Controllers.MQTT.KeepAlive,
+			HeartbeatInterval:   cfg.Controllers.MQTT.HeartbeatInterval,
+			HeartbeatTopic:      cfg.Controllers.MQTT.HeartbeatTopic,
+			PayloadFormat:       cfg.Controllers.MQTT.PayloadFormat,
+			SparkplugGroupID:    cfg.Controllers.MQTT.SparkplugGroupID,
+			SparkplugEdgeNodeID: cfg.Controllers.MQTT.SparkplugEdgeNodeID,
+			Template:            mqttTemplate(cfg.Controllers.MQTT.Template),
+			HomeAssistant:       cfg.Controllers.MQTT.HomeAssistant,
+			DiscoveryPrefix:     cfg.Controllers.MQTT.DiscoveryPrefix,
 		}, log)
 		if err != nil {
 			root.Error("mqtt init failed", "err", err)
@@ -134,10 +200,11 @@ func main() {
 		}
 
 		go func() {
-			log.Info("controller started",
-				"broker", cfg.Controllers.MQTT.Addr,
-				"base_topic", cfg.Controllers.MQTT.BaseTopic,
-			)
+			attrs := []any{"broker", mc.BrokerURL(), "base_topic", cfg.Controllers.MQTT.BaseTopic}
+			if addr := cfg.Controllers.MQTT.EmbeddedBrokerAddr; addr != "" {
+				attrs = append(attrs, "embedded_broker", addr)
+			}
+			log.Info("controller started", attrs...)
 			if err := mc.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
 				log.Error("controller exited", "err", err)
 				cancel()
@@ -216,7 +283,62 @@ func main() {
 		}()
 	}
 
+	if cfg.Controllers.Haystack.Enabled {
+		log := root.With("controller", "haystack")
+		hc, err := haystackctrl.New(th, haystackctrl.Config{
+			DeviceID:       deviceID,
+			Addr:           cfg.Controllers.Haystack.Addr,
+			SampleInterval: cfg.Controllers.Haystack.SampleInterval,
+			HistorySize:    cfg.Controllers.Haystack.HistorySize,
+			WatchLease:     cfg.Controllers.Haystack.WatchLease,
+		}, log)
+		if err != nil {
+			root.Error("haystack init failed", "err", err)
+			os.Exit(1)
+		}
+		go func() {
+			log.Info("controller started", "addr", cfg.Controllers.Haystack.Addr)
+			if err := hc.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				log.Error("controller exited", "err", err)
+				cancel()
+			}
+		}()
+	}
+
 	// Block until shutdown.
 	<-ctx.Done()
 	root.Info("shutting down")
 }
+
+func mqttTemplate(c app.MQTTTemplateConfig) mqttctrl.Template {
+	tpl := mqttctrl.Template{SnapshotTopic: c.SnapshotTopic, CommandTopic: c.CommandTopic}
+	for _, f := range c.Fields {
+		tpl.Fields = append(tpl.Fields, mqttctrl.TemplateField{
+			Attribute: f.Attribute,
+			Path:      f.Path,
+			Unit:      f.Unit,
+			Scale:     f.Scale,
+			Values:    f.Values,
+			Off:       f.Off,
+		})
+	}
+	return tpl
+}
+
+func httpAuthConfig(c app.HTTPAuthConfig) httpctrl.AuthConfig {
+	auth := httpctrl.AuthConfig{
+		JWT: httpctrl.JWTConfig{
+			HMACSecret:    c.JWT.HMACSecret,
+			PublicKeyFile: c.JWT.PublicKeyFile,
+			Issuer:        c.JWT.Issuer,
+			Audience:      c.JWT.Audience,
+		},
+	}
+	for _, k := range c.APIKeys {
+		auth.APIKeys = append(auth.APIKeys, httpctrl.APIKey{Name: k.Name, Key: k.Key, Scope: httpctrl.Scope(k.Scope)})
+	}
+	for _, u := range c.Basic {
+		auth.Users = append(auth.Users, httpctrl.BasicUser{Username: u.Username, Password: u.Password, Scope: httpctrl.Scope(u.Scope)})
+	}
+	return auth
+}
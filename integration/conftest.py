@@ -168,7 +168,20 @@ def knx_tmk_application(tmk_run):
 
 @pytest.fixture(scope="module")
 def mqtt_tmk_application(tmk_run):
-    with tmk_run(controller="mqtt", addr="localhost:1883"):
+    # By default thermocktat runs its own broker. Set TMK_TEST_MQTT_BROKER
+    # (e.g. localhost:1883) to test against an external one such as mosquitto.
+    broker = os.environ.get("TMK_TEST_MQTT_BROKER")
+    if broker:
+        extra_env = {}
+        if version := os.environ.get("TMK_TEST_MQTT_PROTOCOL_VERSION"):
+            extra_env["TMK_CONTROLLERS_MQTT_PROTOCOL_VERSION"] = version
+        with tmk_run(controller="mqtt", addr=broker, extra_env=extra_env):
+            yield
+        return
+    with tmk_run(
+        controller="mqtt",
+        extra_env={"TMK_CONTROLLERS_MQTT_EMBEDDED_BROKER_ADDR": ":1883"},
+    ):
         yield
 
 
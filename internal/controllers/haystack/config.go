@@ -0,0 +1,12 @@
+package haystackctrl
+
+import "time"
+
+// Config holds the Haystack controller configuration.
+type Config struct {
+	DeviceID       string
+	Addr           string
+	SampleInterval time.Duration // how often his points are sampled for hisRead
+	HistorySize    int           // samples kept per his point
+	WatchLease     time.Duration // default lease of a watch without polls
+}
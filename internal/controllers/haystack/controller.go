@@ -0,0 +1,293 @@
+package haystackctrl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+)
+
+const (
+	mimeZinc = "text/zinc"
+	mimeJSON = "application/json"
+
+	maxRequestBody = 1 << 20
+)
+
+// Controller serves the Project Haystack HTTP API under /api/.
+type Controller struct {
+	svc     thermostat.Service
+	cfg     Config
+	log     *slog.Logger
+	srv     *http.Server
+	equipID string
+	booted  time.Time
+	now     func() time.Time
+
+	mu         sync.Mutex
+	priorities map[string]*priorityArray
+	history    map[string]*hisBuffer
+	watches    map[string]*watch
+}
+
+// New returns a runnable controller.
+func New(svc thermostat.Service, cfg Config, logger *slog.Logger) (*Controller, error) {
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	if cfg.Addr == "" {
+		cfg.Addr = ":8081"
+	}
+	if cfg.SampleInterval <= 0 {
+		cfg.SampleInterval = time.Minute
+	}
+	if cfg.HistorySize <= 0 {
+		cfg.HistorySize = 1440
+	}
+	if cfg.WatchLease <= 0 {
+		cfg.WatchLease = 5 * time.Minute
+	}
+
+	c := &Controller{
+		svc:        svc,
+		cfg:        cfg,
+		log:        logger,
+		equipID:    refID(cfg.DeviceID),
+		booted:     time.Now(),
+		now:        time.Now,
+		priorities: map[string]*priorityArray{},
+		history:    map[string]*hisBuffer{},
+		watches:    map[string]*watch{},
+	}
+	for _, p := range points {
+		if p.writable() {
+			c.priorities[p.attr] = &priorityArray{}
+		}
+		if p.his {
+			c.history[p.attr] = &hisBuffer{size: cfg.HistorySize}
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/{op}", c.handleOp)
+	c.srv = &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	return c, nil
+}
+
+// Run serves HTTP and samples history until ctx is cancelled.
+func (c *Controller) Run(ctx context.Context) error {
+	c.srv.BaseContext = func(net.Listener) context.Context { return ctx }
+	go c.sampleLoop(ctx)
+
+	errCh := make(chan error, 1)
+	go func() {
+		if err := c.srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+			return
+		}
+		errCh <- nil
+	}()
+
+	select {
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = c.srv.Shutdown(shutdownCtx)
+		return ctx.Err()
+	case err := <-errCh:
+		return err
+	}
+}
+
+func (c *Controller) sampleLoop(ctx context.Context) {
+	c.sample(c.now())
+	t := time.NewTicker(c.cfg.SampleInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			c.sample(c.now())
+		}
+	}
+}
+
+// op is a Haystack operation; it returns the response grid, or an error that
+// is sent back as an error grid.
+type op struct {
+	summary       string
+	noSideEffects bool // may be called with GET
+	fn            func(c *Controller, req Grid) (Grid, error)
+}
+
+// ops is filled in init to break the initialization cycle with opOps.
+var ops map[string]op
+
+// opNames lists ops in the order of the ops op.
+var opNames = []string{"about", "ops", "formats", "read", "nav", "pointWrite", "hisRead", "watchSub", "watchUnsub", "watchPoll"}
+
+func init() {
+	ops = map[string]op{
+		"about":      {"Summary information for server", true, (*Controller).opAbout},
+		"ops":        {"Operations supported by this server", true, (*Controller).opOps},
+		"formats":    {"Grid data formats supported by this server", true, (*Controller).opFormats},
+		"read":       {"Read entity records by id or filter", true, (*Controller).opRead},
+		"nav":        {"Navigate the equip and point tree", true, (*Controller).opNav},
+		"pointWrite": {"Read or write a writable point priority array", false, (*Controller).opPointWrite},
+		"hisRead":    {"Read time series history", true, (*Controller).opHisRead},
+		"watchSub":   {"Open or add entities to a watch", false, (*Controller).opWatchSub},
+		"watchUnsub": {"Close a watch or remove entities from it", false, (*Controller).opWatchUnsub},
+		"watchPoll":  {"Poll a watch for changes", false, (*Controller).opWatchPoll},
+	}
+}
+
+func (c *Controller) handleOp(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("op")
+	o, ok := ops[name]
+	if !ok {
+		http.Error(w, "unknown op "+name, http.StatusNotFound)
+		return
+	}
+	format, ok := responseFormat(r.Header.Get("Accept"))
+	if !ok {
+		http.Error(w, "supported formats: "+mimeZinc+", "+mimeJSON, http.StatusNotAcceptable)
+		return
+	}
+
+	var req Grid
+	switch r.Method {
+	case http.MethodGet:
+		if !o.noSideEffects {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, name+" requires POST", http.StatusMethodNotAllowed)
+			return
+		}
+		req = queryGrid(r)
+	case http.MethodPost:
+		var err error
+		if req, err = readGrid(r); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, errUnsupportedMedia) {
+				status = http.StatusUnsupportedMediaType
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, r.Method+" is not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	resp, err := o.fn(c, req)
+	if err != nil {
+		c.log.Debug("op failed", "op", name, "err", err)
+		resp = errorGrid(err)
+	}
+	c.log.Debug("request", "method", r.Method, "op", name, "rows", len(resp.Rows))
+	writeGrid(w, format, resp)
+}
+
+// errorGrid is how op failures are returned: an empty grid tagged err, with
+// the thermostat error code in errCode.
+func errorGrid(err error) Grid {
+	return NewGrid(Dict{
+		"err":     Marker{},
+		"dis":     err.Error(),
+		"errCode": string(thermostat.Code(err)),
+	})
+}
+
+// responseFormat picks Zinc or JSON from an Accept header; Zinc is the
+// default.
+func responseFormat(accept string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return mimeZinc, true
+	}
+	for part := range strings.SplitSeq(accept, ",") {
+		mt, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mt {
+		case mimeZinc, "*/*", "text/*", "text/plain":
+			return mimeZinc, true
+		case mimeJSON, "application/*":
+			return mimeJSON, true
+		}
+	}
+	return "", false
+}
+
+func writeGrid(w http.ResponseWriter, format string, g Grid) {
+	if format == mimeJSON {
+		b, err := EncodeJSON(g)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", mimeJSON)
+		_, _ = w.Write(b)
+		return
+	}
+	w.Header().Set("Content-Type", mimeZinc+"; charset=utf-8")
+	_, _ = io.WriteString(w, EncodeZinc(g))
+}
+
+var errUnsupportedMedia = errors.New("unsupported content type")
+
+// readGrid decodes a POST body; a missing Content-Type means Zinc.
+func readGrid(r *http.Request) (Grid, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxRequestBody))
+	if err != nil {
+		return Grid{}, err
+	}
+	mt := mimeZinc
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if mt, _, err = mime.ParseMediaType(ct); err != nil {
+			return Grid{}, fmt.Errorf("%w: %s", errUnsupportedMedia, ct)
+		}
+	}
+	switch mt {
+	case mimeZinc, "text/plain":
+		return DecodeZinc(string(body))
+	case mimeJSON:
+		return DecodeJSON(body)
+	}
+	return Grid{}, fmt.Errorf("%w: %s", errUnsupportedMedia, mt)
+}
+
+// queryGrid turns GET query parameters into a one-row grid. Values are Zinc
+// scalars; anything that does not parse as one is taken as a Str, so
+// ?filter=point and ?range=today work unquoted.
+func queryGrid(r *http.Request) Grid {
+	row := Dict{}
+	for name, vals := range r.URL.Query() {
+		if len(vals) == 0 {
+			continue
+		}
+		v, err := ParseZincValue(vals[0])
+		if err != nil {
+			v = vals[0]
+		}
+		row[name] = v
+	}
+	if len(row) == 0 {
+		return Grid{}
+	}
+	return NewGrid(nil, row)
+}
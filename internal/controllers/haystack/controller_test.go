@@ -0,0 +1,364 @@
+package haystackctrl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Agrid-Dev/thermocktat/internal/testutil"
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+)
+
+func newTestController(t *testing.T) (*Controller, *testutil.FakeThermostatService) {
+	t.Helper()
+	fake := testutil.NewFakeThermostatService()
+	c, err := New(fake, Config{DeviceID: "dev 1", HistorySize: 3}, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return c, fake
+}
+
+func get(t *testing.T, c *Controller, path string, accept string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rr := httptest.NewRecorder()
+	c.srv.Handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func post(t *testing.T, c *Controller, path, contentType, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	rr := httptest.NewRecorder()
+	c.srv.Handler.ServeHTTP(rr, req)
+	return rr
+}
+
+// decode parses a Zinc response and fails on error grids.
+func decode(t *testing.T, rr *httptest.ResponseRecorder) Grid {
+	t.Helper()
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rr.Code, rr.Body)
+	}
+	g, err := DecodeZinc(rr.Body.String())
+	if err != nil {
+		t.Fatalf("decode %q: %v", rr.Body, err)
+	}
+	if isMarker(g.Meta["err"]) {
+		t.Fatalf("error grid: %v (%v)", g.Meta["dis"], g.Meta["errCode"])
+	}
+	return g
+}
+
+// decodeError parses an error grid and returns its errCode.
+func decodeError(t *testing.T, rr *httptest.ResponseRecorder) string {
+	t.Helper()
+	g, err := DecodeZinc(rr.Body.String())
+	if err != nil {
+		t.Fatalf("decode %q: %v", rr.Body, err)
+	}
+	if rr.Code != http.StatusOK || !isMarker(g.Meta["err"]) {
+		t.Fatalf("expected an error grid, got %d %s", rr.Code, rr.Body)
+	}
+	code, _ := g.Meta["errCode"].(string)
+	return code
+}
+
+func TestAboutAndOps(t *testing.T) {
+	c, _ := newTestController(t)
+
+	about := decode(t, get(t, c, "/api/about", ""))
+	if v := about.Rows[0]["haystackVersion"]; v != "3.0" {
+		t.Fatalf("haystackVersion = %v", v)
+	}
+	if _, ok := about.Rows[0]["serverTime"].(time.Time); !ok {
+		t.Fatalf("serverTime = %#v", about.Rows[0]["serverTime"])
+	}
+
+	opsGrid := decode(t, get(t, c, "/api/ops", ""))
+	if len(opsGrid.Rows) != len(opNames) {
+		t.Fatalf("ops rows = %d, want %d", len(opsGrid.Rows), len(opNames))
+	}
+
+	formats := decode(t, get(t, c, "/api/formats", ""))
+	if len(formats.Rows) != 2 {
+		t.Fatalf("formats rows = %d", len(formats.Rows))
+	}
+}
+
+func TestRead_Filter(t *testing.T) {
+	c, _ := newTestController(t)
+
+	g := decode(t, get(t, c, "/api/read?filter="+url.QueryEscape("point and sp and temp"), ""))
+	if len(g.Rows) != 3 {
+		t.Fatalf("rows = %d, want 3 setpoint points", len(g.Rows))
+	}
+	sp := g.Rows[0]
+	if sp["id"] != (Ref{ID: "dev_1.temperature_setpoint", Dis: "dev 1 Temperature Setpoint"}) {
+		t.Fatalf("id = %#v", sp["id"])
+	}
+	if sp["curVal"] != (Number{Val: 22, Unit: "°C"}) || sp["minVal"] != (Number{Val: 16, Unit: "°C"}) {
+		t.Fatalf("row = %#v", sp)
+	}
+	for _, tag := range []string{"point", "sp", "zone", "air", "temp", "writable", "his"} {
+		if !isMarker(sp[tag]) {
+			t.Errorf("missing marker %q", tag)
+		}
+	}
+
+	g = decode(t, get(t, c, "/api/read?limit=1&filter="+url.QueryEscape("equipRef->thermostat and sensor"), ""))
+	if len(g.Rows) != 1 || g.Rows[0]["navName"] != "ambient_temperature" {
+		t.Fatalf("rows = %#v", g.Rows)
+	}
+}
+
+func TestRead_IDs_JSON(t *testing.T) {
+	c, _ := newTestController(t)
+
+	body := `{"_kind":"grid","meta":{"ver":"3.0"},"cols":[{"name":"id"}],"rows":[` +
+		`{"id":{"_kind":"ref","val":"dev_1.mode"}},{"id":{"_kind":"ref","val":"nope"}}]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/read", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	rr := httptest.NewRecorder()
+	c.srv.Handler.ServeHTTP(rr, req)
+
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	g, err := DecodeJSON(rr.Body.Bytes())
+	if err != nil {
+		t.Fatalf("DecodeJSON: %v", err)
+	}
+	if len(g.Rows) != 2 || g.Rows[0]["curVal"] != "auto" || len(g.Rows[1]) != 0 {
+		t.Fatalf("rows = %#v", g.Rows)
+	}
+}
+
+func TestNav(t *testing.T) {
+	c, _ := newTestController(t)
+
+	root := decode(t, get(t, c, "/api/nav", ""))
+	if len(root.Rows) != 1 || root.Rows[0]["navId"] != "dev_1" || !isMarker(root.Rows[0]["equip"]) {
+		t.Fatalf("root = %#v", root.Rows)
+	}
+	pts := decode(t, get(t, c, "/api/nav?navId="+url.QueryEscape(`"dev_1"`), ""))
+	if len(pts.Rows) != len(points) {
+		t.Fatalf("points = %d, want %d", len(pts.Rows), len(points))
+	}
+	if code := decodeError(t, get(t, c, "/api/nav?navId=x", "")); code != string(thermostat.CodeInvalidRequest) {
+		t.Fatalf("errCode = %q", code)
+	}
+}
+
+func TestPointWrite_PriorityArray(t *testing.T) {
+	c, fake := newTestController(t)
+	write := func(body string) *httptest.ResponseRecorder {
+		return post(t, c, "/api/pointWrite", "text/zinc", "ver:\"3.0\"\n"+body)
+	}
+
+	decode(t, write("id,level,val,who\n@dev_1.temperature_setpoint,16,20°C,\"bms\"\n"))
+	decode(t, write("id,level,val\n@dev_1.temperature_setpoint,8,24\n"))
+	if !fake.ApplyPatchCalled || fake.S.TemperatureSetpoint != 24 {
+		t.Fatalf("setpoint = %v, want 24", fake.S.TemperatureSetpoint)
+	}
+
+	g := decode(t, write("id\n@dev_1.temperature_setpoint\n"))
+	if len(g.Rows) != priorityLevels {
+		t.Fatalf("levels = %d", len(g.Rows))
+	}
+	if g.Rows[15]["val"] != (Number{Val: 20, Unit: "°C"}) || g.Rows[15]["who"] != "bms" {
+		t.Fatalf("level 16 = %#v", g.Rows[15])
+	}
+	if g.Rows[7]["levelDis"] != "Manual Override" {
+		t.Fatalf("level 8 = %#v", g.Rows[7])
+	}
+
+	// Releasing level 8 falls back to level 16.
+	decode(t, write("id,level\n@dev_1.temperature_setpoint,8\n"))
+	if fake.S.TemperatureSetpoint != 20 {
+		t.Fatalf("setpoint = %v, want 20", fake.S.TemperatureSetpoint)
+	}
+
+	read := decode(t, get(t, c, "/api/read?id=@dev_1.temperature_setpoint", ""))
+	if read.Rows[0]["writeLevel"] != (Number{Val: 16}) {
+		t.Fatalf("writeLevel = %#v", read.Rows[0]["writeLevel"])
+	}
+
+	decode(t, write("id,level,val\n@dev_1.mode,8,\"heat\"\n"))
+	if fake.S.Mode != thermostat.ModeHeat {
+		t.Fatalf("mode = %v", fake.S.Mode)
+	}
+}
+
+func TestPointWrite_Errors(t *testing.T) {
+	c, fake := newTestController(t)
+	tests := []struct {
+		name string
+		body string
+		want thermostat.ErrorCode
+	}{
+		{"read-only point", "id,level,val\n@dev_1.ambient_temperature,8,20\n", thermostat.CodeInvalidRequest},
+		{"bad level", "id,level,val\n@dev_1.enabled,18,T\n", thermostat.CodeInvalidRequest},
+		{"wrong kind", "id,level,val\n@dev_1.enabled,8,1\n", thermostat.CodeInvalidRequest},
+		{"wrong unit", "id,level,val\n@dev_1.temperature_setpoint,8,70°F\n", thermostat.CodeInvalidRequest},
+		{"invalid mode", "id,level,val\n@dev_1.mode,8,\"dry\"\n", thermostat.CodeInvalidMode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := post(t, c, "/api/pointWrite", "text/zinc", "ver:\"3.0\"\n"+tt.body)
+			if code := decodeError(t, rr); code != string(tt.want) {
+				t.Fatalf("errCode = %q, want %q", code, tt.want)
+			}
+		})
+	}
+
+	// A rejected write leaves the priority array unchanged.
+	fake.ApplyPatchErr = thermostat.ErrSetpointOutOfRange
+	rr := post(t, c, "/api/pointWrite", "text/zinc", "ver:\"3.0\"\nid,level,val\n@dev_1.temperature_setpoint,8,40\n")
+	if code := decodeError(t, rr); code != string(thermostat.CodeSetpointOutOfRange) {
+		t.Fatalf("errCode = %q", code)
+	}
+	if _, _, ok := c.priorities["temperature_setpoint"].effective(); ok {
+		t.Fatal("rejected write was kept in the priority array")
+	}
+}
+
+func TestHisRead(t *testing.T) {
+	c, fake := newTestController(t)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	for i := range 4 {
+		fake.S.AmbientTemperature = float64(20 + i)
+		c.sample(now.Add(time.Duration(i-3) * time.Hour))
+	}
+
+	g := decode(t, get(t, c, "/api/hisRead?id=@dev_1.ambient_temperature&range=today", ""))
+	// HistorySize is 3: the first sample was dropped.
+	if len(g.Rows) != 3 {
+		t.Fatalf("rows = %d, want 3", len(g.Rows))
+	}
+	if g.Rows[0]["val"] != (Number{Val: 21, Unit: "°C"}) || !g.Rows[2]["ts"].(time.Time).Equal(now) {
+		t.Fatalf("rows = %#v", g.Rows)
+	}
+	if g.Meta["id"].(Ref).ID != "dev_1.ambient_temperature" {
+		t.Fatalf("meta = %#v", g.Meta)
+	}
+
+	rng := url.QueryEscape("2024-03-01T10:30:00Z UTC,2024-03-01T11:30:00Z UTC")
+	g = decode(t, get(t, c, "/api/hisRead?id=@dev_1.ambient_temperature&range="+rng, ""))
+	if len(g.Rows) != 1 || g.Rows[0]["val"] != (Number{Val: 22, Unit: "°C"}) {
+		t.Fatalf("rows = %#v", g.Rows)
+	}
+
+	g = decode(t, get(t, c, "/api/hisRead?id=@dev_1.ambient_temperature&range=yesterday", ""))
+	if len(g.Rows) != 0 {
+		t.Fatalf("yesterday rows = %d", len(g.Rows))
+	}
+
+	for _, q := range []string{
+		"id=@dev_1.mode&range=today",
+		"id=@dev_1.ambient_temperature&range=soon",
+		"id=@dev_1.ambient_temperature",
+	} {
+		if code := decodeError(t, get(t, c, "/api/hisRead?"+q, "")); code != string(thermostat.CodeInvalidRequest) {
+			t.Errorf("%s: errCode = %q", q, code)
+		}
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		in         any
+		start, end time.Time
+	}{
+		{"today", day(1), day(2)},
+		{"yesterday", day(0), day(1)},
+		{"2024-02-10", time.Date(2024, 2, 10, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 11, 0, 0, 0, 0, time.UTC)},
+		{"2024-02-28,2024-03-01", time.Date(2024, 2, 28, 0, 0, 0, 0, time.UTC), day(2)},
+		{Date{2024, time.March, 1}, day(1), day(2)},
+	}
+	for _, tt := range tests {
+		start, end, err := parseRange(tt.in, now)
+		if err != nil {
+			t.Errorf("parseRange(%v): %v", tt.in, err)
+			continue
+		}
+		if !start.Equal(tt.start) || !end.Equal(tt.end) {
+			t.Errorf("parseRange(%v) = [%v, %v), want [%v, %v)", tt.in, start, end, tt.start, tt.end)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	c, fake := newTestController(t)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	sub := decode(t, post(t, c, "/api/watchSub", "text/zinc",
+		"ver:\"3.0\" watchDis:\"test\" lease:1min\nid\n@dev_1.ambient_temperature\n@dev_1.mode\n@nope\n"))
+	id, _ := sub.Meta["watchId"].(string)
+	if id == "" || sub.Meta["lease"] != (Number{Val: 60, Unit: "s"}) {
+		t.Fatalf("meta = %#v", sub.Meta)
+	}
+	if len(sub.Rows) != 3 || sub.Rows[0]["curVal"] != (Number{Val: 21, Unit: "°C"}) || len(sub.Rows[2]) != 0 {
+		t.Fatalf("rows = %#v", sub.Rows)
+	}
+
+	poll := func(meta string) Grid {
+		return decode(t, post(t, c, "/api/watchPoll", "text/zinc", "ver:\"3.0\" watchId:\""+id+"\""+meta+"\nempty\n"))
+	}
+	if g := poll(""); len(g.Rows) != 0 {
+		t.Fatalf("unchanged poll rows = %#v", g.Rows)
+	}
+	fake.S.Mode = thermostat.ModeCool
+	if g := poll(""); len(g.Rows) != 1 || g.Rows[0]["curVal"] != "cool" {
+		t.Fatalf("changed poll rows = %#v", g.Rows)
+	}
+	if g := poll(" refresh"); len(g.Rows) != 2 {
+		t.Fatalf("refresh poll rows = %d, want 2", len(g.Rows))
+	}
+
+	decode(t, post(t, c, "/api/watchUnsub", "text/zinc", "ver:\"3.0\" watchId:\""+id+"\"\nid\n@dev_1.mode\n"))
+	if g := poll(" refresh"); len(g.Rows) != 1 {
+		t.Fatalf("rows after unsub = %d, want 1", len(g.Rows))
+	}
+
+	// The lease expires without polls.
+	now = now.Add(2 * time.Minute)
+	rr := post(t, c, "/api/watchPoll", "text/zinc", "ver:\"3.0\" watchId:\""+id+"\"\nempty\n")
+	if code := decodeError(t, rr); code != string(thermostat.CodeInvalidRequest) {
+		t.Fatalf("errCode = %q", code)
+	}
+}
+
+func TestHTTPErrors(t *testing.T) {
+	c, _ := newTestController(t)
+	tests := []struct {
+		name   string
+		rr     *httptest.ResponseRecorder
+		status int
+	}{
+		{"unknown op", get(t, c, "/api/bogus", ""), http.StatusNotFound},
+		{"GET with side effects", get(t, c, "/api/pointWrite", ""), http.StatusMethodNotAllowed},
+		{"unsupported Accept", get(t, c, "/api/about", "text/csv"), http.StatusNotAcceptable},
+		{"unsupported body", post(t, c, "/api/read", "text/csv", "id\n"), http.StatusUnsupportedMediaType},
+		{"malformed Zinc", post(t, c, "/api/read", "text/zinc", "nope"), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if tt.rr.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, tt.rr.Code, tt.status)
+		}
+	}
+}
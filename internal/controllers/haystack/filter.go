@@ -0,0 +1,283 @@
+package haystackctrl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Filter is a parsed Haystack filter
+// (https://project-haystack.org/doc/docHaystack/Filters). Paths ("a->b") are
+// resolved through Ref tags with the lookup passed to Match.
+type Filter interface {
+	Match(d Dict, lookup func(Ref) Dict) bool
+}
+
+type (
+	hasFilter struct{ path []string }
+	notFilter struct{ path []string }
+	cmpFilter struct {
+		path []string
+		op   string
+		val  any
+	}
+	andFilter struct{ a, b Filter }
+	orFilter  struct{ a, b Filter }
+)
+
+func (f hasFilter) Match(d Dict, lookup func(Ref) Dict) bool {
+	return resolve(d, f.path, lookup) != nil
+}
+
+func (f notFilter) Match(d Dict, lookup func(Ref) Dict) bool {
+	return resolve(d, f.path, lookup) == nil
+}
+
+func (f andFilter) Match(d Dict, lookup func(Ref) Dict) bool {
+	return f.a.Match(d, lookup) && f.b.Match(d, lookup)
+}
+
+func (f orFilter) Match(d Dict, lookup func(Ref) Dict) bool {
+	return f.a.Match(d, lookup) || f.b.Match(d, lookup)
+}
+
+func (f cmpFilter) Match(d Dict, lookup func(Ref) Dict) bool {
+	v := resolve(d, f.path, lookup)
+	if v == nil {
+		return false
+	}
+	c, ok := compare(v, f.val)
+	if !ok {
+		return false
+	}
+	switch f.op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default: // ">="
+		return c >= 0
+	}
+}
+
+func resolve(d Dict, path []string, lookup func(Ref) Dict) any {
+	v := d[path[0]]
+	for _, name := range path[1:] {
+		ref, ok := v.(Ref)
+		if !ok || lookup == nil {
+			return nil
+		}
+		next := lookup(ref)
+		if next == nil {
+			return nil
+		}
+		v = next[name]
+	}
+	return v
+}
+
+// compare orders two values of the same kind; ok is false when they are not
+// comparable (different kinds or units).
+func compare(a, b any) (c int, ok bool) {
+	cmp := func(less, equal bool) int {
+		switch {
+		case equal:
+			return 0
+		case less:
+			return -1
+		}
+		return 1
+	}
+	switch a := a.(type) {
+	case Number:
+		b, ok := b.(Number)
+		if !ok || a.Unit != b.Unit && a.Unit != "" && b.Unit != "" {
+			return 0, false
+		}
+		return cmp(a.Val < b.Val, a.Val == b.Val), true
+	case string:
+		b, ok := b.(string)
+		return strings.Compare(a, b), ok
+	case bool:
+		b, ok := b.(bool)
+		return cmp(!a && b, a == b), ok
+	case Ref:
+		b, ok := b.(Ref)
+		return strings.Compare(a.ID, b.ID), ok
+	case Uri:
+		b, ok := b.(Uri)
+		return strings.Compare(string(a), string(b)), ok
+	case Date:
+		b, ok := b.(Date)
+		return strings.Compare(a.String(), b.String()), ok
+	case time.Time:
+		b, ok := b.(time.Time)
+		return a.Compare(b), ok
+	}
+	return 0, false
+}
+
+// ParseFilter parses a filter expression such as
+// `point and temp and (sp or sensor) and curVal > 20°C`.
+func ParseFilter(s string) (Filter, error) {
+	p := &filterParser{s: s}
+	f, err := p.or()
+	if err != nil {
+		return nil, fmt.Errorf("filter: %w", err)
+	}
+	p.skipSpaces()
+	if p.pos < len(p.s) {
+		return nil, fmt.Errorf("filter: unexpected %q", p.s[p.pos:])
+	}
+	return f, nil
+}
+
+type filterParser struct {
+	s   string
+	pos int
+}
+
+func (p *filterParser) skipSpaces() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// keyword consumes kw when it is the next whole word.
+func (p *filterParser) keyword(kw string) bool {
+	p.skipSpaces()
+	rest := p.s[p.pos:]
+	if !strings.HasPrefix(rest, kw) {
+		return false
+	}
+	if len(rest) > len(kw) && isNameChar(rest[len(kw)]) {
+		return false
+	}
+	p.pos += len(kw)
+	return true
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func (p *filterParser) or() (Filter, error) {
+	f, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		g, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		f = orFilter{f, g}
+	}
+	return f, nil
+}
+
+func (p *filterParser) and() (Filter, error) {
+	f, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		g, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		f = andFilter{f, g}
+	}
+	return f, nil
+}
+
+func (p *filterParser) term() (Filter, error) {
+	p.skipSpaces()
+	if p.pos < len(p.s) && p.s[p.pos] == '(' {
+		p.pos++
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		if p.pos >= len(p.s) || p.s[p.pos] != ')' {
+			return nil, errors.New("missing )")
+		}
+		p.pos++
+		return f, nil
+	}
+	if p.keyword("not") {
+		path, err := p.path()
+		if err != nil {
+			return nil, err
+		}
+		return notFilter{path}, nil
+	}
+	path, err := p.path()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if strings.HasPrefix(p.s[p.pos:], op) {
+			p.pos += len(op)
+			val, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			return cmpFilter{path: path, op: op, val: val}, nil
+		}
+	}
+	return hasFilter{path}, nil
+}
+
+func (p *filterParser) path() ([]string, error) {
+	var path []string
+	for {
+		p.skipSpaces()
+		start := p.pos
+		for p.pos < len(p.s) && isNameChar(p.s[p.pos]) {
+			p.pos++
+		}
+		if p.pos == start {
+			if p.pos >= len(p.s) {
+				return nil, errors.New("expected a tag name, got end of input")
+			}
+			return nil, fmt.Errorf("expected a tag name at %q", p.s[p.pos:])
+		}
+		path = append(path, p.s[start:p.pos])
+		if !strings.HasPrefix(p.s[p.pos:], "->") {
+			return path, nil
+		}
+		p.pos += len("->")
+	}
+}
+
+// value parses a comparison operand: true, false, or a Zinc scalar.
+func (p *filterParser) value() (any, error) {
+	if p.keyword("true") {
+		return true, nil
+	}
+	if p.keyword("false") {
+		return false, nil
+	}
+	p.skipSpaces()
+	zp := &zincParser{s: p.s, pos: p.pos}
+	v, err := zp.value()
+	if err != nil {
+		return nil, err
+	}
+	p.pos = zp.pos
+	switch v.(type) {
+	case nil, Marker, Remove, NA:
+		return nil, fmt.Errorf("cannot compare to %s", ZincValue(v))
+	}
+	return v, nil
+}
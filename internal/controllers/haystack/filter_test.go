@@ -0,0 +1,64 @@
+package haystackctrl
+
+import "testing"
+
+func TestFilter_Match(t *testing.T) {
+	equip := Dict{"id": Ref{ID: "dev"}, "equip": Marker{}, "dis": "Living room"}
+	sp := Dict{
+		"id":       Ref{ID: "dev.sp"},
+		"point":    Marker{},
+		"sp":       Marker{},
+		"temp":     Marker{},
+		"curVal":   Number{Val: 22, Unit: "°C"},
+		"kind":     "Number",
+		"equipRef": Ref{ID: "dev"},
+	}
+	lookup := func(r Ref) Dict {
+		if r.ID == "dev" {
+			return equip
+		}
+		return nil
+	}
+
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{"point", true},
+		{"equip", false},
+		{"not equip", true},
+		{"point and sp and temp", true},
+		{"point and (sensor or sp)", true},
+		{"sensor or equip", false},
+		{"curVal > 21°C", true},
+		{"curVal >= 22", true},
+		{"curVal < 22°C", false},
+		{"curVal == 22°F", false},
+		{`kind == "Number"`, true},
+		{`kind != "Number"`, false},
+		{"id == @dev.sp", true},
+		{"equipRef == @dev", true},
+		{`equipRef->dis == "Living room"`, true},
+		{"equipRef->equip", true},
+		{"equipRef->site", false},
+		{"curVal == true", false},
+	}
+	for _, tt := range tests {
+		f, err := ParseFilter(tt.filter)
+		if err != nil {
+			t.Errorf("ParseFilter(%q): %v", tt.filter, err)
+			continue
+		}
+		if got := f.Match(sp, lookup); got != tt.want {
+			t.Errorf("%q matched = %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestParseFilter_Errors(t *testing.T) {
+	for _, s := range []string{"", "point and", "(point", "point)", "curVal >", "curVal == M", "not"} {
+		if _, err := ParseFilter(s); err == nil {
+			t.Errorf("ParseFilter(%q): expected error", s)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package haystackctrl
+
+import (
+	"strings"
+	"time"
+)
+
+type hisItem struct {
+	ts  time.Time
+	val any
+}
+
+// hisBuffer keeps the most recent samples of a point, oldest first.
+type hisBuffer struct {
+	items []hisItem
+	size  int
+}
+
+func (b *hisBuffer) add(it hisItem) {
+	if len(b.items) == b.size {
+		copy(b.items, b.items[1:])
+		b.items = b.items[:len(b.items)-1]
+	}
+	b.items = append(b.items, it)
+}
+
+// between returns the samples in [start, end).
+func (b *hisBuffer) between(start, end time.Time) []hisItem {
+	var out []hisItem
+	for _, it := range b.items {
+		if !it.ts.Before(start) && it.ts.Before(end) {
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+// sample records the current value of every his point.
+func (c *Controller) sample(now time.Time) {
+	s := c.svc.Get()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, p := range points {
+		if p.his {
+			c.history[p.attr].add(hisItem{ts: now.UTC(), val: p.cur(s)})
+		}
+	}
+}
+
+// parseRange resolves a hisRead range to [start, end) in UTC. It accepts
+// "today", "yesterday", a Date, "date,date" (both inclusive), a DateTime
+// (until now) and "dateTime,dateTime".
+func parseRange(v any, now time.Time) (start, end time.Time, err error) {
+	now = now.UTC()
+	today := Date{now.Year(), now.Month(), now.Day()}.Midnight(time.UTC)
+	s, isStr := v.(string)
+	switch {
+	case isStr && s == "today":
+		return today, today.AddDate(0, 0, 1), nil
+	case isStr && s == "yesterday":
+		return today.AddDate(0, 0, -1), today, nil
+	case isStr:
+		first, second, found := strings.Cut(s, ",")
+		from, err := ParseZincValue(strings.TrimSpace(first))
+		if err != nil {
+			return start, end, invalidRequest("invalid range %q", s)
+		}
+		if !found {
+			return parseRange(from, now)
+		}
+		to, err := ParseZincValue(strings.TrimSpace(second))
+		if err != nil {
+			return start, end, invalidRequest("invalid range %q", s)
+		}
+		if start, _, err = parseRange(from, now); err != nil {
+			return start, end, err
+		}
+		switch to := to.(type) {
+		case Date:
+			end = to.Midnight(time.UTC).AddDate(0, 0, 1)
+		case time.Time:
+			end = to
+		default:
+			return start, end, invalidRequest("invalid range %q", s)
+		}
+		return start, end, nil
+	}
+	switch v := v.(type) {
+	case Date:
+		start = v.Midnight(time.UTC)
+		return start, start.AddDate(0, 0, 1), nil
+	case time.Time:
+		// Include a sample taken exactly now.
+		return v, now.Add(time.Nanosecond), nil
+	}
+	return start, end, invalidRequest("invalid range %s", ZincValue(v))
+}
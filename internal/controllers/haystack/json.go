@@ -0,0 +1,233 @@
+package haystackctrl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// JSON follows the Haystack 4 encoding
+// (https://project-haystack.org/doc/docHaystack/Json): Str, Bool and Null map
+// to their JSON counterparts, other kinds to objects tagged with "_kind".
+
+// EncodeJSON writes g as a Haystack 4 JSON grid.
+func EncodeJSON(g Grid) ([]byte, error) {
+	meta := map[string]any{"ver": zincVersion}
+	for k, v := range g.Meta {
+		meta[k] = jsonValue(v)
+	}
+	cols := make([]map[string]any, len(g.Cols))
+	for i, c := range g.Cols {
+		col := map[string]any{"name": c.Name}
+		for k, v := range c.Meta {
+			col[k] = jsonValue(v)
+		}
+		cols[i] = col
+	}
+	rows := make([]map[string]any, len(g.Rows))
+	for i, r := range g.Rows {
+		rows[i] = jsonDict(r)
+	}
+	return json.Marshal(map[string]any{
+		"_kind": "grid",
+		"meta":  meta,
+		"cols":  cols,
+		"rows":  rows,
+	})
+}
+
+func jsonDict(d Dict) map[string]any {
+	out := make(map[string]any, len(d))
+	for k, v := range d {
+		if v != nil {
+			out[k] = jsonValue(v)
+		}
+	}
+	return out
+}
+
+func jsonValue(v any) any {
+	switch v := v.(type) {
+	case nil, bool, string:
+		return v
+	case Marker:
+		return map[string]any{"_kind": "marker"}
+	case Remove:
+		return map[string]any{"_kind": "remove"}
+	case NA:
+		return map[string]any{"_kind": "na"}
+	case Number:
+		var val any = v.Val
+		switch {
+		case math.IsNaN(v.Val):
+			val = "NaN"
+		case math.IsInf(v.Val, 1):
+			val = "INF"
+		case math.IsInf(v.Val, -1):
+			val = "-INF"
+		case v.Unit == "":
+			return v.Val
+		}
+		n := map[string]any{"_kind": "number", "val": val}
+		if v.Unit != "" {
+			n["unit"] = v.Unit
+		}
+		return n
+	case Ref:
+		r := map[string]any{"_kind": "ref", "val": v.ID}
+		if v.Dis != "" {
+			r["dis"] = v.Dis
+		}
+		return r
+	case Uri:
+		return map[string]any{"_kind": "uri", "val": string(v)}
+	case Date:
+		return map[string]any{"_kind": "date", "val": v.String()}
+	case time.Time:
+		return map[string]any{"_kind": "dateTime", "val": formatDateTime(v), "tz": "UTC"}
+	case Dict:
+		d := jsonDict(v)
+		d["_kind"] = "dict"
+		return d
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// DecodeJSON parses a Haystack 4 JSON grid.
+func DecodeJSON(b []byte) (Grid, error) {
+	var raw struct {
+		Meta map[string]any   `json:"meta"`
+		Cols []map[string]any `json:"cols"`
+		Rows []map[string]any `json:"rows"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return Grid{}, fmt.Errorf("json: %w", err)
+	}
+	var g Grid
+	var err error
+	if g.Meta, err = fromJSONDict(raw.Meta); err != nil {
+		return Grid{}, err
+	}
+	delete(g.Meta, "ver")
+	for _, c := range raw.Cols {
+		name, _ := c["name"].(string)
+		if name == "" {
+			return Grid{}, errors.New("json: column without a name")
+		}
+		delete(c, "name")
+		meta, err := fromJSONDict(c)
+		if err != nil {
+			return Grid{}, err
+		}
+		if len(meta) == 0 {
+			meta = nil
+		}
+		g.Cols = append(g.Cols, Col{Name: name, Meta: meta})
+	}
+	for _, r := range raw.Rows {
+		row, err := fromJSONDict(r)
+		if err != nil {
+			return Grid{}, err
+		}
+		g.Rows = append(g.Rows, row)
+	}
+	return g, nil
+}
+
+func fromJSONDict(m map[string]any) (Dict, error) {
+	d := make(Dict, len(m))
+	for k, raw := range m {
+		if k == "_kind" {
+			continue
+		}
+		v, err := fromJSON(raw)
+		if err != nil {
+			return nil, fmt.Errorf("json: tag %q: %w", k, err)
+		}
+		if v != nil {
+			d[k] = v
+		}
+	}
+	return d, nil
+}
+
+func fromJSON(raw any) (any, error) {
+	switch v := raw.(type) {
+	case nil, bool, string:
+		return v, nil
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return Number{Val: f}, nil
+	case map[string]any:
+		return fromJSONKind(v)
+	}
+	return nil, fmt.Errorf("unsupported value %v", raw)
+}
+
+func fromJSONKind(m map[string]any) (any, error) {
+	kind, _ := m["_kind"].(string)
+	str := func(name string) string { s, _ := m[name].(string); return s }
+	switch kind {
+	case "marker":
+		return Marker{}, nil
+	case "remove":
+		return Remove{}, nil
+	case "na":
+		return NA{}, nil
+	case "number":
+		n := Number{Unit: str("unit")}
+		switch val := m["val"].(type) {
+		case json.Number:
+			f, err := val.Float64()
+			if err != nil {
+				return nil, err
+			}
+			n.Val = f
+		case string:
+			switch val {
+			case "INF":
+				n.Val = math.Inf(1)
+			case "-INF":
+				n.Val = math.Inf(-1)
+			case "NaN":
+				n.Val = math.NaN()
+			default:
+				return nil, fmt.Errorf("invalid number %q", val)
+			}
+		default:
+			return nil, errors.New("number without a val")
+		}
+		return n, nil
+	case "ref":
+		if str("val") == "" {
+			return nil, errors.New("ref without a val")
+		}
+		return Ref{ID: str("val"), Dis: str("dis")}, nil
+	case "uri":
+		return Uri(str("val")), nil
+	case "date":
+		t, err := time.Parse("2006-01-02", str("val"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q", str("val"))
+		}
+		return Date{t.Year(), t.Month(), t.Day()}, nil
+	case "dateTime":
+		t, err := time.Parse(time.RFC3339Nano, str("val"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid dateTime %q", str("val"))
+		}
+		return t, nil
+	case "dict", "":
+		return fromJSONDict(m)
+	}
+	return nil, fmt.Errorf("unsupported kind %q", kind)
+}
@@ -0,0 +1,82 @@
+package haystackctrl
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestJSONGrid_RoundTrip(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	g := Grid{
+		Meta: Dict{"watchId": "w-1"},
+		Cols: []Col{{Name: "id"}, {Name: "val"}, {Name: "ts"}, {Name: "flag"}},
+		Rows: []Dict{
+			{"id": Ref{ID: "a", Dis: "A"}, "val": Number{Val: 21.5, Unit: "°C"}, "ts": ts, "flag": Marker{}},
+			{"id": Ref{ID: "b"}, "val": "heat", "flag": true},
+			{"val": Number{Val: 3}, "ts": Date{2024, time.March, 1}},
+		},
+	}
+	b, err := EncodeJSON(g)
+	if err != nil {
+		t.Fatalf("EncodeJSON: %v", err)
+	}
+	got, err := DecodeJSON(b)
+	if err != nil {
+		t.Fatalf("DecodeJSON: %v", err)
+	}
+	if !got.Rows[0]["ts"].(time.Time).Equal(ts) {
+		t.Fatalf("ts = %v, want %v", got.Rows[0]["ts"], ts)
+	}
+	got.Rows[0]["ts"] = ts
+	if !reflect.DeepEqual(got, g) {
+		t.Fatalf("DecodeJSON = %#v, want %#v", got, g)
+	}
+}
+
+func TestEncodeJSON_Kinds(t *testing.T) {
+	b, err := EncodeJSON(NewGrid(nil, Dict{
+		"m":    Marker{},
+		"n":    Number{Val: 2},
+		"temp": Number{Val: 20, Unit: "°C"},
+		"ref":  Ref{ID: "x"},
+	}))
+	if err != nil {
+		t.Fatalf("EncodeJSON: %v", err)
+	}
+	var doc struct {
+		Kind string           `json:"_kind"`
+		Meta map[string]any   `json:"meta"`
+		Rows []map[string]any `json:"rows"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if doc.Kind != "grid" || doc.Meta["ver"] != "3.0" {
+		t.Fatalf("header = %s", b)
+	}
+	row := doc.Rows[0]
+	want := map[string]any{
+		"m":    map[string]any{"_kind": "marker"},
+		"n":    2.0, // unitless numbers are plain JSON numbers
+		"temp": map[string]any{"_kind": "number", "val": 20.0, "unit": "°C"},
+		"ref":  map[string]any{"_kind": "ref", "val": "x"},
+	}
+	if !reflect.DeepEqual(row, want) {
+		t.Fatalf("row = %#v, want %#v", row, want)
+	}
+}
+
+func TestDecodeJSON_Errors(t *testing.T) {
+	for _, s := range []string{
+		`not json`,
+		`{"cols":[{}],"rows":[]}`,
+		`{"cols":[{"name":"id"}],"rows":[{"id":{"_kind":"ref"}}]}`,
+		`{"cols":[{"name":"v"}],"rows":[{"v":{"_kind":"coord"}}]}`,
+	} {
+		if _, err := DecodeJSON([]byte(s)); err == nil {
+			t.Errorf("DecodeJSON(%s): expected error", s)
+		}
+	}
+}
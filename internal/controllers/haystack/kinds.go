@@ -0,0 +1,110 @@
+package haystackctrl
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Haystack value kinds. Str, Bool and Null are Go string, bool and nil;
+// DateTime is a time.Time.
+type (
+	Marker struct{}
+	Remove struct{}
+	NA     struct{}
+
+	Number struct {
+		Val  float64
+		Unit string
+	}
+
+	Ref struct {
+		ID  string
+		Dis string
+	}
+
+	Uri string
+
+	Date struct {
+		Year  int
+		Month time.Month
+		Day   int
+	}
+)
+
+func (d Date) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
+
+// Midnight returns the start of d in loc.
+func (d Date) Midnight(loc *time.Location) time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, loc)
+}
+
+// Dict is a set of name/value tags.
+type Dict map[string]any
+
+// Col is a grid column.
+type Col struct {
+	Name string
+	Meta Dict
+}
+
+// Grid is the request and response payload of every Haystack op.
+type Grid struct {
+	Meta Dict
+	Cols []Col
+	Rows []Dict
+}
+
+// NewGrid builds a grid whose columns are the union of the row tags: "id"
+// first, then alphabetical.
+func NewGrid(meta Dict, rows ...Dict) Grid {
+	seen := map[string]bool{}
+	var names []string
+	for _, r := range rows {
+		for name := range r {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == "id") != (names[j] == "id") {
+			return names[i] == "id"
+		}
+		return names[i] < names[j]
+	})
+	g := Grid{Meta: meta, Rows: rows}
+	for _, n := range names {
+		g.Cols = append(g.Cols, Col{Name: n})
+	}
+	if len(g.Cols) == 0 {
+		// A grid always has at least one column.
+		g.Cols = []Col{{Name: "empty"}}
+	}
+	return g
+}
+
+// firstRow returns the first row, or an empty dict for an empty grid.
+func (g Grid) firstRow() Dict {
+	if len(g.Rows) == 0 {
+		return Dict{}
+	}
+	return g.Rows[0]
+}
+
+func isMarker(v any) bool {
+	_, ok := v.(Marker)
+	return ok
+}
+
+func numberOf(v any) (Number, bool) {
+	n, ok := v.(Number)
+	if !ok || math.IsNaN(n.Val) {
+		return Number{}, false
+	}
+	return n, true
+}
@@ -0,0 +1,326 @@
+package haystackctrl
+
+import (
+	"math"
+	"os"
+	"sort"
+
+	"github.com/Agrid-Dev/thermocktat/internal/buildinfo"
+)
+
+func (c *Controller) opAbout(Grid) (Grid, error) {
+	host, _ := os.Hostname()
+	return NewGrid(nil, Dict{
+		"haystackVersion": zincVersion,
+		"tz":              "UTC",
+		"serverName":      host,
+		"serverTime":      c.now().UTC(),
+		"serverBootTime":  c.booted.UTC(),
+		"productName":     "thermocktat",
+		"productUri":      Uri("https://github.com/Agrid-Dev/thermocktat"),
+		"productVersion":  buildinfo.Version,
+		"vendorName":      "Agrid",
+		"vendorUri":       Uri("https://github.com/Agrid-Dev"),
+		"moduleName":      "haystack",
+		"moduleVersion":   buildinfo.Version,
+	}), nil
+}
+
+func (c *Controller) opOps(Grid) (Grid, error) {
+	rows := make([]Dict, len(opNames))
+	for i, name := range opNames {
+		rows[i] = Dict{"name": name, "summary": ops[name].summary}
+	}
+	return NewGrid(nil, rows...), nil
+}
+
+func (c *Controller) opFormats(Grid) (Grid, error) {
+	return NewGrid(nil,
+		Dict{"mime": mimeZinc, "receive": Marker{}, "send": Marker{}},
+		Dict{"mime": mimeJSON, "receive": Marker{}, "send": Marker{}},
+	), nil
+}
+
+// opRead reads by filter (with an optional limit) or by a list of ids. An id
+// that does not resolve yields an empty row.
+func (c *Controller) opRead(req Grid) (Grid, error) {
+	s := c.svc.Get()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	row := req.firstRow()
+	if _, ok := row["filter"]; !ok {
+		var rows []Dict
+		for _, r := range req.Rows {
+			ref, ok := r["id"].(Ref)
+			if !ok {
+				return Grid{}, invalidRequest("read requires a filter or id rows")
+			}
+			d := c.entity(ref.ID, s)
+			if d == nil {
+				d = Dict{}
+			}
+			rows = append(rows, d)
+		}
+		if rows == nil {
+			return Grid{}, invalidRequest("read requires a filter or id rows")
+		}
+		return NewGrid(nil, rows...), nil
+	}
+
+	expr, ok := row["filter"].(string)
+	if !ok {
+		return Grid{}, invalidValue(row["filter"], "Str filter")
+	}
+	f, err := ParseFilter(expr)
+	if err != nil {
+		return Grid{}, invalidRequest("%v", err)
+	}
+	limit := math.MaxInt
+	if v, ok := row["limit"]; ok {
+		n, ok := numberOf(v)
+		if !ok || n.Val < 1 {
+			return Grid{}, invalidValue(v, "positive Number limit")
+		}
+		if n.Val < float64(limit) {
+			limit = int(n.Val)
+		}
+	}
+	lookup := func(r Ref) Dict { return c.entity(r.ID, s) }
+	var rows []Dict
+	for _, d := range c.entities(s) {
+		if len(rows) < limit && f.Match(d, lookup) {
+			rows = append(rows, d)
+		}
+	}
+	return NewGrid(nil, rows...), nil
+}
+
+// opNav navigates equip → points. Without navId it returns the equip, whose
+// navId lists its points.
+func (c *Controller) opNav(req Grid) (Grid, error) {
+	s := c.svc.Get()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	navID := req.firstRow()["navId"]
+	if ref, ok := navID.(Ref); ok {
+		navID = ref.ID
+	}
+	switch navID {
+	case nil:
+		equip := c.equipDict()
+		equip["navId"] = c.equipID
+		return NewGrid(nil, equip), nil
+	case c.equipID:
+		var rows []Dict
+		for _, p := range points {
+			rows = append(rows, c.pointDict(p, s))
+		}
+		return NewGrid(nil, rows...), nil
+	default:
+		return Grid{}, invalidRequest("unknown navId %s", ZincValue(navID))
+	}
+}
+
+// opPointWrite returns the priority array of a writable point, or writes one
+// level when "level" is given; a missing val releases it. The effective value
+// is applied to the thermostat, and a rejected write leaves the array
+// unchanged. Releasing every level keeps the last applied value.
+func (c *Controller) opPointWrite(req Grid) (Grid, error) {
+	row := req.firstRow()
+	ref, ok := row["id"].(Ref)
+	if !ok {
+		return Grid{}, invalidRequest("pointWrite requires an id")
+	}
+	p, ok := c.point(ref.ID)
+	if !ok || !p.writable() {
+		return Grid{}, invalidRequest("%s is not a writable point", ZincValue(ref))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	arr := c.priorities[p.attr]
+
+	lv, write := row["level"]
+	if !write {
+		return NewGrid(nil, arr.rows()...), nil
+	}
+	n, ok := numberOf(lv)
+	if !ok || n.Val != math.Trunc(n.Val) || n.Val < 1 || n.Val > priorityLevels {
+		return Grid{}, invalidValue(lv, "level 1-17")
+	}
+	level := int(n.Val)
+
+	next := *arr
+	next[level-1] = prioritySlot{val: row["val"]}
+	if who, ok := row["who"].(string); ok && row["val"] != nil {
+		next[level-1].who = who
+	}
+	if _, slot, ok := next.effective(); ok {
+		patch, err := p.write(slot.val)
+		if err != nil {
+			return Grid{}, err
+		}
+		if err := c.svc.ApplyPatch(patch); err != nil {
+			return Grid{}, err
+		}
+	}
+	*arr = next
+	return NewGrid(nil), nil
+}
+
+// opHisRead returns the samples of a his point within "range".
+func (c *Controller) opHisRead(req Grid) (Grid, error) {
+	row := req.firstRow()
+	ref, ok := row["id"].(Ref)
+	if !ok {
+		return Grid{}, invalidRequest("hisRead requires an id")
+	}
+	p, ok := c.point(ref.ID)
+	if !ok || !p.his {
+		return Grid{}, invalidRequest("%s is not a his point", ZincValue(ref))
+	}
+	rng, ok := row["range"]
+	if !ok {
+		return Grid{}, invalidRequest("hisRead requires a range")
+	}
+	start, end, err := parseRange(rng, c.now())
+	if err != nil {
+		return Grid{}, err
+	}
+
+	c.mu.Lock()
+	items := c.history[p.attr].between(start, end)
+	c.mu.Unlock()
+
+	rows := make([]Dict, len(items))
+	for i, it := range items {
+		rows[i] = Dict{"ts": it.ts, "val": it.val}
+	}
+	g := NewGrid(Dict{
+		"id":       Ref{ID: c.pointID(p), Dis: c.cfg.DeviceID + " " + p.dis},
+		"hisStart": start,
+		"hisEnd":   end,
+	}, rows...)
+	g.Cols = []Col{{Name: "ts"}, {Name: "val"}}
+	return g, nil
+}
+
+// opWatchSub opens a watch (watchDis) or adds to one (watchId) and returns
+// the current state of the subscribed entities.
+func (c *Controller) opWatchSub(req Grid) (Grid, error) {
+	now := c.now()
+	s := c.svc.Get()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.expireWatches(now)
+
+	var (
+		id string
+		w  *watch
+	)
+	if v, ok := req.Meta["watchId"]; ok {
+		id, _ = v.(string)
+		if w = c.watches[id]; w == nil {
+			return Grid{}, invalidRequest("unknown watch %s", ZincValue(v))
+		}
+	} else {
+		dis, ok := req.Meta["watchDis"].(string)
+		if !ok {
+			return Grid{}, invalidRequest("watchSub requires watchDis or watchId")
+		}
+		id = newWatchID()
+		w = &watch{dis: dis, lease: c.cfg.WatchLease, sent: map[string]string{}}
+		c.watches[id] = w
+		c.log.Debug("watch opened", "watch_id", id, "dis", dis)
+	}
+	if v, ok := req.Meta["lease"]; ok {
+		lease, err := leaseDuration(v)
+		if err != nil {
+			return Grid{}, err
+		}
+		w.lease = lease
+	}
+	w.touch(now)
+
+	rows := make([]Dict, 0, len(req.Rows))
+	for _, r := range req.Rows {
+		ref, ok := r["id"].(Ref)
+		if !ok {
+			return Grid{}, invalidRequest("watchSub rows require an id")
+		}
+		d := c.entity(ref.ID, s)
+		if d == nil {
+			rows = append(rows, Dict{})
+			continue
+		}
+		w.sent[ref.ID] = fingerprint(d)
+		rows = append(rows, d)
+	}
+	return NewGrid(Dict{
+		"watchId": id,
+		"lease":   Number{Val: w.lease.Seconds(), Unit: "s"},
+	}, rows...), nil
+}
+
+// opWatchUnsub closes a watch (close marker) or removes the listed ids.
+func (c *Controller) opWatchUnsub(req Grid) (Grid, error) {
+	id, ok := req.Meta["watchId"].(string)
+	if !ok {
+		return Grid{}, invalidRequest("watchUnsub requires a watchId")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := c.watches[id]
+	if w == nil {
+		return NewGrid(nil), nil
+	}
+	if isMarker(req.Meta["close"]) {
+		delete(c.watches, id)
+		c.log.Debug("watch closed", "watch_id", id, "dis", w.dis)
+		return NewGrid(nil), nil
+	}
+	for _, r := range req.Rows {
+		if ref, ok := r["id"].(Ref); ok {
+			delete(w.sent, ref.ID)
+		}
+	}
+	return NewGrid(nil), nil
+}
+
+// opWatchPoll returns the watched entities that changed since the last poll,
+// or all of them with the refresh marker.
+func (c *Controller) opWatchPoll(req Grid) (Grid, error) {
+	now := c.now()
+	id, ok := req.Meta["watchId"].(string)
+	if !ok {
+		return Grid{}, invalidRequest("watchPoll requires a watchId")
+	}
+	s := c.svc.Get()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.expireWatches(now)
+	w := c.watches[id]
+	if w == nil {
+		return Grid{}, invalidRequest("unknown or expired watch %q", id)
+	}
+	w.touch(now)
+
+	refresh := isMarker(req.Meta["refresh"])
+	ids := make([]string, 0, len(w.sent))
+	for eid := range w.sent {
+		ids = append(ids, eid)
+	}
+	sort.Strings(ids)
+	var rows []Dict
+	for _, eid := range ids {
+		d := c.entity(eid, s)
+		fp := fingerprint(d)
+		if refresh || fp != w.sent[eid] {
+			w.sent[eid] = fp
+			rows = append(rows, d)
+		}
+	}
+	return NewGrid(Dict{"watchId": id}, rows...), nil
+}
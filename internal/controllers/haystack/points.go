@@ -0,0 +1,250 @@
+package haystackctrl
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+)
+
+const unitCelsius = "°C"
+
+// point models one thermostat attribute as a Haystack point of the equip.
+type point struct {
+	attr    string   // thermostat attribute, also the navName and id suffix
+	dis     string   // display name, prefixed with the device id
+	kind    string   // Haystack point kind: Number, Bool or Str
+	unit    string   // Number points only
+	markers []string // semantic tags, besides "point"
+	enum    string   // Bool and Str points: the accepted values
+	his     bool     // sampled and served by hisRead
+
+	cur   func(thermostat.Snapshot) any
+	extra func(thermostat.Snapshot) Dict // dynamic tags, e.g. minVal/maxVal
+
+	// write turns a value into a patch; nil for read-only points.
+	write func(v any) (thermostat.Patch, error)
+}
+
+func (p point) writable() bool { return p.write != nil }
+
+var points = []point{
+	{
+		attr: "ambient_temperature", dis: "Ambient Temperature",
+		kind: "Number", unit: unitCelsius,
+		markers: []string{"sensor", "zone", "air", "temp"},
+		his:     true,
+		cur:     func(s thermostat.Snapshot) any { return Number{s.AmbientTemperature, unitCelsius} },
+	},
+	{
+		attr: "temperature_setpoint", dis: "Temperature Setpoint",
+		kind: "Number", unit: unitCelsius,
+		markers: []string{"sp", "zone", "air", "temp"},
+		his:     true,
+		cur:     func(s thermostat.Snapshot) any { return Number{s.TemperatureSetpoint, unitCelsius} },
+		extra: func(s thermostat.Snapshot) Dict {
+			return Dict{
+				"minVal": Number{s.TemperatureSetpointMin, unitCelsius},
+				"maxVal": Number{s.TemperatureSetpointMax, unitCelsius},
+			}
+		},
+		write: func(v any) (thermostat.Patch, error) {
+			f, err := temperatureValue(v)
+			return thermostat.Patch{TemperatureSetpoint: &f}, err
+		},
+	},
+	{
+		attr: "temperature_setpoint_min", dis: "Temperature Setpoint Min",
+		kind: "Number", unit: unitCelsius,
+		markers: []string{"sp", "zone", "air", "temp"},
+		cur:     func(s thermostat.Snapshot) any { return Number{s.TemperatureSetpointMin, unitCelsius} },
+		write: func(v any) (thermostat.Patch, error) {
+			f, err := temperatureValue(v)
+			return thermostat.Patch{TemperatureSetpointMin: &f}, err
+		},
+	},
+	{
+		attr: "temperature_setpoint_max", dis: "Temperature Setpoint Max",
+		kind: "Number", unit: unitCelsius,
+		markers: []string{"sp", "zone", "air", "temp"},
+		cur:     func(s thermostat.Snapshot) any { return Number{s.TemperatureSetpointMax, unitCelsius} },
+		write: func(v any) (thermostat.Patch, error) {
+			f, err := temperatureValue(v)
+			return thermostat.Patch{TemperatureSetpointMax: &f}, err
+		},
+	},
+	{
+		attr: "enabled", dis: "Enabled",
+		kind: "Bool", enum: "off,on",
+		markers: []string{"cmd", "enable"},
+		cur:     func(s thermostat.Snapshot) any { return s.Enabled },
+		write: func(v any) (thermostat.Patch, error) {
+			b, ok := v.(bool)
+			if !ok {
+				return thermostat.Patch{}, invalidValue(v, "Bool")
+			}
+			return thermostat.Patch{Enabled: &b}, nil
+		},
+	},
+	{
+		attr: "mode", dis: "Mode",
+		kind: "Str", enum: "heat,cool,fan,auto",
+		markers: []string{"cmd", "hvacMode"},
+		cur:     func(s thermostat.Snapshot) any { return s.Mode.String() },
+		write: func(v any) (thermostat.Patch, error) {
+			s, ok := v.(string)
+			if !ok {
+				return thermostat.Patch{}, invalidValue(v, "Str")
+			}
+			m, err := thermostat.ParseMode(s)
+			return thermostat.Patch{Mode: &m}, err
+		},
+	},
+	{
+		attr: "fan_speed", dis: "Fan Speed",
+		kind: "Str", enum: "auto,low,medium,high",
+		markers: []string{"cmd", "fan", "speed"},
+		cur:     func(s thermostat.Snapshot) any { return s.FanSpeed.String() },
+		write: func(v any) (thermostat.Patch, error) {
+			s, ok := v.(string)
+			if !ok {
+				return thermostat.Patch{}, invalidValue(v, "Str")
+			}
+			f, err := thermostat.ParseFanSpeed(s)
+			return thermostat.Patch{FanSpeed: &f}, err
+		},
+	},
+	{
+		attr: "fault_code", dis: "Fault Code",
+		kind:    "Number",
+		markers: []string{"cmd", "fault"},
+		his:     true,
+		cur:     func(s thermostat.Snapshot) any { return Number{Val: float64(s.FaultCode)} },
+		write: func(v any) (thermostat.Patch, error) {
+			n, ok := numberOf(v)
+			if !ok || n.Unit != "" || n.Val != math.Trunc(n.Val) || math.IsInf(n.Val, 0) {
+				return thermostat.Patch{}, invalidValue(v, "unitless integer Number")
+			}
+			code := int(n.Val)
+			return thermostat.Patch{FaultCode: &code}, nil
+		},
+	},
+}
+
+// temperatureValue accepts a Number in °C or without a unit.
+func temperatureValue(v any) (float64, error) {
+	n, ok := numberOf(v)
+	if !ok || n.Unit != "" && n.Unit != unitCelsius || math.IsInf(n.Val, 0) {
+		return 0, invalidValue(v, "Number in "+unitCelsius)
+	}
+	return n.Val, nil
+}
+
+func invalidValue(v any, want string) error {
+	return invalidRequest("invalid value %s: expected %s", ZincValue(v), want)
+}
+
+// invalidRequest is a malformed op argument; it shows as invalid_request in
+// error grids.
+func invalidRequest(format string, args ...any) error {
+	return &thermostat.Error{Code: thermostat.CodeInvalidRequest, Message: fmt.Sprintf(format, args...)}
+}
+
+// refID turns a device id into a valid Ref id: characters outside
+// [A-Za-z0-9_:.~-] become underscores.
+func refID(s string) string {
+	if s == "" {
+		return "thermostat"
+	}
+	return strings.Map(func(r rune) rune {
+		if r < 0x80 && isRefChar(byte(r)) {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// equipDict is the entity grouping all points.
+func (c *Controller) equipDict() Dict {
+	return Dict{
+		"id":         Ref{ID: c.equipID, Dis: c.cfg.DeviceID},
+		"dis":        c.cfg.DeviceID,
+		"navName":    c.cfg.DeviceID,
+		"equip":      Marker{},
+		"thermostat": Marker{},
+	}
+}
+
+func (c *Controller) pointID(p point) string { return c.equipID + "." + p.attr }
+
+// pointDict is the entity of p in state s. Callers hold c.mu.
+func (c *Controller) pointDict(p point, s thermostat.Snapshot) Dict {
+	dis := c.cfg.DeviceID + " " + p.dis
+	d := Dict{
+		"id":        Ref{ID: c.pointID(p), Dis: dis},
+		"dis":       dis,
+		"navName":   p.attr,
+		"point":     Marker{},
+		"kind":      p.kind,
+		"equipRef":  Ref{ID: c.equipID, Dis: c.cfg.DeviceID},
+		"tz":        "UTC",
+		"cur":       Marker{},
+		"curVal":    p.cur(s),
+		"curStatus": "ok",
+	}
+	for _, m := range p.markers {
+		d[m] = Marker{}
+	}
+	if p.unit != "" {
+		d["unit"] = p.unit
+	}
+	if p.enum != "" {
+		d["enum"] = p.enum
+	}
+	if p.his {
+		d["his"] = Marker{}
+	}
+	if p.extra != nil {
+		for k, v := range p.extra(s) {
+			d[k] = v
+		}
+	}
+	if p.writable() {
+		d["writable"] = Marker{}
+		if level, slot, ok := c.priorities[p.attr].effective(); ok {
+			d["writeLevel"] = Number{Val: float64(level)}
+			d["writeVal"] = slot.val
+		}
+	}
+	return d
+}
+
+// entities returns the equip followed by its points. Callers hold c.mu.
+func (c *Controller) entities(s thermostat.Snapshot) []Dict {
+	out := []Dict{c.equipDict()}
+	for _, p := range points {
+		out = append(out, c.pointDict(p, s))
+	}
+	return out
+}
+
+// entity returns the entity with id, or nil. Callers hold c.mu.
+func (c *Controller) entity(id string, s thermostat.Snapshot) Dict {
+	if id == c.equipID {
+		return c.equipDict()
+	}
+	if p, ok := c.point(id); ok {
+		return c.pointDict(p, s)
+	}
+	return nil
+}
+
+func (c *Controller) point(id string) (point, bool) {
+	for _, p := range points {
+		if c.pointID(p) == id {
+			return p, true
+		}
+	}
+	return point{}, false
+}
@@ -0,0 +1,59 @@
+package haystackctrl
+
+import "fmt"
+
+// priorityLevels is the size of a point's priority array; level 1 wins.
+const priorityLevels = 17
+
+type prioritySlot struct {
+	val any // nil when the level is released
+	who string
+}
+
+// priorityArray holds the pointWrite values of one writable point. The
+// effective value is pushed to the thermostat.
+type priorityArray [priorityLevels]prioritySlot
+
+// effective returns the highest-priority level holding a value.
+func (a *priorityArray) effective() (level int, slot prioritySlot, ok bool) {
+	if a == nil {
+		return 0, prioritySlot{}, false
+	}
+	for i, s := range a {
+		if s.val != nil {
+			return i + 1, s, true
+		}
+	}
+	return 0, prioritySlot{}, false
+}
+
+func levelDis(level int) string {
+	switch level {
+	case 1:
+		return "Emergency"
+	case 8:
+		return "Manual Override"
+	case priorityLevels:
+		return "Default"
+	}
+	return fmt.Sprintf("Level %d", level)
+}
+
+// rows is the pointWrite read response.
+func (a *priorityArray) rows() []Dict {
+	rows := make([]Dict, priorityLevels)
+	for i := range rows {
+		row := Dict{
+			"level":    Number{Val: float64(i + 1)},
+			"levelDis": levelDis(i + 1),
+		}
+		if a != nil && a[i].val != nil {
+			row["val"] = a[i].val
+			if a[i].who != "" {
+				row["who"] = a[i].who
+			}
+		}
+		rows[i] = row
+	}
+	return rows
+}
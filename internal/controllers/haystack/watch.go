@@ -0,0 +1,49 @@
+package haystackctrl
+
+import (
+	"crypto/rand"
+	"time"
+)
+
+// watch is a subscription opened by watchSub. A poll returns the entities
+// that changed since the previous one; the watch closes once its lease
+// expires without a poll.
+type watch struct {
+	dis     string
+	lease   time.Duration
+	expires time.Time
+	sent    map[string]string // entity id -> Zinc encoding last sent
+}
+
+func (w *watch) touch(now time.Time) { w.expires = now.Add(w.lease) }
+
+// fingerprint is a stable encoding of d, used to detect changes.
+func fingerprint(d Dict) string { return EncodeZinc(NewGrid(nil, d)) }
+
+// expireWatches drops watches whose lease has elapsed. Callers hold c.mu.
+func (c *Controller) expireWatches(now time.Time) {
+	for id, w := range c.watches {
+		if now.After(w.expires) {
+			delete(c.watches, id)
+			c.log.Debug("watch expired", "watch_id", id, "dis", w.dis)
+		}
+	}
+}
+
+func newWatchID() string { return "w-" + rand.Text() }
+
+// leaseDuration converts a lease Number; a missing unit means seconds.
+func leaseDuration(v any) (time.Duration, error) {
+	n, ok := numberOf(v)
+	if !ok || n.Val <= 0 {
+		return 0, invalidValue(v, "positive duration Number")
+	}
+	unit := map[string]time.Duration{
+		"": time.Second, "ms": time.Millisecond, "s": time.Second, "sec": time.Second,
+		"min": time.Minute, "h": time.Hour, "hr": time.Hour,
+	}[n.Unit]
+	if unit == 0 {
+		return 0, invalidValue(v, "duration in ms, s, min or h")
+	}
+	return time.Duration(n.Val * float64(unit)), nil
+}
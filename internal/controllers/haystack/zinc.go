@@ -0,0 +1,501 @@
+package haystackctrl
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Zinc is the Haystack text format (https://project-haystack.org/doc/docHaystack/Zinc).
+
+const zincVersion = "3.0"
+
+// EncodeZinc writes g as a Zinc grid.
+func EncodeZinc(g Grid) string {
+	var b strings.Builder
+	b.WriteString(`ver:"` + zincVersion + `"`)
+	writeZincTags(&b, g.Meta, "ver")
+	b.WriteByte('\n')
+	for i, c := range g.Cols {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(c.Name)
+		writeZincTags(&b, c.Meta, "")
+	}
+	b.WriteByte('\n')
+	for _, r := range g.Rows {
+		for i, c := range g.Cols {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			if v, ok := r[c.Name]; ok && v != nil {
+				b.WriteString(ZincValue(v))
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func writeZincTags(b *strings.Builder, d Dict, skip string) {
+	names := make([]string, 0, len(d))
+	for n := range d {
+		if n != skip {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		b.WriteByte(' ')
+		b.WriteString(n)
+		if !isMarker(d[n]) {
+			b.WriteByte(':')
+			b.WriteString(ZincValue(d[n]))
+		}
+	}
+}
+
+// ZincValue encodes a scalar.
+func ZincValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "N"
+	case Marker:
+		return "M"
+	case Remove:
+		return "R"
+	case NA:
+		return "NA"
+	case bool:
+		if v {
+			return "T"
+		}
+		return "F"
+	case Number:
+		switch {
+		case math.IsNaN(v.Val):
+			return "NaN"
+		case math.IsInf(v.Val, 1):
+			return "INF"
+		case math.IsInf(v.Val, -1):
+			return "-INF"
+		}
+		return strconv.FormatFloat(v.Val, 'f', -1, 64) + v.Unit
+	case string:
+		return zincString(v)
+	case Ref:
+		if v.Dis != "" {
+			return "@" + v.ID + " " + zincString(v.Dis)
+		}
+		return "@" + v.ID
+	case Uri:
+		return "`" + strings.NewReplacer(`\`, `\\`, "`", "\\`").Replace(string(v)) + "`"
+	case Date:
+		return v.String()
+	case time.Time:
+		return formatDateTime(v) + " UTC"
+	default:
+		return zincString(fmt.Sprint(v))
+	}
+}
+
+func formatDateTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.999Z07:00")
+}
+
+func zincString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '$':
+			b.WriteString(`\$`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// DecodeZinc parses a Zinc grid.
+func DecodeZinc(s string) (Grid, error) {
+	p := &zincParser{s: strings.ReplaceAll(s, "\r\n", "\n")}
+	g, err := p.grid()
+	if err != nil {
+		return Grid{}, fmt.Errorf("zinc: line %d: %w", p.line(), err)
+	}
+	return g, nil
+}
+
+// ParseZincValue parses a single Zinc scalar, e.g. a filter operand.
+func ParseZincValue(s string) (any, error) {
+	p := &zincParser{s: s}
+	v, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.s) {
+		return nil, fmt.Errorf("unexpected %q after value", p.s[p.pos:])
+	}
+	return v, nil
+}
+
+type zincParser struct {
+	s   string
+	pos int
+}
+
+func (p *zincParser) line() int { return strings.Count(p.s[:p.pos], "\n") + 1 }
+
+func (p *zincParser) peek() byte {
+	if p.pos >= len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *zincParser) eof() bool { return p.pos >= len(p.s) }
+
+func (p *zincParser) skipSpaces() {
+	for p.peek() == ' ' || p.peek() == '\t' {
+		p.pos++
+	}
+}
+
+func (p *zincParser) expect(c byte) error {
+	if p.peek() != c {
+		if p.eof() {
+			return fmt.Errorf("expected %q, got end of input", c)
+		}
+		return fmt.Errorf("expected %q, got %q", c, p.peek())
+	}
+	p.pos++
+	return nil
+}
+
+func (p *zincParser) endOfLine() error {
+	p.skipSpaces()
+	if p.eof() {
+		return nil
+	}
+	return p.expect('\n')
+}
+
+func (p *zincParser) grid() (Grid, error) {
+	g := Grid{}
+	if !strings.HasPrefix(p.s, "ver:") {
+		return g, errors.New(`grid must start with ver:"3.0"`)
+	}
+	p.pos = len("ver:")
+	ver, err := p.value()
+	if err != nil {
+		return g, err
+	}
+	if ver, ok := ver.(string); !ok || (ver != "3.0" && ver != "2.0") {
+		return g, fmt.Errorf("unsupported version %v", ver)
+	}
+	if g.Meta, err = p.tags(); err != nil {
+		return g, err
+	}
+	if err := p.endOfLine(); err != nil {
+		return g, err
+	}
+
+	// Columns
+	for {
+		p.skipSpaces()
+		name, err := p.id()
+		if err != nil {
+			return g, err
+		}
+		meta, err := p.tags()
+		if err != nil {
+			return g, err
+		}
+		if len(meta) == 0 {
+			meta = nil
+		}
+		g.Cols = append(g.Cols, Col{Name: name, Meta: meta})
+		p.skipSpaces()
+		if p.peek() != ',' {
+			break
+		}
+		p.pos++
+	}
+	if err := p.endOfLine(); err != nil {
+		return g, err
+	}
+
+	// Rows, until end of input or a blank line
+	for !p.eof() && p.peek() != '\n' {
+		row := Dict{}
+		for i, c := range g.Cols {
+			if i > 0 {
+				if err := p.expect(','); err != nil {
+					return g, err
+				}
+			}
+			p.skipSpaces()
+			if p.eof() || p.peek() == ',' || p.peek() == '\n' {
+				continue // null cell
+			}
+			v, err := p.value()
+			if err != nil {
+				return g, err
+			}
+			if v != nil {
+				row[c.Name] = v
+			}
+			p.skipSpaces()
+		}
+		if err := p.endOfLine(); err != nil {
+			return g, err
+		}
+		g.Rows = append(g.Rows, row)
+	}
+	return g, nil
+}
+
+// tags parses space-separated "name" markers and "name:value" pairs up to the
+// end of the line or the next column.
+func (p *zincParser) tags() (Dict, error) {
+	d := Dict{}
+	for {
+		p.skipSpaces()
+		if c := p.peek(); c == 0 || c == '\n' || c == ',' {
+			return d, nil
+		}
+		name, err := p.id()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ':' {
+			d[name] = Marker{}
+			continue
+		}
+		p.pos++
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		d[name] = v
+	}
+}
+
+func (p *zincParser) id() (string, error) {
+	start := p.pos
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || p.pos > start && c >= '0' && c <= '9' {
+			p.pos++
+			continue
+		}
+		break
+	}
+	if p.pos == start {
+		if p.eof() {
+			return "", errors.New("expected a tag name, got end of input")
+		}
+		return "", fmt.Errorf("expected a tag name, got %q", p.peek())
+	}
+	return p.s[start:p.pos], nil
+}
+
+func (p *zincParser) value() (any, error) {
+	c := p.peek()
+	switch {
+	case c == '"':
+		return p.str()
+	case c == '@':
+		return p.ref()
+	case c == '`':
+		return p.uri()
+	case c == '-' || c >= '0' && c <= '9':
+		return p.numberOrDate()
+	case c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+		word, _ := p.id()
+		switch word {
+		case "N":
+			return nil, nil
+		case "M":
+			return Marker{}, nil
+		case "R":
+			return Remove{}, nil
+		case "NA":
+			return NA{}, nil
+		case "T":
+			return true, nil
+		case "F":
+			return false, nil
+		case "INF":
+			return Number{Val: math.Inf(1)}, nil
+		case "NaN":
+			return Number{Val: math.NaN()}, nil
+		}
+		return nil, fmt.Errorf("unsupported value %q", word)
+	case c == 0:
+		return nil, errors.New("expected a value, got end of input")
+	}
+	return nil, fmt.Errorf("unexpected %q", c)
+}
+
+func (p *zincParser) str() (string, error) {
+	s, err := p.quoted('"')
+	return s, err
+}
+
+func (p *zincParser) uri() (Uri, error) {
+	s, err := p.quoted('`')
+	return Uri(s), err
+}
+
+func (p *zincParser) quoted(q byte) (string, error) {
+	p.pos++ // opening quote
+	var b strings.Builder
+	for {
+		if p.eof() {
+			return "", errors.New("unterminated string")
+		}
+		r, size := utf8.DecodeRuneInString(p.s[p.pos:])
+		p.pos += size
+		switch {
+		case r == rune(q):
+			return b.String(), nil
+		case r == '\n':
+			return "", errors.New("unterminated string")
+		case r != '\\':
+			b.WriteRune(r)
+			continue
+		}
+		if p.eof() {
+			return "", errors.New("unterminated string")
+		}
+		e := p.s[p.pos]
+		p.pos++
+		switch e {
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '"', '\\', '$', '`':
+			b.WriteByte(e)
+		case 'u':
+			if p.pos+4 > len(p.s) {
+				return "", errors.New(`invalid \u escape`)
+			}
+			n, err := strconv.ParseUint(p.s[p.pos:p.pos+4], 16, 32)
+			if err != nil {
+				return "", errors.New(`invalid \u escape`)
+			}
+			p.pos += 4
+			b.WriteRune(rune(n))
+		default:
+			return "", fmt.Errorf(`invalid escape \%c`, e)
+		}
+	}
+}
+
+func isRefChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '_' || c == ':' || c == '-' || c == '.' || c == '~'
+}
+
+func (p *zincParser) ref() (Ref, error) {
+	p.pos++ // @
+	start := p.pos
+	for p.pos < len(p.s) && isRefChar(p.s[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		return Ref{}, errors.New("empty ref")
+	}
+	r := Ref{ID: p.s[start:p.pos]}
+	if strings.HasPrefix(p.s[p.pos:], ` "`) {
+		p.pos++
+		dis, err := p.str()
+		if err != nil {
+			return Ref{}, err
+		}
+		r.Dis = dis
+	}
+	return r, nil
+}
+
+var (
+	zincDate     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	zincNumber   = regexp.MustCompile(`^-?\d[\d_]*(\.\d[\d_]*)?([eE][+-]?\d+)?`)
+	zincTimeZone = regexp.MustCompile(`^ [A-Z][A-Za-z0-9_+\-/]*`)
+)
+
+func (p *zincParser) numberOrDate() (any, error) {
+	if strings.HasPrefix(p.s[p.pos:], "-INF") {
+		p.pos += len("-INF")
+		return Number{Val: math.Inf(-1)}, nil
+	}
+	start := p.pos
+	for p.pos < len(p.s) {
+		r, size := utf8.DecodeRuneInString(p.s[p.pos:])
+		if r == ',' || r == '\n' || r == ' ' || r == '\t' || r == ')' || !unicode.IsPrint(r) {
+			break
+		}
+		p.pos += size
+	}
+	tok := p.s[start:p.pos]
+
+	if len(tok) >= 10 && zincDate.MatchString(tok[:10]) {
+		if len(tok) == 10 {
+			t, err := time.Parse("2006-01-02", tok)
+			if err != nil {
+				return nil, fmt.Errorf("invalid date %q", tok)
+			}
+			return Date{t.Year(), t.Month(), t.Day()}, nil
+		}
+		t, err := time.Parse(time.RFC3339Nano, tok)
+		if err != nil {
+			return nil, fmt.Errorf("invalid datetime %q", tok)
+		}
+		if tz := zincTimeZone.FindString(p.s[p.pos:]); tz != "" {
+			p.pos += len(tz) // the offset already locates the instant
+		}
+		return t, nil
+	}
+
+	num := zincNumber.FindString(tok)
+	if num == "" {
+		return nil, fmt.Errorf("invalid number %q", tok)
+	}
+	f, err := strconv.ParseFloat(strings.ReplaceAll(num, "_", ""), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %q", tok)
+	}
+	return Number{Val: f, Unit: tok[len(num):]}, nil
+}
@@ -0,0 +1,111 @@
+package haystackctrl
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestZincValue_RoundTrip(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 12, 30, 15, 0, time.UTC)
+	tests := []struct {
+		v    any
+		zinc string
+	}{
+		{Marker{}, "M"},
+		{Remove{}, "R"},
+		{NA{}, "NA"},
+		{true, "T"},
+		{false, "F"},
+		{Number{Val: 21.5, Unit: "°C"}, "21.5°C"},
+		{Number{Val: -3}, "-3"},
+		{Number{Val: math.Inf(1)}, "INF"},
+		{Number{Val: math.Inf(-1)}, "-INF"},
+		{"say \"hi\" $x\n", `"say \"hi\" \$x\n"`},
+		{Ref{ID: "dev.mode"}, "@dev.mode"},
+		{Ref{ID: "dev", Dis: "Device"}, `@dev "Device"`},
+		{Uri("http://x/`y`"), "`http://x/\\`y\\``"},
+		{Date{2024, time.March, 1}, "2024-03-01"},
+		{ts, "2024-03-01T12:30:15Z UTC"},
+	}
+	for _, tt := range tests {
+		if got := ZincValue(tt.v); got != tt.zinc {
+			t.Errorf("ZincValue(%#v) = %q, want %q", tt.v, got, tt.zinc)
+		}
+		got, err := ParseZincValue(tt.zinc)
+		if err != nil {
+			t.Errorf("ParseZincValue(%q): %v", tt.zinc, err)
+			continue
+		}
+		if gt, ok := got.(time.Time); ok {
+			if !gt.Equal(ts) {
+				t.Errorf("ParseZincValue(%q) = %v, want %v", tt.zinc, gt, ts)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.v) {
+			t.Errorf("ParseZincValue(%q) = %#v, want %#v", tt.zinc, got, tt.v)
+		}
+	}
+}
+
+func TestParseZincValue_Errors(t *testing.T) {
+	for _, s := range []string{"", "point", `"open`, "@", "2024-13-01", "1.5 x"} {
+		if _, err := ParseZincValue(s); err == nil {
+			t.Errorf("ParseZincValue(%q): expected error", s)
+		}
+	}
+}
+
+func TestZincGrid_RoundTrip(t *testing.T) {
+	g := Grid{
+		Meta: Dict{"watchId": "w-1", "refresh": Marker{}},
+		Cols: []Col{{Name: "id"}, {Name: "curVal", Meta: Dict{"dis": "Value"}}, {Name: "point"}},
+		Rows: []Dict{
+			{"id": Ref{ID: "a"}, "curVal": Number{Val: 20, Unit: "°C"}, "point": Marker{}},
+			{"id": Ref{ID: "b", Dis: "B"}},
+		},
+	}
+	zinc := EncodeZinc(g)
+	want := "ver:\"3.0\" refresh watchId:\"w-1\"\n" +
+		"id,curVal dis:\"Value\",point\n" +
+		"@a,20°C,M\n" +
+		"@b \"B\",,\n"
+	if zinc != want {
+		t.Fatalf("EncodeZinc =\n%s\nwant\n%s", zinc, want)
+	}
+
+	got, err := DecodeZinc(zinc)
+	if err != nil {
+		t.Fatalf("DecodeZinc: %v", err)
+	}
+	if !reflect.DeepEqual(got, g) {
+		t.Fatalf("DecodeZinc = %#v, want %#v", got, g)
+	}
+}
+
+func TestDecodeZinc_Request(t *testing.T) {
+	g, err := DecodeZinc("ver:\"3.0\"\r\nid,level,val\r\n@dev.mode,8,\"heat\"\r\n")
+	if err != nil {
+		t.Fatalf("DecodeZinc: %v", err)
+	}
+	want := Dict{"id": Ref{ID: "dev.mode"}, "level": Number{Val: 8}, "val": "heat"}
+	if len(g.Rows) != 1 || !reflect.DeepEqual(g.Rows[0], want) {
+		t.Fatalf("rows = %#v, want [%#v]", g.Rows, want)
+	}
+}
+
+func TestDecodeZinc_Errors(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"id\n@a\n",
+		"ver:\"9.0\"\nid\n",
+		"ver:\"3.0\"\n\n",
+		"ver:\"3.0\"\nid,val\n@a,1,2\n",
+	} {
+		if _, err := DecodeZinc(s); err == nil {
+			t.Errorf("DecodeZinc(%q): expected error", s)
+		}
+	}
+}
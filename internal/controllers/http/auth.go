@@ -0,0 +1,254 @@
+package httpctrl
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Scope is the access level granted to a credential.
+type Scope string
+
+const (
+	// ScopeRead allows reading the state (GET routes, streams).
+	ScopeRead Scope = "read"
+	// ScopeReadWrite also allows changing it (POST/PATCH routes, WebSocket commands).
+	ScopeReadWrite Scope = "read_write"
+)
+
+// ParseScope accepts "read" and "read_write"; empty means read-only.
+func ParseScope(s string) (Scope, error) {
+	switch Scope(strings.ToLower(strings.TrimSpace(s))) {
+	case "", ScopeRead:
+		return ScopeRead, nil
+	case ScopeReadWrite:
+		return ScopeReadWrite, nil
+	default:
+		return "", fmt.Errorf("invalid scope %q (expected read|read_write)", s)
+	}
+}
+
+// AuthConfig enables authentication when at least one credential source is
+// configured. /healthz, /version, /openapi.json, /docs and the dashboard
+// assets stay public.
+type AuthConfig struct {
+	APIKeys []APIKey    // sent as the X-API-Key header
+	Users   []BasicUser // sent with HTTP Basic authentication
+	JWT     JWTConfig   // sent as "Authorization: Bearer <token>"
+}
+
+type APIKey struct {
+	Name  string // optional label
+	Key   string
+	Scope Scope
+}
+
+type BasicUser struct {
+	Username string
+	Password string
+	Scope    Scope
+}
+
+// JWTConfig verifies bearer tokens locally. HMAC-signed tokens (HS256/384/512)
+// are checked against HMACSecret, asymmetric ones (RS*, ES*, EdDSA) against
+// the PEM public key or certificate in PublicKeyFile. The space-separated
+// "scope" claim grants "read" or "read_write".
+type JWTConfig struct {
+	HMACSecret    string
+	PublicKeyFile string
+	Issuer        string // required "iss" when set
+	Audience      string // required in "aud" when set
+}
+
+func (c AuthConfig) enabled() bool {
+	return len(c.APIKeys) > 0 || len(c.Users) > 0 || c.JWT.HMACSecret != "" || c.JWT.PublicKeyFile != ""
+}
+
+var (
+	errNoCredentials      = errors.New("missing credentials")
+	errInvalidCredentials = errors.New("invalid credentials")
+)
+
+// publicPaths never require credentials.
+var publicPaths = map[string]bool{
+	"/healthz":      true,
+	"/version":      true,
+	"/openapi.json": true,
+	"/docs":         true,
+}
+
+// queryTokenPaths also accept a bearer token as the access_token query
+// parameter: browsers cannot set headers on EventSource and WebSocket
+// requests.
+var queryTokenPaths = map[string]bool{
+	"/v1/events": true,
+	"/v1/ws":     true,
+}
+
+type principal struct {
+	name  string
+	scope Scope
+}
+
+type principalKey struct{}
+
+// canWrite reports whether the request may change the thermostat state;
+// always true when authentication is disabled.
+func canWrite(ctx context.Context) bool {
+	p, ok := ctx.Value(principalKey{}).(principal)
+	return !ok || p.scope == ScopeReadWrite
+}
+
+type authenticator struct {
+	apiKeys []APIKey
+	users   []BasicUser
+	jwt     *jwtVerifier
+	schemes string // WWW-Authenticate challenge
+}
+
+// newAuthenticator returns nil when authentication is disabled.
+func newAuthenticator(cfg AuthConfig) (*authenticator, error) {
+	if !cfg.enabled() {
+		return nil, nil
+	}
+	a := &authenticator{apiKeys: cfg.APIKeys, users: cfg.Users}
+	for _, k := range cfg.APIKeys {
+		if k.Key == "" {
+			return nil, fmt.Errorf("api key %q: empty key", k.Name)
+		}
+		if _, err := ParseScope(string(k.Scope)); err != nil {
+			return nil, fmt.Errorf("api key %q: %w", k.Name, err)
+		}
+	}
+	for _, u := range cfg.Users {
+		if u.Username == "" {
+			return nil, errors.New("basic auth user: empty username")
+		}
+		if u.Password == "" {
+			return nil, fmt.Errorf("basic auth user %q: empty password", u.Username)
+		}
+		if _, err := ParseScope(string(u.Scope)); err != nil {
+			return nil, fmt.Errorf("basic auth user %q: %w", u.Username, err)
+		}
+	}
+	if cfg.JWT.HMACSecret != "" || cfg.JWT.PublicKeyFile != "" {
+		v, err := newJWTVerifier(cfg.JWT)
+		if err != nil {
+			return nil, err
+		}
+		a.jwt = v
+	}
+
+	var challenges []string
+	if len(a.users) > 0 {
+		challenges = append(challenges, `Basic realm="thermocktat"`)
+	}
+	if a.jwt != nil {
+		challenges = append(challenges, `Bearer realm="thermocktat"`)
+	}
+	if len(a.apiKeys) > 0 {
+		challenges = append(challenges, `ApiKey realm="thermocktat"`)
+	}
+	a.schemes = strings.Join(challenges, ", ")
+	return a, nil
+}
+
+// requireAuth rejects requests without valid credentials (401) and writes by
+// read-only credentials (403). It is a no-op when a is nil.
+func requireAuth(a *authenticator, next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if publicPaths[r.URL.Path] || strings.HasPrefix(r.URL.Path, dashboardPath) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		p, err := a.authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", a.schemes)
+			writeProblem(w, codeUnauthorized, err.Error())
+			return
+		}
+		switch {
+		case p.scope == "":
+			writeProblem(w, codeForbidden, "credentials grant no scope")
+			return
+		case r.Method != http.MethodGet && r.Method != http.MethodHead && p.scope != ScopeReadWrite:
+			writeProblem(w, codeForbidden, "read-only credentials")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, p)))
+	})
+}
+
+func (a *authenticator) authenticate(r *http.Request) (principal, error) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return a.apiKey(key)
+	}
+	if user, pass, ok := r.BasicAuth(); ok {
+		return a.basic(user, pass)
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return a.bearer(strings.TrimSpace(token))
+	}
+	if token := r.URL.Query().Get("access_token"); token != "" && r.Method == http.MethodGet && queryTokenPaths[r.URL.Path] {
+		return a.bearer(token)
+	}
+	return principal{}, errNoCredentials
+}
+
+func (a *authenticator) apiKey(key string) (principal, error) {
+	for _, k := range a.apiKeys {
+		if secretEqual(key, k.Key) {
+			scope, _ := ParseScope(string(k.Scope))
+			return principal{name: k.Name, scope: scope}, nil
+		}
+	}
+	return principal{}, errInvalidCredentials
+}
+
+func (a *authenticator) basic(user, pass string) (principal, error) {
+	for _, u := range a.users {
+		// Evaluate both comparisons to keep timing independent of the username.
+		userOK := secretEqual(user, u.Username)
+		passOK := secretEqual(pass, u.Password)
+		if userOK && passOK {
+			scope, _ := ParseScope(string(u.Scope))
+			return principal{name: u.Username, scope: scope}, nil
+		}
+	}
+	return principal{}, errInvalidCredentials
+}
+
+func (a *authenticator) bearer(token string) (principal, error) {
+	if a.jwt == nil {
+		return principal{}, errInvalidCredentials
+	}
+	claims, err := a.jwt.verify(token)
+	if err != nil {
+		return principal{}, fmt.Errorf("%w: %v", errInvalidCredentials, err)
+	}
+	p := principal{name: claims.Subject}
+	for s := range strings.FieldsSeq(claims.Scope) {
+		switch Scope(s) {
+		case ScopeReadWrite:
+			p.scope = ScopeReadWrite
+		case ScopeRead:
+			if p.scope == "" {
+				p.scope = ScopeRead
+			}
+		}
+	}
+	return p, nil
+}
+
+// secretEqual compares in constant time, including for different lengths.
+func secretEqual(a, b string) bool {
+	ha, hb := sha256.Sum256([]byte(a)), sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
@@ -0,0 +1,197 @@
+package httpctrl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/Agrid-Dev/thermocktat/internal/testutil"
+)
+
+const testJWTSecret = "test-secret"
+
+func newAuthTestServer(t *testing.T) (*Server, *testutil.FakeThermostatService) {
+	t.Helper()
+	f := testutil.NewFakeThermostatService()
+	srv, err := New(f, Config{DeviceID: "default", Addr: ":0", Auth: testAuthConfig()}, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return srv, f
+}
+
+func testAuthConfig() AuthConfig {
+	return AuthConfig{
+		APIKeys: []APIKey{
+			{Name: "viewer", Key: "ro-key", Scope: ScopeRead},
+			{Name: "operator", Key: "rw-key", Scope: ScopeReadWrite},
+		},
+		Users: []BasicUser{
+			{Username: "bms", Password: "secret", Scope: ScopeReadWrite},
+			{Username: "guest", Password: "guest"}, // read-only by default
+		},
+		JWT: JWTConfig{HMACSecret: testJWTSecret},
+	}
+}
+
+func authRequest(t *testing.T, srv *Server, method, path string, setAuth func(*http.Request)) *httptest.ResponseRecorder {
+	t.Helper()
+	var body *strings.Reader
+	if method == http.MethodPost {
+		body = strings.NewReader(`{"value": true}`)
+	} else {
+		body = strings.NewReader("")
+	}
+	req := httptest.NewRequest(method, path, body)
+	if setAuth != nil {
+		setAuth(req)
+	}
+	rr := httptest.NewRecorder()
+	srv.srv.Handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func withAPIKey(key string) func(*http.Request) {
+	return func(r *http.Request) { r.Header.Set("X-API-Key", key) }
+}
+
+func withBasic(user, pass string) func(*http.Request) {
+	return func(r *http.Request) { r.SetBasicAuth(user, pass) }
+}
+
+func withBearer(token string) func(*http.Request) {
+	return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }
+}
+
+func TestAuth_Statuses(t *testing.T) {
+	srv, _ := newAuthTestServer(t)
+	exp := float64(time.Now().Add(time.Hour).Unix())
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		setAuth func(*http.Request)
+		want    int
+	}{
+		{"no credentials", http.MethodGet, "/v1", nil, http.StatusUnauthorized},
+		{"unknown api key", http.MethodGet, "/v1", withAPIKey("nope"), http.StatusUnauthorized},
+		{"read key reads", http.MethodGet, "/v1", withAPIKey("ro-key"), http.StatusOK},
+		{"read key writes", http.MethodPost, "/v1/enabled", withAPIKey("ro-key"), http.StatusForbidden},
+		{"read-write key writes", http.MethodPost, "/v1/enabled", withAPIKey("rw-key"), http.StatusOK},
+		{"wrong password", http.MethodGet, "/v1", withBasic("bms", "wrong"), http.StatusUnauthorized},
+		{"basic read-write", http.MethodPost, "/v1/enabled", withBasic("bms", "secret"), http.StatusOK},
+		{"basic default scope is read", http.MethodPost, "/v1/enabled", withBasic("guest", "guest"), http.StatusForbidden},
+		{"jwt read-write", http.MethodPost, "/v1/enabled", withBearer(signHS256(t, testJWTSecret, map[string]any{"scope": "read_write", "exp": exp})), http.StatusOK},
+		{"jwt read", http.MethodPost, "/v1/enabled", withBearer(signHS256(t, testJWTSecret, map[string]any{"scope": "read", "exp": exp})), http.StatusForbidden},
+		{"jwt without scope", http.MethodGet, "/v1", withBearer(signHS256(t, testJWTSecret, map[string]any{"exp": exp})), http.StatusForbidden},
+		{"jwt bad signature", http.MethodGet, "/v1", withBearer(signHS256(t, "other", map[string]any{"scope": "read"})), http.StatusUnauthorized},
+		{"healthz is public", http.MethodGet, "/healthz", nil, http.StatusOK},
+		{"version is public", http.MethodGet, "/version", nil, http.StatusOK},
+		{"openapi is public", http.MethodGet, "/openapi.json", nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := authRequest(t, srv, tt.method, tt.path, tt.setAuth)
+			assertStatus(t, rr, tt.want)
+		})
+	}
+}
+
+func TestAuth_UnauthorizedChallenges(t *testing.T) {
+	srv, _ := newAuthTestServer(t)
+	rr := authRequest(t, srv, http.MethodGet, "/v1", nil)
+	assertStatus(t, rr, http.StatusUnauthorized)
+
+	challenge := rr.Header().Get("WWW-Authenticate")
+	for _, scheme := range []string{"Basic", "Bearer", "ApiKey"} {
+		if !strings.Contains(challenge, scheme+` realm="thermocktat"`) {
+			t.Fatalf("WWW-Authenticate %q: missing %s", challenge, scheme)
+		}
+	}
+	assertProblem(t, rr, codeUnauthorized)
+}
+
+func TestAuth_ForbiddenWriteDoesNotCallService(t *testing.T) {
+	srv, f := newAuthTestServer(t)
+	rr := authRequest(t, srv, http.MethodPost, "/v1/enabled", withAPIKey("ro-key"))
+	assertStatus(t, rr, http.StatusForbidden)
+	if f.ApplyPatchCalled {
+		t.Fatal("service must not be called for a forbidden write")
+	}
+}
+
+func TestAuth_AccessTokenQueryForStreams(t *testing.T) {
+	token := signHS256(t, testJWTSecret, map[string]any{"scope": "read"})
+
+	srv, _ := newAuthTestServer(t)
+	for _, path := range []string{"/v1", "/v1/weather"} {
+		rr := authRequest(t, srv, http.MethodGet, path+"?access_token="+token, nil)
+		assertStatus(t, rr, http.StatusUnauthorized)
+	}
+
+	_, _, ts := newEventsTestServer(t, func(c *Config) { c.Auth = testAuthConfig() })
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/v1/ws?access_token=" + token
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial with access_token: %v", err)
+	}
+	_ = conn.Close()
+}
+
+func TestAuth_DisabledByDefault(t *testing.T) {
+	srv, _ := newTestServer()
+	rr := authRequest(t, srv, http.MethodPost, "/v1/enabled", nil)
+	assertStatus(t, rr, http.StatusOK)
+}
+
+func TestAuth_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  AuthConfig
+	}{
+		{"empty api key", AuthConfig{APIKeys: []APIKey{{Name: "k"}}}},
+		{"bad api key scope", AuthConfig{APIKeys: []APIKey{{Name: "k", Key: "k1", Scope: "admin"}}}},
+		{"bad scope", AuthConfig{Users: []BasicUser{{Username: "u", Password: "p", Scope: "admin"}}}},
+		{"empty password", AuthConfig{Users: []BasicUser{{Username: "u"}}}},
+		{"missing public key", AuthConfig{JWT: JWTConfig{PublicKeyFile: "/does/not/exist.pem"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(testutil.NewFakeThermostatService(), Config{Addr: ":0", Auth: tt.cfg}, nil); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestAuth_WebSocketReadOnlyRejectsCommands(t *testing.T) {
+	_, th, ts := newEventsTestServer(t, func(c *Config) { c.Auth = testAuthConfig() })
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/v1/ws"
+
+	if _, resp, err := websocket.DefaultDialer.Dial(url, nil); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without credentials, got err=%v", err)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"X-Api-Key": {"ro-key"}})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	_ = readWS(t, conn)
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"id":1,"set":{"enabled":false}}`)); err != nil {
+		t.Fatal(err)
+	}
+	msg := readWSType(t, conn, "error")
+	if msg.Error != "read-only credentials" || string(msg.ID) != "1" {
+		t.Fatalf("unexpected reply %+v", msg)
+	}
+	if !th.Get().Enabled {
+		t.Fatal("read-only command must not be applied")
+	}
+}
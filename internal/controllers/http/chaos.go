@@ -0,0 +1,232 @@
+package httpctrl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ChaosConfig injects faults into /v1 requests to exercise client retries,
+// timeouts and circuit breakers. The zero value injects nothing.
+//
+// Every request is first delayed by Latency plus a random duration up to
+// Jitter. Then, with the given probabilities, it hangs until the client gives
+// up, has its connection dropped without a response, or fails with
+// ErrorStatus; otherwise it is served normally. Faults are injected before
+// the request is handled, so a failed write is never applied.
+type ChaosConfig struct {
+	Latency     time.Duration
+	Jitter      time.Duration
+	ErrorRate   float64 // 0–1
+	ErrorStatus int     // 5xx, 503 by default
+	DropRate    float64 // 0–1
+	HangRate    float64 // 0–1
+}
+
+const chaosPath = "/v1/chaos"
+
+func (c ChaosConfig) withDefaults() ChaosConfig {
+	if c.ErrorStatus == 0 {
+		c.ErrorStatus = http.StatusServiceUnavailable
+	}
+	return c
+}
+
+func (c ChaosConfig) validate() error {
+	switch {
+	case c.Latency < 0 || c.Jitter < 0:
+		return errors.New("latency and jitter must not be negative")
+	case c.ErrorStatus < 500 || c.ErrorStatus > 599:
+		return fmt.Errorf("error status must be 5xx, got %d", c.ErrorStatus)
+	}
+	for _, r := range []struct {
+		name string
+		rate float64
+	}{{"error", c.ErrorRate}, {"drop", c.DropRate}, {"hang", c.HangRate}} {
+		if r.rate < 0 || r.rate > 1 {
+			return fmt.Errorf("%s rate must be between 0 and 1, got %v", r.name, r.rate)
+		}
+	}
+	if sum := c.ErrorRate + c.DropRate + c.HangRate; sum > 1 {
+		return fmt.Errorf("error, drop and hang rates add up to %v, more than 1", sum)
+	}
+	return nil
+}
+
+// chaos holds the fault settings, changeable at runtime through /v1/chaos.
+type chaos struct {
+	mu  sync.Mutex
+	cfg ChaosConfig
+}
+
+func newChaos(cfg ChaosConfig) (*chaos, error) {
+	cfg = cfg.withDefaults()
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &chaos{cfg: cfg}, nil
+}
+
+func (c *chaos) get() ChaosConfig {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cfg
+}
+
+// update applies fn to the current settings, all or nothing.
+func (c *chaos) update(fn func(ChaosConfig) ChaosConfig) (ChaosConfig, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cfg := fn(c.cfg).withDefaults()
+	if err := cfg.validate(); err != nil {
+		return c.cfg, err
+	}
+	c.cfg = cfg
+	return cfg, nil
+}
+
+// injectFaults applies the chaos settings to /v1 routes, except /v1/chaos
+// itself so faults can always be turned off.
+func injectFaults(c *chaos, log *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/v1/") && r.URL.Path != "/v1" || r.URL.Path == chaosPath {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cfg := c.get()
+
+		if delay := cfg.Latency + randDuration(cfg.Jitter); delay > 0 {
+			t := time.NewTimer(delay)
+			select {
+			case <-r.Context().Done():
+				t.Stop()
+				return
+			case <-t.C:
+			}
+		}
+
+		switch roll := rand.Float64(); {
+		case roll < cfg.HangRate:
+			log.Debug("chaos: hanging request", "path", r.URL.Path)
+			<-r.Context().Done()
+		case roll < cfg.HangRate+cfg.DropRate:
+			log.Debug("chaos: dropping connection", "path", r.URL.Path)
+			// Closes the connection without a response.
+			panic(http.ErrAbortHandler)
+		case roll < cfg.HangRate+cfg.DropRate+cfg.ErrorRate:
+			log.Debug("chaos: injecting error", "path", r.URL.Path, "status", cfg.ErrorStatus)
+			writeProblemStatus(w, cfg.ErrorStatus, codeInjectedFault, "fault injected by chaos settings")
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+func randDuration(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return rand.N(max + 1)
+}
+
+// ---- /v1/chaos ----
+
+type chaosDTO struct {
+	Latency     string  `json:"latency"`
+	Jitter      string  `json:"jitter"`
+	ErrorRate   float64 `json:"error_rate"`
+	ErrorStatus int     `json:"error_status"`
+	DropRate    float64 `json:"drop_rate"`
+	HangRate    float64 `json:"hang_rate"`
+}
+
+func toChaosDTO(c ChaosConfig) chaosDTO {
+	return chaosDTO{
+		Latency:     c.Latency.String(),
+		Jitter:      c.Jitter.String(),
+		ErrorRate:   c.ErrorRate,
+		ErrorStatus: c.ErrorStatus,
+		DropRate:    c.DropRate,
+		HangRate:    c.HangRate,
+	}
+}
+
+// chaosPatchDTO mirrors chaosDTO with optional fields.
+type chaosPatchDTO struct {
+	Latency     *string  `json:"latency"`
+	Jitter      *string  `json:"jitter"`
+	ErrorRate   *float64 `json:"error_rate"`
+	ErrorStatus *int     `json:"error_status"`
+	DropRate    *float64 `json:"drop_rate"`
+	HangRate    *float64 `json:"hang_rate"`
+}
+
+// durations parses the duration fields up front, so that update cannot fail
+// halfway.
+func (d chaosPatchDTO) durations() (latency, jitter *time.Duration, err error) {
+	parse := func(name string, s *string) (*time.Duration, error) {
+		if s == nil {
+			return nil, nil
+		}
+		v, err := time.ParseDuration(*s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", name, err)
+		}
+		return &v, nil
+	}
+	if latency, err = parse("latency", d.Latency); err != nil {
+		return nil, nil, err
+	}
+	jitter, err = parse("jitter", d.Jitter)
+	return latency, jitter, err
+}
+
+func (s *Server) handleGetChaos(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, toChaosDTO(s.chaos.get()))
+}
+
+func (s *Server) handlePatchChaos(w http.ResponseWriter, r *http.Request) {
+	// body: partial settings, e.g. {"latency": "200ms", "error_rate": 0.1}
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	var req chaosPatchDTO
+	if err := dec.Decode(&req); err != nil {
+		writeInvalidRequest(w, "invalid json: "+err.Error())
+		return
+	}
+	latency, jitter, err := req.durations()
+	if err != nil {
+		writeInvalidRequest(w, err.Error())
+		return
+	}
+	cfg, err := s.chaos.update(func(c ChaosConfig) ChaosConfig {
+		setIf(&c.Latency, latency)
+		setIf(&c.Jitter, jitter)
+		setIf(&c.ErrorRate, req.ErrorRate)
+		setIf(&c.ErrorStatus, req.ErrorStatus)
+		setIf(&c.DropRate, req.DropRate)
+		setIf(&c.HangRate, req.HangRate)
+		return c
+	})
+	if err != nil {
+		writeProblem(w, codeInvalidChaos, err.Error())
+		return
+	}
+	s.log.Info("chaos settings changed",
+		"latency", cfg.Latency, "jitter", cfg.Jitter,
+		"error_rate", cfg.ErrorRate, "error_status", cfg.ErrorStatus,
+		"drop_rate", cfg.DropRate, "hang_rate", cfg.HangRate)
+	writeJSON(w, http.StatusOK, toChaosDTO(cfg))
+}
+
+func setIf[T any](dst *T, v *T) {
+	if v != nil {
+		*dst = *v
+	}
+}
@@ -0,0 +1,140 @@
+package httpctrl
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Agrid-Dev/thermocktat/internal/testutil"
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+)
+
+func newChaosTestServer(t *testing.T, cfg ChaosConfig) (*Server, *testutil.FakeThermostatService) {
+	t.Helper()
+	f := testutil.NewFakeThermostatService()
+	srv, err := New(f, Config{DeviceID: "default", Addr: ":0", Chaos: cfg}, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return srv, f
+}
+
+func TestChaosConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     ChaosConfig
+		wantErr bool
+	}{
+		{"zero", ChaosConfig{}, false},
+		{"all faults", ChaosConfig{Latency: time.Second, Jitter: time.Second, ErrorRate: 0.5, DropRate: 0.25, HangRate: 0.25}, false},
+		{"negative latency", ChaosConfig{Latency: -time.Second}, true},
+		{"rate above 1", ChaosConfig{ErrorRate: 1.5}, true},
+		{"negative rate", ChaosConfig{DropRate: -0.1}, true},
+		{"rates add up above 1", ChaosConfig{ErrorRate: 0.6, HangRate: 0.6}, true},
+		{"non-5xx status", ChaosConfig{ErrorStatus: http.StatusTeapot}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.withDefaults().validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := New(testutil.NewFakeThermostatService(), Config{Chaos: ChaosConfig{ErrorRate: 2}}, nil); err == nil {
+		t.Fatal("New: expected error for invalid chaos settings")
+	}
+}
+
+func TestChaos_InjectsErrors(t *testing.T) {
+	srv, f := newChaosTestServer(t, ChaosConfig{ErrorRate: 1, ErrorStatus: http.StatusBadGateway})
+
+	rr := doJSONRequest(t, srv.srv.Handler, http.MethodPost, "/v1/enabled", map[string]any{"value": false})
+	assertStatus(t, rr, http.StatusBadGateway)
+	assertProblem(t, rr, codeInjectedFault)
+	if f.ApplyPatchCalled {
+		t.Fatal("write was applied despite the injected error")
+	}
+
+	// Routes outside /v1 and the chaos settings themselves are never faulted.
+	assertStatus(t, doJSONRequest(t, srv.srv.Handler, http.MethodGet, "/healthz", nil), http.StatusOK)
+	assertStatus(t, doJSONRequest(t, srv.srv.Handler, http.MethodGet, "/v1/chaos", nil), http.StatusOK)
+}
+
+func TestChaos_Latency(t *testing.T) {
+	srv, _ := newChaosTestServer(t, ChaosConfig{Latency: 30 * time.Millisecond, Jitter: 10 * time.Millisecond})
+
+	start := time.Now()
+	assertStatus(t, doJSONRequest(t, srv.srv.Handler, http.MethodGet, "/v1", nil), http.StatusOK)
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Fatalf("request took %v, want at least 30ms", elapsed)
+	}
+}
+
+func TestChaos_HangsUntilClientGivesUp(t *testing.T) {
+	srv, _ := newChaosTestServer(t, ChaosConfig{HangRate: 1})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequestWithContext(ctx, http.MethodGet, "/v1", nil)
+	rr := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		srv.srv.Handler.ServeHTTP(rr, req)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler did not return after the client gave up")
+	}
+	if rr.Body.Len() != 0 {
+		t.Fatalf("hung request got a body: %s", rr.Body)
+	}
+}
+
+func TestChaos_DropsConnections(t *testing.T) {
+	srv, _ := newChaosTestServer(t, ChaosConfig{DropRate: 1})
+	ts := httptest.NewServer(srv.srv.Handler)
+	defer ts.Close()
+
+	resp, err := ts.Client().Get(ts.URL + "/v1")
+	if err == nil {
+		resp.Body.Close()
+		t.Fatalf("expected a dropped connection, got %d", resp.StatusCode)
+	}
+}
+
+func TestChaos_PatchAtRuntime(t *testing.T) {
+	srv, _ := newChaosTestServer(t, ChaosConfig{})
+
+	rr := doJSONRequest(t, srv.srv.Handler, http.MethodPatch, "/v1/chaos", map[string]any{
+		"latency":    "5ms",
+		"error_rate": 1,
+	})
+	assertStatus(t, rr, http.StatusOK)
+	got := decodeJSON[chaosDTO](t, rr)
+	want := chaosDTO{Latency: "5ms", Jitter: "0s", ErrorRate: 1, ErrorStatus: http.StatusServiceUnavailable}
+	if got != want {
+		t.Fatalf("settings = %+v, want %+v", got, want)
+	}
+	assertStatus(t, doJSONRequest(t, srv.srv.Handler, http.MethodGet, "/v1", nil), http.StatusServiceUnavailable)
+
+	// Invalid settings are rejected as a whole.
+	rr = doJSONRequest(t, srv.srv.Handler, http.MethodPatch, "/v1/chaos", map[string]any{"latency": "1s", "hang_rate": 0.5})
+	assertProblem(t, rr, codeInvalidChaos)
+	rr = doJSONRequest(t, srv.srv.Handler, http.MethodPatch, "/v1/chaos", map[string]any{"jitter": "soon"})
+	assertProblem(t, rr, thermostat.CodeInvalidRequest)
+	rr = doJSONRequest(t, srv.srv.Handler, http.MethodPatch, "/v1/chaos", map[string]any{"error_rte": 0})
+	assertProblem(t, rr, thermostat.CodeInvalidRequest)
+	if got := srv.chaos.get(); got.Latency != 5*time.Millisecond || got.HangRate != 0 {
+		t.Fatalf("settings changed by rejected patches: %+v", got)
+	}
+
+	rr = doJSONRequest(t, srv.srv.Handler, http.MethodPatch, "/v1/chaos", map[string]any{"latency": "0s", "error_rate": 0})
+	assertStatus(t, rr, http.StatusOK)
+	assertStatus(t, doJSONRequest(t, srv.srv.Handler, http.MethodGet, "/v1", nil), http.StatusOK)
+}
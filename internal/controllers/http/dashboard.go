@@ -0,0 +1,28 @@
+package httpctrl
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// dashboardFiles is the single-page UI served at /ui/. It only uses the
+// public API (/v1/events, /v1/sim/*, PATCH /v1), so credentials entered in
+// the page are checked like any other client's.
+//
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+// dashboardPath prefixes the UI assets; they are public, the API calls they
+// make are not.
+const dashboardPath = "/ui/"
+
+func handleDashboard() http.HandlerFunc {
+	sub, _ := fs.Sub(dashboardFiles, "dashboard")
+	files := http.StripPrefix(dashboardPath, http.FileServerFS(sub))
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		// Missing assets get a problem like unknown routes.
+		files.ServeHTTP(&fallbackWriter{ResponseWriter: w, r: r}, r)
+	}
+}
@@ -0,0 +1,296 @@
+// Thermocktat dashboard: live state from /v1/events, regulation state from
+// /v1/sim/status, writes through PATCH /v1. Paths are relative so the page
+// also works behind a reverse proxy prefix.
+"use strict";
+
+const API = "../v1";
+const CHART_WINDOW_MS = 10 * 60 * 1000;
+const STATUS_INTERVAL_MS = 2000;
+const RECONNECT_MS = 2000;
+
+const state = { snapshot: {}, samples: [], lastEventId: null };
+
+// ---- Credentials (kept for the browser session only) ----
+
+function authHeaders() {
+  const type = sessionStorage.getItem("tmk-auth-type");
+  const value = sessionStorage.getItem("tmk-auth-value");
+  if (!type || !value) return {};
+  switch (type) {
+    case "apikey": return { "X-API-Key": value };
+    case "bearer": return { Authorization: "Bearer " + value };
+    case "basic": return { Authorization: "Basic " + btoa(value) };
+  }
+  return {};
+}
+
+document.getElementById("auth-form").addEventListener("submit", (e) => {
+  e.preventDefault();
+  const form = e.target;
+  sessionStorage.setItem("tmk-auth-type", form.type.value);
+  sessionStorage.setItem("tmk-auth-value", form.value.value);
+  form.value.value = "";
+  restartStream();
+  refreshStatus();
+});
+
+// ---- API helpers ----
+
+async function api(method, path, body) {
+  const headers = { ...authHeaders() };
+  if (body !== undefined) headers["Content-Type"] = "application/json";
+  const res = await fetch(API + path, {
+    method,
+    headers,
+    body: body === undefined ? undefined : JSON.stringify(body),
+  });
+  const data = await res.json().catch(() => null);
+  if (!res.ok) {
+    // Errors are RFC 7807 problem details.
+    const detail = data && (data.detail || data.title);
+    throw new Error(`${res.status} ${data && data.code ? data.code + ": " : ""}${detail || res.statusText}`);
+  }
+  clearError();
+  return data;
+}
+
+function showError(err) {
+  const el = document.getElementById("error");
+  el.textContent = err.message;
+  el.hidden = false;
+}
+
+function clearError() {
+  document.getElementById("error").hidden = true;
+}
+
+function setConnected(ok) {
+  const el = document.getElementById("connection");
+  el.textContent = ok ? "live" : "disconnected";
+  el.classList.toggle("off", !ok);
+}
+
+// ---- Live state (Server-Sent Events over fetch, so credentials can be sent as headers) ----
+
+let streamAbort = null;
+
+function restartStream() {
+  if (streamAbort) streamAbort.abort();
+  streamAbort = new AbortController();
+  stream(streamAbort.signal);
+}
+
+async function stream(signal) {
+  while (!signal.aborted) {
+    try {
+      const headers = { Accept: "text/event-stream", ...authHeaders() };
+      if (state.lastEventId !== null) headers["Last-Event-ID"] = state.lastEventId;
+      const res = await fetch(API + "/events", { headers, signal });
+      if (!res.ok) {
+        const data = await res.json().catch(() => null);
+        throw new Error(`${res.status} ${(data && data.detail) || res.statusText}`);
+      }
+      setConnected(true);
+      clearError();
+      await readEvents(res.body, signal);
+    } catch (err) {
+      if (signal.aborted) return;
+      showError(err);
+    }
+    setConnected(false);
+    await new Promise((r) => setTimeout(r, RECONNECT_MS));
+  }
+}
+
+async function readEvents(body, signal) {
+  const reader = body.pipeThrough(new TextDecoderStream()).getReader();
+  let buf = "";
+  while (!signal.aborted) {
+    const { value, done } = await reader.read();
+    if (done) return;
+    buf += value;
+    let sep;
+    while ((sep = buf.indexOf("\n\n")) >= 0) {
+      handleEvent(buf.slice(0, sep));
+      buf = buf.slice(sep + 2);
+    }
+  }
+}
+
+function handleEvent(block) {
+  let type = "message", id = null, data = "";
+  for (const line of block.split("\n")) {
+    if (line.startsWith("event:")) type = line.slice(6).trim();
+    else if (line.startsWith("id:")) id = line.slice(3).trim();
+    else if (line.startsWith("data:")) data += line.slice(5).trim();
+  }
+  if (id !== null) state.lastEventId = id;
+  if (!data) return; // keep-alive comment
+  const fields = JSON.parse(data);
+  if (type === "snapshot") {
+    state.snapshot = fields;
+    fillControls(fields);
+  } else if (type === "delta") {
+    Object.assign(state.snapshot, fields);
+  }
+  renderSnapshot();
+  sample();
+}
+
+// ---- Rendering ----
+
+const LABELS = {
+  enabled: "Enabled",
+  ambient_temperature: "Ambient (°C)",
+  temperature_setpoint: "Setpoint (°C)",
+  temperature_setpoint_min: "Setpoint min (°C)",
+  temperature_setpoint_max: "Setpoint max (°C)",
+  mode: "Mode",
+  fan_speed: "Fan speed",
+  fault_code: "Fault code",
+  version: "Version",
+};
+
+function renderList(el, entries) {
+  el.replaceChildren(...entries.flatMap(([k, v]) => {
+    const dt = document.createElement("dt");
+    const dd = document.createElement("dd");
+    dt.textContent = k;
+    dd.textContent = typeof v === "number" && !Number.isInteger(v) ? v.toFixed(2) : String(v);
+    return [dt, dd];
+  }));
+}
+
+function renderSnapshot() {
+  const s = state.snapshot;
+  document.getElementById("device-id").textContent = s.device_id || "";
+  renderList(document.getElementById("snapshot"),
+    Object.keys(LABELS).filter((k) => k in s).map((k) => [LABELS[k], s[k]]));
+}
+
+// fillControls copies the snapshot into the form; called on full snapshots
+// and after a successful write, so typing is not overwritten by live deltas.
+function fillControls(s) {
+  const form = document.getElementById("controls");
+  form.enabled.checked = s.enabled;
+  for (const name of ["temperature_setpoint", "temperature_setpoint_min", "temperature_setpoint_max", "mode", "fan_speed", "fault_code"]) {
+    if (name in s) form[name].value = s[name];
+  }
+}
+
+// ---- Chart ----
+
+function sample() {
+  const s = state.snapshot;
+  if (s.ambient_temperature === undefined) return;
+  const now = Date.now();
+  state.samples.push({ t: now, ambient: s.ambient_temperature, setpoint: s.temperature_setpoint });
+  while (state.samples.length && state.samples[0].t < now - CHART_WINDOW_MS) state.samples.shift();
+  drawChart();
+}
+
+function drawChart() {
+  const canvas = document.getElementById("chart");
+  const ctx = canvas.getContext("2d");
+  const { width, height } = canvas;
+  const pad = 36;
+  ctx.clearRect(0, 0, width, height);
+  const pts = state.samples;
+  if (!pts.length) return;
+
+  const values = pts.flatMap((p) => [p.ambient, p.setpoint]);
+  let lo = Math.floor(Math.min(...values) - 0.5);
+  let hi = Math.ceil(Math.max(...values) + 0.5);
+  const now = Date.now();
+  const x = (t) => pad + (width - 2 * pad) * (1 - (now - t) / CHART_WINDOW_MS);
+  const y = (v) => height - pad + (v - lo) / (hi - lo) * (2 * pad - height);
+
+  const css = getComputedStyle(document.documentElement);
+  ctx.strokeStyle = "#dee2e6";
+  ctx.fillStyle = css.getPropertyValue("--muted");
+  ctx.font = "11px system-ui";
+  ctx.lineWidth = 1;
+  const step = Math.max(1, Math.round((hi - lo) / 5));
+  for (let v = lo; v <= hi; v += step) {
+    ctx.beginPath();
+    ctx.moveTo(pad, y(v));
+    ctx.lineTo(width - pad, y(v));
+    ctx.stroke();
+    ctx.fillText(v + "°", 4, y(v) + 4);
+  }
+
+  for (const [key, color] of [["setpoint", "--setpoint"], ["ambient", "--ambient"]]) {
+    ctx.strokeStyle = css.getPropertyValue(color);
+    ctx.lineWidth = 2;
+    ctx.beginPath();
+    pts.forEach((p, i) => (i ? ctx.lineTo : ctx.moveTo).call(ctx, x(p.t), y(p[key])));
+    ctx.lineTo(x(now), y(pts[pts.length - 1][key])); // hold the last value
+    ctx.stroke();
+  }
+}
+
+// Scroll the chart even when nothing changes.
+setInterval(drawChart, 1000);
+
+// ---- Regulation ----
+
+function renderStatus(st) {
+  renderList(document.getElementById("regulation"), [
+    ["Paused", st.paused],
+    ["Regulation", st.regulation],
+    ["Ambient (°C)", st.ambient_temperature],
+    ["Outdoor (°C)", st.outdoor_temperature],
+  ]);
+}
+
+async function refreshStatus() {
+  try {
+    renderStatus(await api("GET", "/sim/status"));
+  } catch (err) {
+    showError(err);
+  }
+}
+
+function simAction(id, path, body) {
+  document.getElementById(id).addEventListener("click", async () => {
+    try {
+      renderStatus(await api("POST", path, body));
+    } catch (err) {
+      showError(err);
+    }
+  });
+}
+
+simAction("pause", "/sim/pause");
+simAction("resume", "/sim/resume");
+simAction("step", "/sim/step", { value: "1m" });
+setInterval(refreshStatus, STATUS_INTERVAL_MS);
+
+// ---- Controls ----
+
+document.getElementById("controls").addEventListener("submit", async (e) => {
+  e.preventDefault();
+  const form = e.target;
+  const s = state.snapshot;
+  const patch = {};
+  if (form.enabled.checked !== s.enabled) patch.enabled = form.enabled.checked;
+  for (const name of ["temperature_setpoint", "temperature_setpoint_min", "temperature_setpoint_max", "fault_code"]) {
+    const v = Number(form[name].value);
+    if (form[name].value !== "" && v !== s[name]) patch[name] = v;
+  }
+  for (const name of ["mode", "fan_speed"]) {
+    if (form[name].value !== s[name]) patch[name] = form[name].value;
+  }
+  if (!Object.keys(patch).length) return;
+  try {
+    const snap = await api("PATCH", "", patch);
+    Object.assign(state.snapshot, snap);
+    fillControls(snap);
+    renderSnapshot();
+  } catch (err) {
+    showError(err);
+  }
+});
+
+restartStream();
+refreshStatus();
@@ -0,0 +1,57 @@
+package httpctrl
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Agrid-Dev/thermocktat/internal/testutil"
+)
+
+func TestDashboard_ServesAssets(t *testing.T) {
+	srv, err := New(testutil.NewFakeThermostatService(), Config{DeviceID: "default", Addr: ":0", Dashboard: true}, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	tests := []struct {
+		path        string
+		contentType string
+		contains    string
+	}{
+		{"/ui/", "text/html", `<script src="app.js">`},
+		{"/ui/app.js", "javascript", `"../v1"`},
+		{"/ui/style.css", "text/css", "--ambient"},
+	}
+	for _, tt := range tests {
+		rr := doJSONRequest(t, srv.srv.Handler, http.MethodGet, tt.path, nil)
+		assertStatus(t, rr, http.StatusOK)
+		if ct := rr.Header().Get("Content-Type"); !strings.Contains(ct, tt.contentType) {
+			t.Errorf("%s: Content-Type = %q, want %q", tt.path, ct, tt.contentType)
+		}
+		if !strings.Contains(rr.Body.String(), tt.contains) {
+			t.Errorf("%s: body does not contain %q", tt.path, tt.contains)
+		}
+	}
+
+	rr := doJSONRequest(t, srv.srv.Handler, http.MethodGet, "/ui", nil)
+	assertStatus(t, rr, http.StatusTemporaryRedirect)
+	assertProblem(t, doJSONRequest(t, srv.srv.Handler, http.MethodGet, "/ui/missing.js", nil), codeNotFound)
+}
+
+func TestDashboard_DisabledByDefault(t *testing.T) {
+	srv, _ := newTestServer()
+	rr := doJSONRequest(t, srv.srv.Handler, http.MethodGet, "/ui/", nil)
+	assertStatus(t, rr, http.StatusNotFound)
+}
+
+func TestDashboard_AssetsArePublic(t *testing.T) {
+	f := testutil.NewFakeThermostatService()
+	srv, err := New(f, Config{DeviceID: "default", Addr: ":0", Dashboard: true, Auth: testAuthConfig()}, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	assertStatus(t, authRequest(t, srv, http.MethodGet, "/ui/", nil), http.StatusOK)
+	assertStatus(t, authRequest(t, srv, http.MethodGet, "/ui/app.js", nil), http.StatusOK)
+	assertStatus(t, authRequest(t, srv, http.MethodGet, "/v1", nil), http.StatusUnauthorized)
+}
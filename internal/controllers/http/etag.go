@@ -0,0 +1,61 @@
+package httpctrl
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// etag tags a snapshot representation as "<version>-<hash>": the version
+// only changes with the writable attributes, while the hash of the encoded
+// snapshot also changes with the ambient temperature. If-None-Match compares
+// whole tags, If-Match only versions.
+func etag(version uint64, dto snapshotDTO) string {
+	b, _ := json.Marshal(dto)
+	h := fnv.New64a()
+	h.Write(b)
+	return `"` + strconv.FormatUint(version, 10) + "-" + strconv.FormatUint(h.Sum64(), 16) + `"`
+}
+
+// headerTags returns the entity tags of an If-Match or If-None-Match header,
+// without quotes nor weak prefix (W/"3" compares like "3"), and whether it
+// holds "*".
+func headerTags(header string) (tags []string, wildcard bool) {
+	for tag := range strings.SplitSeq(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return nil, true
+		}
+		tag = strings.TrimPrefix(tag, "W/")
+		if len(tag) >= 2 && tag[0] == '"' && tag[len(tag)-1] == '"' {
+			tags = append(tags, tag[1:len(tag)-1])
+		}
+	}
+	return tags, false
+}
+
+// matchesETag reports whether an If-None-Match header lists tag.
+func matchesETag(header, tag string) bool {
+	tags, wildcard := headerTags(header)
+	for _, t := range tags {
+		if `"`+t+`"` == tag {
+			return true
+		}
+	}
+	return wildcard
+}
+
+// matchesVersion reports whether an If-Match header lists a tag of version,
+// whatever the ambient temperature it was read with. Bare versions ("3")
+// are accepted too.
+func matchesVersion(header string, version uint64) bool {
+	tags, wildcard := headerTags(header)
+	want := strconv.FormatUint(version, 10)
+	for _, t := range tags {
+		if v, _, _ := strings.Cut(t, "-"); v == want {
+			return true
+		}
+	}
+	return wildcard
+}
@@ -0,0 +1,151 @@
+package httpctrl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+)
+
+func TestMatchesETag(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{`"3-a1"`, true},
+		{`W/"3-a1"`, true},
+		{`"1-ff", "3-a1"`, true},
+		{`*`, true},
+		{`"3-b2"`, false},
+		{`"3"`, false},
+		{`3-a1`, false},
+		{``, false},
+	}
+	for _, tt := range tests {
+		if got := matchesETag(tt.header, `"3-a1"`); got != tt.want {
+			t.Errorf("matchesETag(%q)=%v want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestMatchesVersion(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{`"3-a1"`, true},
+		{`"3-b2"`, true}, // read with another ambient temperature
+		{`"3"`, true},
+		{`W/"3"`, true},
+		{`"1", "3-a1"`, true},
+		{`*`, true},
+		{`"2-a1"`, false},
+		{`"30-a1"`, false},
+		{`3`, false},
+		{``, false},
+	}
+	for _, tt := range tests {
+		if got := matchesVersion(tt.header, 3); got != tt.want {
+			t.Errorf("matchesVersion(%q, 3)=%v want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func conditionalRequest(t *testing.T, srv *Server, method, path, header, tag, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set(header, tag)
+	rr := httptest.NewRecorder()
+	srv.srv.Handler.ServeHTTP(rr, r)
+	return rr
+}
+
+func TestGET_v1_ETag(t *testing.T) {
+	srv, f := newTestServer()
+	f.S.Version = 4
+
+	rr := doJSONRequest(t, srv.srv.Handler, http.MethodGet, "/v1", nil)
+	assertStatus(t, rr, http.StatusOK)
+	tag := rr.Header().Get("ETag")
+	if !strings.HasPrefix(tag, `"4-`) {
+		t.Fatalf("expected ETag of version 4, got %q", tag)
+	}
+	if got := decodeJSON[snapshotDTO](t, rr); got.Version != 4 {
+		t.Fatalf("expected version 4 in body, got %d", got.Version)
+	}
+
+	rr = conditionalRequest(t, srv, http.MethodGet, "/v1", "If-None-Match", tag, "")
+	assertStatus(t, rr, http.StatusNotModified)
+	if rr.Body.Len() != 0 || rr.Header().Get("ETag") != tag {
+		t.Fatalf("expected empty 304 with ETag, got headers=%v body=%q", rr.Header(), rr.Body.String())
+	}
+
+	rr = conditionalRequest(t, srv, http.MethodGet, "/v1", "If-None-Match", `"4"`, "")
+	assertStatus(t, rr, http.StatusOK)
+}
+
+func TestGET_v1_ETagFollowsAmbient(t *testing.T) {
+	srv, f := newTestServer()
+	rr := doJSONRequest(t, srv.srv.Handler, http.MethodGet, "/v1", nil)
+	tag := rr.Header().Get("ETag")
+
+	// The regulation loop moves the ambient temperature, not the version.
+	f.S.AmbientTemperature += 0.5
+	rr = conditionalRequest(t, srv, http.MethodGet, "/v1", "If-None-Match", tag, "")
+	assertStatus(t, rr, http.StatusOK)
+	if got := decodeJSON[snapshotDTO](t, rr); got.AmbientTemperature != f.S.AmbientTemperature {
+		t.Fatalf("expected the new ambient temperature, got %v", got.AmbientTemperature)
+	}
+	newTag := rr.Header().Get("ETag")
+	if newTag == tag {
+		t.Fatalf("expected a new ETag, got %q again", tag)
+	}
+	rr = conditionalRequest(t, srv, http.MethodGet, "/v1", "If-None-Match", newTag, "")
+	assertStatus(t, rr, http.StatusNotModified)
+
+	// The tag read before the ambient change is still good for writes.
+	rr = conditionalRequest(t, srv, http.MethodPost, "/v1/temperature_setpoint", "If-Match", tag, `{"value": 20}`)
+	assertStatus(t, rr, http.StatusOK)
+}
+
+func TestWrite_IfMatch(t *testing.T) {
+	srv, f := newTestServer()
+
+	// Two supervisors read version 0; the first write wins.
+	rr := conditionalRequest(t, srv, http.MethodPost, "/v1/temperature_setpoint", "If-Match", `"0"`, `{"value": 20}`)
+	assertStatus(t, rr, http.StatusOK)
+	if got := rr.Header().Get("ETag"); !strings.HasPrefix(got, `"1-`) {
+		t.Fatalf("expected an ETag of version 1, got %q", got)
+	}
+
+	rr = conditionalRequest(t, srv, http.MethodPatch, "/v1", "If-Match", `"0"`, `{"temperature_setpoint": 25}`)
+	assertStatus(t, rr, http.StatusPreconditionFailed)
+	assertProblem(t, rr, thermostat.CodeVersionMismatch)
+	if f.S.TemperatureSetpoint != 20 {
+		t.Fatalf("stale write must not apply, setpoint=%v", f.S.TemperatureSetpoint)
+	}
+
+	// After re-reading, the second supervisor succeeds.
+	rr = conditionalRequest(t, srv, http.MethodPatch, "/v1", "If-Match", `"1"`, `{"temperature_setpoint": 25}`)
+	assertStatus(t, rr, http.StatusOK)
+
+	rr = conditionalRequest(t, srv, http.MethodPost, "/v1/mode", "If-Match", "*", `{"value": "cool"}`)
+	assertStatus(t, rr, http.StatusOK)
+	if f.S.Version != 3 {
+		t.Fatalf("expected version 3, got %d", f.S.Version)
+	}
+}
+
+func TestWrite_IfMatchRaceIsAtomic(t *testing.T) {
+	srv, f := newTestServer()
+	// The version passed the header check but changed before the write.
+	f.ApplyPatchErr = thermostat.ErrVersionMismatch
+
+	rr := conditionalRequest(t, srv, http.MethodPost, "/v1/enabled", "If-Match", `"0"`, `{"value": false}`)
+	assertStatus(t, rr, http.StatusPreconditionFailed)
+	if p := f.ApplyPatchArg; p.IfVersion == nil || *p.IfVersion != 0 {
+		t.Fatalf("expected IfVersion 0 passed to the service, got %+v", p)
+	}
+}
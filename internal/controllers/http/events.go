@@ -0,0 +1,230 @@
+package httpctrl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+)
+
+const (
+	eventPollInterval = 250 * time.Millisecond
+	eventBufferSize   = 256
+	eventKeepAlive    = 15 * time.Second
+	// eventSubscriberQueue is how many events a slow client may lag behind
+	// before it is disconnected (it can then resume with Last-Event-ID).
+	eventSubscriberQueue = 32
+)
+
+// event is one state change, as a JSON object of the changed fields.
+type event struct {
+	id   uint64
+	data []byte
+}
+
+// eventHub polls the service for state changes (including ambient temperature
+// updates from the regulation loop) and broadcasts them to subscribers. The
+// last eventBufferSize events are kept so reconnecting clients can resume.
+type eventHub struct {
+	svc thermostat.Service
+
+	mu      sync.Mutex
+	last    snapshotDTO
+	hasLast bool
+	lastID  uint64
+	buf     []event // oldest first, at most eventBufferSize
+	subs    map[chan event]struct{}
+}
+
+func newEventHub(svc thermostat.Service) *eventHub {
+	return &eventHub{svc: svc, subs: make(map[chan event]struct{})}
+}
+
+func (h *eventHub) run(ctx context.Context) {
+	h.poll()
+	ticker := time.NewTicker(eventPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.poll()
+		}
+	}
+}
+
+// poll diffs the current snapshot against the last one seen and broadcasts
+// the changed fields, if any.
+func (h *eventHub) poll() {
+	cur := toDTO(h.svc.Get())
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.hasLast {
+		h.last, h.hasLast = cur, true
+		return
+	}
+	delta := diffDTO(h.last, cur)
+	if len(delta) == 0 {
+		return
+	}
+	h.last = cur
+
+	data, _ := json.Marshal(delta)
+	h.lastID++
+	ev := event{id: h.lastID, data: data}
+	h.buf = append(h.buf, ev)
+	if len(h.buf) > eventBufferSize {
+		h.buf = h.buf[len(h.buf)-eventBufferSize:]
+	}
+
+	for ch := range h.subs {
+		select {
+		case ch <- ev:
+		default:
+			// Too slow: drop it, the client resumes from the buffer on reconnect.
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe registers a new client. When lastEventID is still covered by the
+// buffer, the missed events are returned for replay; otherwise resumed is
+// false and the client should be sent a full snapshot tagged with currentID.
+func (h *eventHub) subscribe(lastEventID *uint64) (ch chan event, replay []event, currentID uint64, resumed bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch = make(chan event, eventSubscriberQueue)
+	h.subs[ch] = struct{}{}
+
+	if lastEventID != nil && *lastEventID <= h.lastID {
+		oldest := h.lastID - uint64(len(h.buf)) // last id the buffer can resume from
+		if *lastEventID >= oldest {
+			replay = append(replay, h.buf[len(h.buf)-int(h.lastID-*lastEventID):]...)
+			return ch, replay, h.lastID, true
+		}
+	}
+	return ch, nil, h.lastID, false
+}
+
+func (h *eventHub) unsubscribe(ch chan event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[ch]; ok {
+		delete(h.subs, ch)
+		close(ch)
+	}
+}
+
+func diffDTO(prev, cur snapshotDTO) map[string]any {
+	d := map[string]any{}
+	if prev.Enabled != cur.Enabled {
+		d["enabled"] = cur.Enabled
+	}
+	if prev.TemperatureSetpoint != cur.TemperatureSetpoint {
+		d["temperature_setpoint"] = cur.TemperatureSetpoint
+	}
+	if prev.TemperatureSetpointMin != cur.TemperatureSetpointMin {
+		d["temperature_setpoint_min"] = cur.TemperatureSetpointMin
+	}
+	if prev.TemperatureSetpointMax != cur.TemperatureSetpointMax {
+		d["temperature_setpoint_max"] = cur.TemperatureSetpointMax
+	}
+	if prev.Mode != cur.Mode {
+		d["mode"] = cur.Mode
+	}
+	if prev.FanSpeed != cur.FanSpeed {
+		d["fan_speed"] = cur.FanSpeed
+	}
+	if prev.AmbientTemperature != cur.AmbientTemperature {
+		d["ambient_temperature"] = cur.AmbientTemperature
+	}
+	if prev.FaultCode != cur.FaultCode {
+		d["fault_code"] = cur.FaultCode
+	}
+	if prev.Version != cur.Version {
+		d["version"] = cur.Version
+	}
+	return d
+}
+
+// handleEvents streams state changes as Server-Sent Events. A new client first
+// receives a "snapshot" event, then "delta" events holding the changed fields.
+// A client reconnecting with Last-Event-ID only receives the deltas it missed
+// while they are still buffered, and a fresh snapshot otherwise.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	lastEventID, err := parseLastEventID(r)
+	if err != nil {
+		writeInvalidRequest(w, err.Error())
+		return
+	}
+
+	ch, replay, currentID, resumed := s.events.subscribe(lastEventID)
+	defer s.events.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+
+	if !resumed {
+		dto := toDTO(s.svc.Get())
+		dto.DeviceID = s.deviceID
+		data, _ := json.Marshal(dto)
+		writeEvent(w, currentID, "snapshot", data)
+	}
+	for _, ev := range replay {
+		writeEvent(w, ev.id, "delta", ev.data)
+	}
+	_ = rc.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			writeEvent(w, ev.id, "delta", ev.data)
+		case <-keepAlive.C:
+			_, _ = fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// parseLastEventID reads the resume position from the Last-Event-ID header, or
+// the lastEventId query parameter for clients that cannot set headers.
+func parseLastEventID(r *http.Request) (*uint64, error) {
+	raw := r.Header.Get("Last-Event-ID")
+	if raw == "" {
+		raw = r.URL.Query().Get("lastEventId")
+	}
+	if raw == "" {
+		return nil, nil
+	}
+	id, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Last-Event-ID %q", raw)
+	}
+	return &id, nil
+}
+
+func writeEvent(w http.ResponseWriter, id uint64, name string, data []byte) {
+	_, _ = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", id, name, data)
+}
@@ -0,0 +1,199 @@
+package httpctrl
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+)
+
+type sseEvent struct {
+	id    string
+	event string
+	data  map[string]any
+}
+
+// newEventsTestServer uses a real thermostat: the stream handler reads the
+// service from its own goroutine, which the fake is not safe for.
+func newEventsTestServer(t *testing.T, opts ...func(*Config)) (*Server, *thermostat.Thermostat, *httptest.Server) {
+	t.Helper()
+	th, err := thermostat.New(
+		thermostat.Snapshot{
+			Enabled:                true,
+			TemperatureSetpoint:    22,
+			TemperatureSetpointMin: 16,
+			TemperatureSetpointMax: 28,
+			Mode:                   thermostat.ModeAuto,
+			FanSpeed:               thermostat.FanAuto,
+			AmbientTemperature:     21,
+		},
+		thermostat.PIDRegulatorParams{TargetHysteresis: 1, ModeChangeHysteresis: 2},
+		thermostat.HeatLossSimulatorParams{Coefficient: 0.01, OutdoorTemperature: 10},
+		nil,
+	)
+	if err != nil {
+		t.Fatalf("thermostat.New: %v", err)
+	}
+	cfg := Config{DeviceID: "default", Addr: ":0"}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	srv, err := New(th, cfg, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ts := httptest.NewServer(srv.srv.Handler)
+	t.Cleanup(ts.Close)
+	srv.events.poll() // baseline
+	return srv, th, ts
+}
+
+func openEventStream(t *testing.T, ts *httptest.Server, lastEventID string) *bufio.Reader {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	t.Cleanup(cancel)
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/v1/events", nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /v1/events: %v", err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected text/event-stream, got %q", ct)
+	}
+	return bufio.NewReader(resp.Body)
+}
+
+func readEvent(t *testing.T, r *bufio.Reader) sseEvent {
+	t.Helper()
+	var ev sseEvent
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read event: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "":
+			if ev.event != "" {
+				return ev
+			}
+		case strings.HasPrefix(line, "id: "):
+			ev.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			ev.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev.data); err != nil {
+				t.Fatalf("event data: %v", err)
+			}
+		}
+	}
+}
+
+func TestEvents_SnapshotThenDeltas(t *testing.T) {
+	srv, th, ts := newEventsTestServer(t)
+	stream := openEventStream(t, ts, "")
+
+	first := readEvent(t, stream)
+	if first.event != "snapshot" || first.id != "0" {
+		t.Fatalf("expected initial snapshot with id 0, got %+v", first)
+	}
+	if first.data["device_id"] != "default" || first.data["temperature_setpoint"] != float64(22) {
+		t.Fatalf("unexpected snapshot data: %v", first.data)
+	}
+
+	if err := th.SetSetpoint(24); err != nil {
+		t.Fatal(err)
+	}
+	srv.events.poll()
+
+	delta := readEvent(t, stream)
+	if delta.event != "delta" || delta.id != "1" {
+		t.Fatalf("expected delta with id 1, got %+v", delta)
+	}
+	if len(delta.data) != 2 || delta.data["temperature_setpoint"] != float64(24) || delta.data["version"] != float64(1) {
+		t.Fatalf("expected only the changed field and the version, got %v", delta.data)
+	}
+
+	// Ambient changes from the regulation loop are streamed too.
+	th.UpdateAmbient(time.Second)
+	srv.events.poll()
+
+	delta = readEvent(t, stream)
+	if _, ok := delta.data["ambient_temperature"]; delta.id != "2" || !ok {
+		t.Fatalf("expected ambient_temperature delta with id 2, got %+v", delta)
+	}
+}
+
+func TestEvents_ResumeFromLastEventID(t *testing.T) {
+	srv, th, ts := newEventsTestServer(t)
+	for _, sp := range []float64{23, 24, 25} {
+		if err := th.SetSetpoint(sp); err != nil {
+			t.Fatal(err)
+		}
+		srv.events.poll()
+	}
+
+	stream := openEventStream(t, ts, "1")
+
+	for _, want := range []struct {
+		id string
+		sp float64
+	}{{"2", 24}, {"3", 25}} {
+		ev := readEvent(t, stream)
+		if ev.event != "delta" || ev.id != want.id || ev.data["temperature_setpoint"] != want.sp {
+			t.Fatalf("expected replayed delta %s (setpoint %v), got %+v", want.id, want.sp, ev)
+		}
+	}
+}
+
+func TestEvents_UnknownLastEventIDSendsSnapshot(t *testing.T) {
+	_, _, ts := newEventsTestServer(t)
+	stream := openEventStream(t, ts, "999")
+
+	ev := readEvent(t, stream)
+	if ev.event != "snapshot" {
+		t.Fatalf("expected a fresh snapshot, got %+v", ev)
+	}
+}
+
+func TestEvents_InvalidLastEventID(t *testing.T) {
+	srv, _ := newTestServer()
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/events", nil)
+	req.Header.Set("Last-Event-ID", "abc")
+	rr := httptest.NewRecorder()
+	srv.srv.Handler.ServeHTTP(rr, req)
+
+	assertStatus(t, rr, http.StatusBadRequest)
+	assertProblem(t, rr, thermostat.CodeInvalidRequest)
+}
+
+func TestEventHub_BufferIsBounded(t *testing.T) {
+	srv, th, _ := newEventsTestServer(t)
+	for i := 0; i <= eventBufferSize; i++ {
+		th.SetFaultCode(i + 1)
+		srv.events.poll()
+	}
+
+	_, replay, currentID, resumed := srv.events.subscribe(new(uint64))
+	if resumed || replay != nil {
+		t.Fatalf("id 0 fell out of the buffer and must not resume (replayed %d)", len(replay))
+	}
+	if currentID != eventBufferSize+1 {
+		t.Fatalf("expected current id %d, got %d", eventBufferSize+1, currentID)
+	}
+}
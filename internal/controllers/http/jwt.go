@@ -0,0 +1,224 @@
+package httpctrl
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// jwtVerifier checks compact JWS tokens with the standard library only.
+type jwtVerifier struct {
+	hmacSecret []byte
+	publicKey  crypto.PublicKey // *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey
+	issuer     string
+	audience   string
+	now        func() time.Time
+}
+
+type jwtClaims struct {
+	Subject   string      `json:"sub"`
+	Issuer    string      `json:"iss"`
+	Audience  jwtAudience `json:"aud"`
+	ExpiresAt *float64    `json:"exp"`
+	NotBefore *float64    `json:"nbf"`
+	Scope     string      `json:"scope"`
+}
+
+// jwtAudience accepts both forms allowed by RFC 7519: a string or an array.
+type jwtAudience []string
+
+func (a *jwtAudience) UnmarshalJSON(b []byte) error {
+	var one string
+	if err := json.Unmarshal(b, &one); err == nil {
+		*a = jwtAudience{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return errors.New("aud must be a string or an array of strings")
+	}
+	*a = many
+	return nil
+}
+
+func newJWTVerifier(cfg JWTConfig) (*jwtVerifier, error) {
+	v := &jwtVerifier{
+		issuer:   cfg.Issuer,
+		audience: cfg.Audience,
+		now:      time.Now,
+	}
+	if cfg.HMACSecret != "" {
+		v.hmacSecret = []byte(cfg.HMACSecret)
+	}
+	if cfg.PublicKeyFile != "" {
+		key, err := loadPublicKey(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("jwt public key: %w", err)
+		}
+		v.publicKey = key
+	}
+	return v, nil
+}
+
+// loadPublicKey reads a PEM "PUBLIC KEY" (PKIX) or "CERTIFICATE" block.
+func loadPublicKey(path string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block", path)
+	}
+	var key crypto.PublicKey
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+			key = cert.PublicKey
+		}
+	default:
+		return nil, fmt.Errorf("%s: unsupported PEM block %q", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	switch key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("%s: unsupported key type %T", path, key)
+	}
+}
+
+func (v *jwtVerifier) verify(token string) (jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return jwtClaims{}, errors.New("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return jwtClaims{}, fmt.Errorf("header: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return jwtClaims{}, errors.New("signature: invalid encoding")
+	}
+	if err := v.verifySignature(header.Alg, parts[0]+"."+parts[1], sig); err != nil {
+		return jwtClaims{}, err
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return jwtClaims{}, fmt.Errorf("claims: %w", err)
+	}
+	now := float64(v.now().Unix())
+	if claims.ExpiresAt != nil && now >= *claims.ExpiresAt {
+		return jwtClaims{}, errors.New("token expired")
+	}
+	if claims.NotBefore != nil && now < *claims.NotBefore {
+		return jwtClaims{}, errors.New("token not yet valid")
+	}
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		return jwtClaims{}, errors.New("unexpected issuer")
+	}
+	if v.audience != "" && !slices.Contains(claims.Audience, v.audience) {
+		return jwtClaims{}, errors.New("unexpected audience")
+	}
+	return claims, nil
+}
+
+// verifySignature only accepts an algorithm matching a configured key, so an
+// RSA public key can never be used as an HMAC secret.
+func (v *jwtVerifier) verifySignature(alg, signed string, sig []byte) error {
+	var hash crypto.Hash
+	switch alg[min(len(alg), 2):] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	}
+
+	switch {
+	case strings.HasPrefix(alg, "HS") && hash != 0 && v.hmacSecret != nil:
+		mac := hmac.New(hash.New, v.hmacSecret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return errors.New("invalid signature")
+		}
+		return nil
+
+	case strings.HasPrefix(alg, "RS") && hash != 0:
+		pub, ok := v.publicKey.(*rsa.PublicKey)
+		if !ok {
+			break
+		}
+		if rsa.VerifyPKCS1v15(pub, hash, digest(hash, signed), sig) != nil {
+			return errors.New("invalid signature")
+		}
+		return nil
+
+	case strings.HasPrefix(alg, "ES") && hash != 0:
+		pub, ok := v.publicKey.(*ecdsa.PublicKey)
+		if !ok || pub.Curve.Params().BitSize != esCurveBits[hash] {
+			break
+		}
+		// JWS encodes ECDSA signatures as fixed-size r || s.
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return errors.New("invalid signature")
+		}
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, digest(hash, signed), r, s) {
+			return errors.New("invalid signature")
+		}
+		return nil
+
+	case alg == "EdDSA":
+		pub, ok := v.publicKey.(ed25519.PublicKey)
+		if !ok {
+			break
+		}
+		if !ed25519.Verify(pub, []byte(signed), sig) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported algorithm %q", alg)
+}
+
+// esCurveBits ties ES256, ES384 and ES512 to the P-256, P-384 and P-521 curves.
+var esCurveBits = map[crypto.Hash]int{crypto.SHA256: 256, crypto.SHA384: 384, crypto.SHA512: 521}
+
+func digest(hash crypto.Hash, signed string) []byte {
+	h := hash.New()
+	h.Write([]byte(signed))
+	return h.Sum(nil)
+}
+
+func decodeSegment(seg string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return errors.New("invalid encoding")
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,145 @@
+package httpctrl
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func encodeSegment(t *testing.T, v any) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func signHS256(t *testing.T, secret string, claims map[string]any) string {
+	t.Helper()
+	signed := encodeSegment(t, map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + encodeSegment(t, claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func signES256(t *testing.T, key *ecdsa.PrivateKey, claims map[string]any) string {
+	t.Helper()
+	signed := encodeSegment(t, map[string]string{"alg": "ES256", "typ": "JWT"}) + "." + encodeSegment(t, claims)
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest(crypto.SHA256, signed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func writePublicKey(t *testing.T, pub crypto.PublicKey) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "pub.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestJWT_HMACClaims(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	v, err := newJWTVerifier(JWTConfig{HMACSecret: "s3cret", Issuer: "bms", Audience: "thermocktat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.now = func() time.Time { return now }
+
+	valid := map[string]any{"iss": "bms", "aud": []string{"other", "thermocktat"}, "scope": "read", "sub": "alice"}
+	tests := []struct {
+		name    string
+		claims  map[string]any
+		wantErr string
+	}{
+		{"valid", valid, ""},
+		{"audience string", merge(valid, map[string]any{"aud": "thermocktat"}), ""},
+		{"expired", merge(valid, map[string]any{"exp": now.Unix()}), "token expired"},
+		{"not yet valid", merge(valid, map[string]any{"nbf": now.Unix() + 60}), "token not yet valid"},
+		{"wrong issuer", merge(valid, map[string]any{"iss": "someone"}), "unexpected issuer"},
+		{"wrong audience", merge(valid, map[string]any{"aud": "other"}), "unexpected audience"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := v.verify(signHS256(t, "s3cret", tt.claims))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if claims.Subject != "alice" || claims.Scope != "read" {
+					t.Fatalf("unexpected claims %+v", claims)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("expected %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestJWT_ECDSAPublicKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := newJWTVerifier(JWTConfig{PublicKeyFile: writePublicKey(t, &key.PublicKey)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := v.verify(signES256(t, key, map[string]any{"scope": "read_write"})); err != nil {
+		t.Fatalf("valid ES256 token rejected: %v", err)
+	}
+
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if _, err := v.verify(signES256(t, other, map[string]any{"scope": "read_write"})); err == nil {
+		t.Fatal("token signed by another key accepted")
+	}
+}
+
+func TestJWT_RejectsUnconfiguredAlgorithms(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	v, err := newJWTVerifier(JWTConfig{PublicKeyFile: writePublicKey(t, &key.PublicKey)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// No HMAC secret configured: an HS256 token must not verify.
+	if _, err := v.verify(signHS256(t, "", map[string]any{"scope": "read"})); err == nil {
+		t.Fatal("HS256 token accepted without an HMAC secret")
+	}
+	unsigned := encodeSegment(t, map[string]string{"alg": "none"}) + "." + encodeSegment(t, map[string]any{"scope": "read"}) + "."
+	if _, err := v.verify(unsigned); err == nil {
+		t.Fatal(`"none" token accepted`)
+	}
+}
+
+func merge(base, extra map[string]any) map[string]any {
+	out := maps.Clone(base)
+	maps.Copy(out, extra)
+	return out
+}
@@ -0,0 +1,467 @@
+package httpctrl
+
+import (
+	"net/http"
+
+	"github.com/Agrid-Dev/thermocktat/internal/buildinfo"
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+)
+
+// object is a JSON object of the OpenAPI document.
+type object = map[string]any
+
+// public overrides the document security for routes that never require credentials.
+var public = []any{}
+
+func (s *Server) handleOpenAPI(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, openAPIDocument())
+}
+
+// openAPIDocument describes every route registered in New; keep both in sync
+// (TestOpenAPI_DocumentsEveryRoute enforces it).
+func openAPIDocument() object {
+	return object{
+		"openapi": "3.0.3",
+		"info": object{
+			"title":       "Thermocktat HTTP API",
+			"description": "Control API of the thermocktat thermostat emulator.",
+			"version":     buildinfo.Version,
+			"license":     object{"name": "MIT"},
+		},
+		"paths": object{
+			"/v1": object{
+				"get": operation("getSnapshot", "Read the full snapshot", nil, object{
+					"200": snapshotResponse(),
+					"304": object{"description": "Unchanged, ambient temperature included, since one of the If-None-Match ETags"},
+					"401": errorResponse("Missing or invalid credentials (when authentication is enabled)"),
+				}).with("parameters", []any{etagParam("If-None-Match", "Reply 304 while the snapshot, ambient temperature included, matches one of these ETags")}),
+				"patch": operation("patchSnapshot",
+					"Update several attributes atomically",
+					jsonBody(ref("Patch")),
+					snapshotResponses()).with("parameters", []any{ifMatchParam()}),
+			},
+			"/v1/enabled":                  snapshotValuePath("setEnabled", "Power the thermostat on or off", object{"type": "boolean"}),
+			"/v1/temperature_setpoint":     snapshotValuePath("setTemperatureSetpoint", "Set the temperature setpoint", object{"type": "number"}),
+			"/v1/temperature_setpoint_min": snapshotValuePath("setTemperatureSetpointMin", "Set the setpoint lower bound", object{"type": "number"}),
+			"/v1/temperature_setpoint_max": snapshotValuePath("setTemperatureSetpointMax", "Set the setpoint upper bound", object{"type": "number"}),
+			"/v1/mode":                     snapshotValuePath("setMode", "Set the operating mode", ref("Mode")),
+			"/v1/fan_speed":                snapshotValuePath("setFanSpeed", "Set the fan speed", ref("FanSpeed")),
+			"/v1/fault_code":               snapshotValuePath("setFaultCode", "Set the fault code", object{"type": "integer"}),
+			"/v1/weather": object{
+				"get": operation("getWeatherStatus", "Read the weather provider health", nil, object{
+					"200": jsonResponse("Weather provider health", ref("WeatherStatus")),
+				}),
+			},
+			"/v1/simulation": object{
+				"get": operation("getSimulation", "Read the room simulation parameters", nil, object{
+					"200": jsonResponse("Simulation parameters", ref("Simulation")),
+				}),
+				"patch": operation("patchSimulation",
+					"Tune the room simulation at runtime, all or nothing",
+					jsonBody(ref("SimulationPatch")),
+					writeResponses(jsonResponse("Updated simulation parameters", ref("Simulation")))),
+			},
+			"/v1/sim/status": object{
+				"get": operation("getSimulationStatus", "Read whether the simulation is paused and what the regulation is doing", nil, object{
+					"200": jsonResponse("Simulation status", ref("SimulationStatus")),
+				}),
+			},
+			"/v1/sim/pause": object{
+				"post": operation("pauseSimulation", "Freeze the regulation loop", nil, simStatusResponses()),
+			},
+			"/v1/sim/resume": object{
+				"post": operation("resumeSimulation", "Resume the regulation loop", nil, simStatusResponses()),
+			},
+			"/v1/sim/ambient_temperature": valuePath("setAmbientTemperature",
+				"Force the ambient temperature; the simulation continues from this value",
+				object{"type": "number"}, simStatusResponses()),
+			"/v1/sim/step": valuePath("stepSimulation",
+				"Advance the simulation by a duration at once, even while paused",
+				object{"type": "string", "description": "Go duration, e.g. 15m", "example": "15m"}, simStatusResponses()),
+			"/v1/chaos": object{
+				"get": operation("getChaos", "Read the fault injection settings", nil, object{
+					"200": jsonResponse("Fault injection settings", ref("Chaos")),
+				}),
+				"patch": operation("patchChaos",
+					"Change the fault injection settings, all or nothing; this route is never faulted",
+					jsonBody(ref("ChaosPatch")),
+					writeResponses(jsonResponse("Updated fault injection settings", ref("Chaos")))),
+			},
+			"/v1/events": object{
+				"get": operation("streamEvents",
+					"Stream state changes as Server-Sent Events: a `snapshot` event, then `delta` events with the changed fields",
+					nil,
+					object{
+						"200": object{
+							"description": "Event stream",
+							"content":     object{"text/event-stream": object{"schema": object{"type": "string"}}},
+						},
+						"400": errorResponse("Invalid Last-Event-ID"),
+					},
+				).with("parameters", []any{
+					object{
+						"name": "Last-Event-ID", "in": "header", "required": false,
+						"description": "Resume after this event id",
+						"schema":      object{"type": "integer", "minimum": 0},
+					},
+					object{
+						"name": "lastEventId", "in": "query", "required": false,
+						"description": "Same as the Last-Event-ID header",
+						"schema":      object{"type": "integer", "minimum": 0},
+					},
+				}),
+			},
+			"/v1/ws": object{
+				"get": operation("controlChannel",
+					"WebSocket control channel: snapshots are pushed on change, `{\"id\": ..., \"set\": {...}}` commands are acknowledged",
+					nil,
+					object{"101": object{"description": "Switching to the WebSocket protocol"}},
+				),
+			},
+			"/version": object{
+				"get": operation("getVersion", "Read the build information", nil, object{
+					"200": jsonResponse("Build information", ref("Version")),
+				}).with("security", public),
+			},
+			"/healthz": object{
+				"get": operation("healthz", "Liveness probe", nil, object{
+					"200": object{
+						"description": "Server is running",
+						"content":     object{"text/plain": object{"schema": object{"type": "string", "example": "ok"}}},
+					},
+				}).with("security", public),
+			},
+			"/openapi.json": object{
+				"get": operation("getOpenAPI", "Read this OpenAPI document", nil, object{
+					"200": jsonResponse("OpenAPI document", object{"type": "object"}),
+				}).with("security", public),
+			},
+			"/docs": object{
+				"get": operation("getDocs", "Swagger UI page for this document (only when enabled)", nil, object{
+					"200": object{
+						"description": "HTML page",
+						"content":     object{"text/html": object{"schema": object{"type": "string"}}},
+					},
+				}).with("security", public),
+			},
+			"/ui/": object{
+				"get": operation("getDashboard", "Dashboard single-page UI and its assets (only when enabled)", nil, object{
+					"200": object{
+						"description": "HTML page",
+						"content":     object{"text/html": object{"schema": object{"type": "string"}}},
+					},
+				}).with("security", public),
+			},
+		},
+		// Credentials are only required when authentication is configured.
+		"security": []any{object{}, object{"apiKey": []any{}}, object{"basic": []any{}}, object{"bearer": []any{}}},
+		"components": object{
+			"schemas": schemas(),
+			"securitySchemes": object{
+				"apiKey": object{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+				"basic":  object{"type": "http", "scheme": "basic"},
+				"bearer": object{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+	}
+}
+
+func schemas() object {
+	modes := []any{}
+	for m := thermostat.ModeHeat; m.Valid(); m++ {
+		modes = append(modes, m.String())
+	}
+	fanSpeeds := []any{}
+	for f := thermostat.FanAuto; f.Valid(); f++ {
+		fanSpeeds = append(fanSpeeds, f.String())
+	}
+	number := object{"type": "number", "format": "double"}
+
+	return object{
+		"Mode":     object{"type": "string", "enum": modes},
+		"FanSpeed": object{"type": "string", "enum": fanSpeeds},
+		"Snapshot": object{
+			"type": "object",
+			"required": []any{
+				"device_id", "enabled", "temperature_setpoint", "temperature_setpoint_min",
+				"temperature_setpoint_max", "mode", "fan_speed", "ambient_temperature", "fault_code", "version",
+			},
+			"properties": object{
+				"device_id":                object{"type": "string"},
+				"enabled":                  object{"type": "boolean"},
+				"temperature_setpoint":     number,
+				"temperature_setpoint_min": number,
+				"temperature_setpoint_max": number,
+				"mode":                     ref("Mode"),
+				"fan_speed":                ref("FanSpeed"),
+				"ambient_temperature":      number,
+				"fault_code":               object{"type": "integer"},
+				"version": object{
+					"type":        "integer",
+					"minimum":     0,
+					"description": "Increases on every change of a writable attribute, not on ambient temperature changes; the ETag starts with it",
+				},
+			},
+		},
+		"Patch": object{
+			"type":                 "object",
+			"description":          "Partial snapshot: only the attributes present are updated, all or nothing.",
+			"additionalProperties": false,
+			"properties": object{
+				"enabled":                  object{"type": "boolean"},
+				"temperature_setpoint":     number,
+				"temperature_setpoint_min": number,
+				"temperature_setpoint_max": number,
+				"mode":                     ref("Mode"),
+				"fan_speed":                ref("FanSpeed"),
+				"fault_code":               object{"type": "integer"},
+			},
+		},
+		"WeatherStatus": object{
+			"type":     "object",
+			"required": []any{"provider", "healthy", "outdoor_temperature", "last_success", "consecutive_failures", "provider_switches"},
+			"properties": object{
+				"provider":             object{"type": "string"},
+				"healthy":              object{"type": "boolean"},
+				"outdoor_temperature":  number,
+				"last_success":         object{"type": "string", "format": "date-time", "nullable": true},
+				"last_error":           object{"type": "string"},
+				"last_error_at":        object{"type": "string", "format": "date-time"},
+				"consecutive_failures": object{"type": "integer"},
+				"provider_switches":    object{"type": "integer", "description": "Changes of active provider of a fallback chain, 0 otherwise"},
+			},
+		},
+		"Simulation": object{
+			"type":     "object",
+			"required": []any{"regulator", "heat_loss"},
+			"properties": object{
+				"regulator": object{
+					"type":     "object",
+					"required": []any{"kp", "ki", "kd", "target_hysteresis", "mode_change_hysteresis", "interval"},
+					"properties": object{
+						"kp":                     number,
+						"ki":                     number,
+						"kd":                     number,
+						"target_hysteresis":      number,
+						"mode_change_hysteresis": number,
+						"interval":               object{"type": "string", "example": "1s"},
+					},
+				},
+				"heat_loss": object{
+					"type":     "object",
+					"required": []any{"coefficient", "outdoor_temperature", "outdoor_temperature_override"},
+					"properties": object{
+						"coefficient":                  number,
+						"outdoor_temperature":          object{"type": "number", "format": "double", "description": "Value in use: the override when set, otherwise the weather provider's"},
+						"outdoor_temperature_override": object{"type": "number", "format": "double", "nullable": true},
+					},
+				},
+			},
+		},
+		"SimulationPatch": object{
+			"type":                 "object",
+			"additionalProperties": false,
+			"properties": object{
+				"regulator": object{
+					"type":                 "object",
+					"additionalProperties": false,
+					"properties": object{
+						"kp":                     object{"type": "number", "minimum": 0},
+						"ki":                     object{"type": "number", "minimum": 0},
+						"kd":                     object{"type": "number", "minimum": 0},
+						"target_hysteresis":      number,
+						"mode_change_hysteresis": object{"type": "number", "description": "Must stay greater than target_hysteresis"},
+						"interval":               object{"type": "string", "description": "Go duration, e.g. 500ms"},
+					},
+				},
+				"heat_loss": object{
+					"type":                 "object",
+					"additionalProperties": false,
+					"properties": object{
+						"coefficient":                  object{"type": "number", "minimum": 0},
+						"outdoor_temperature_override": object{"type": "number", "nullable": true, "description": "null hands the outdoor temperature back to the weather provider"},
+					},
+				},
+			},
+		},
+		"SimulationStatus": object{
+			"type":     "object",
+			"required": []any{"paused", "regulation", "ambient_temperature", "outdoor_temperature"},
+			"properties": object{
+				"paused":              object{"type": "boolean"},
+				"regulation":          object{"type": "string", "enum": []any{"idle", "heating", "cooling"}},
+				"ambient_temperature": number,
+				"outdoor_temperature": number,
+			},
+		},
+		"Chaos": object{
+			"type":     "object",
+			"required": []any{"latency", "jitter", "error_rate", "error_status", "drop_rate", "hang_rate"},
+			"properties": object{
+				"latency":      object{"type": "string", "example": "200ms", "description": "Added to every /v1 request"},
+				"jitter":       object{"type": "string", "example": "100ms", "description": "Random extra latency, up to this value"},
+				"error_rate":   object{"type": "number", "minimum": 0, "maximum": 1},
+				"error_status": object{"type": "integer", "minimum": 500, "maximum": 599},
+				"drop_rate":    object{"type": "number", "minimum": 0, "maximum": 1, "description": "Connection closed without a response"},
+				"hang_rate":    object{"type": "number", "minimum": 0, "maximum": 1, "description": "No response until the client gives up"},
+			},
+		},
+		"ChaosPatch": object{
+			"type":                 "object",
+			"additionalProperties": false,
+			"description":          "error_rate, drop_rate and hang_rate must add up to 1 at most",
+			"properties": object{
+				"latency":      object{"type": "string", "description": "Go duration, e.g. 200ms"},
+				"jitter":       object{"type": "string", "description": "Go duration, e.g. 100ms"},
+				"error_rate":   object{"type": "number", "minimum": 0, "maximum": 1},
+				"error_status": object{"type": "integer", "minimum": 500, "maximum": 599},
+				"drop_rate":    object{"type": "number", "minimum": 0, "maximum": 1},
+				"hang_rate":    object{"type": "number", "minimum": 0, "maximum": 1},
+			},
+		},
+		"Version": object{
+			"type":     "object",
+			"required": []any{"version", "commit", "date"},
+			"properties": object{
+				"version": object{"type": "string"},
+				"commit":  object{"type": "string"},
+				"date":    object{"type": "string"},
+			},
+		},
+		"Problem": object{
+			"type":        "object",
+			"description": "RFC 7807 problem details; match on code, detail is for humans",
+			"required":    []any{"type", "title", "status", "code"},
+			"properties": object{
+				"type":   object{"type": "string", "example": "urn:thermocktat:problem:setpoint_out_of_range"},
+				"title":  object{"type": "string"},
+				"status": object{"type": "integer"},
+				"detail": object{"type": "string"},
+				"code":   object{"type": "string", "example": "setpoint_out_of_range"},
+			},
+		},
+	}
+}
+
+// op is an OpenAPI operation object.
+type op object
+
+func (o op) with(key string, v any) op {
+	o[key] = v
+	return o
+}
+
+func operation(id, summary string, body object, responses object) op {
+	o := op{"operationId": id, "summary": summary, "responses": responses}
+	if body != nil {
+		o["requestBody"] = body
+	}
+	return o
+}
+
+func valuePath(id, summary string, value, responses object) object {
+	return object{
+		"post": operation(id, summary, jsonBody(object{
+			"type":                 "object",
+			"required":             []any{"value"},
+			"additionalProperties": false,
+			"properties":           object{"value": value},
+		}), responses),
+	}
+}
+
+// snapshotValuePath is a single-attribute write, conditional on If-Match.
+func snapshotValuePath(id, summary string, value object) object {
+	p := valuePath(id, summary, value, snapshotResponses())
+	p["post"] = p["post"].(op).with("parameters", []any{ifMatchParam()})
+	return p
+}
+
+func snapshotResponse() object {
+	r := jsonResponse("Current snapshot", ref("Snapshot"))
+	r["headers"] = object{"ETag": object{
+		"description": "\"<version>-<hash>\": If-None-Match compares whole tags, If-Match only the version",
+		"schema":      object{"type": "string", "example": `"42-9f3c2a7b1e4d5c60"`},
+	}}
+	return r
+}
+
+func snapshotResponses() object {
+	r := writeResponses(snapshotResponse())
+	r["412"] = errorResponse("If-Match does not list the current version")
+	return r
+}
+
+func etagParam(name, desc string) object {
+	return object{
+		"name": name, "in": "header", "required": false,
+		"description": desc,
+		"schema":      object{"type": "string", "example": `"42-9f3c2a7b1e4d5c60"`},
+	}
+}
+
+func ifMatchParam() object {
+	return etagParam("If-Match", "Apply only if the version is that of one of these ETags (or bare versions, e.g. \"42\"), else reply 412")
+}
+
+func simStatusResponses() object {
+	return writeResponses(jsonResponse("Simulation status", ref("SimulationStatus")))
+}
+
+func writeResponses(ok object) object {
+	return object{
+		"200": ok,
+		"400": errorResponse("Malformed request"),
+		"401": errorResponse("Missing or invalid credentials (when authentication is enabled)"),
+		"403": errorResponse("Credentials do not grant write access"),
+		"409": errorResponse("Value conflicts with the current state, e.g. setpoint outside min/max"),
+		"422": errorResponse("Value rejected by validation"),
+	}
+}
+
+func jsonBody(schema object) object {
+	return object{
+		"required": true,
+		"content":  object{"application/json": object{"schema": schema}},
+	}
+}
+
+func jsonResponse(desc string, schema object) object {
+	return object{
+		"description": desc,
+		"content":     object{"application/json": object{"schema": schema}},
+	}
+}
+
+func errorResponse(desc string) object {
+	return object{
+		"description": desc,
+		"content":     object{"application/problem+json": object{"schema": ref("Problem")}},
+	}
+}
+
+func ref(name string) object {
+	return object{"$ref": "#/components/schemas/" + name}
+}
+
+const swaggerUIPage = `<!doctype html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Thermocktat API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`
+
+func handleSwaggerUI(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write([]byte(swaggerUIPage))
+}
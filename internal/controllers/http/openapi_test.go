@@ -0,0 +1,64 @@
+package httpctrl
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Agrid-Dev/thermocktat/internal/testutil"
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+)
+
+func TestOpenAPI_DocumentsEveryRoute(t *testing.T) {
+	srv, err := New(&testutil.FakeThermostatService{}, Config{DeviceID: "default", Addr: ":0", SwaggerUI: true, Dashboard: true}, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	rr := doJSONRequest(t, srv.srv.Handler, http.MethodGet, "/openapi.json", nil)
+	assertStatus(t, rr, http.StatusOK)
+	doc := decodeJSON[map[string]any](t, rr)
+
+	if doc["openapi"] != "3.0.3" {
+		t.Fatalf("openapi version: got %v", doc["openapi"])
+	}
+	paths, _ := doc["paths"].(map[string]any)
+	for _, route := range srv.routes {
+		method, path, _ := strings.Cut(route, " ")
+		item, ok := paths[path].(map[string]any)
+		if !ok {
+			t.Errorf("route %q: path missing from spec", route)
+			continue
+		}
+		if _, ok := item[strings.ToLower(method)]; !ok {
+			t.Errorf("route %q: method missing from spec", route)
+		}
+	}
+}
+
+func TestOpenAPI_Enums(t *testing.T) {
+	s := schemas()
+	tests := []struct {
+		schema string
+		want   []string
+	}{
+		{"Mode", []string{thermostat.ModeHeat.String(), thermostat.ModeCool.String(), thermostat.ModeFan.String(), thermostat.ModeAuto.String()}},
+		{"FanSpeed", []string{thermostat.FanAuto.String(), thermostat.FanLow.String(), thermostat.FanMedium.String(), thermostat.FanHigh.String()}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, v := range s[tt.schema].(object)["enum"].([]any) {
+			got = append(got, v.(string))
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Fatalf("%s enum: got %v, want %v", tt.schema, got, tt.want)
+		}
+	}
+}
+
+func TestSwaggerUI_DisabledByDefault(t *testing.T) {
+	srv, _ := newTestServer()
+	rr := doJSONRequest(t, srv.srv.Handler, http.MethodGet, "/docs", nil)
+	assertStatus(t, rr, http.StatusNotFound)
+}
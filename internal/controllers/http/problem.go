@@ -0,0 +1,128 @@
+package httpctrl
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+)
+
+// Error codes specific to the HTTP controller; the others come from the
+// thermostat package.
+const (
+	codeNotFound         thermostat.ErrorCode = "not_found"
+	codeMethodNotAllowed thermostat.ErrorCode = "method_not_allowed"
+	codeUnauthorized     thermostat.ErrorCode = "unauthorized"
+	codeForbidden        thermostat.ErrorCode = "forbidden"
+	codeInjectedFault    thermostat.ErrorCode = "injected_fault"
+	codeInvalidChaos     thermostat.ErrorCode = "invalid_chaos_settings"
+)
+
+// problem is an RFC 7807 problem details body. Code carries the stable error
+// code clients should match on; Detail is for humans.
+type problem struct {
+	Type   string               `json:"type"`
+	Title  string               `json:"title"`
+	Status int                  `json:"status"`
+	Detail string               `json:"detail,omitempty"`
+	Code   thermostat.ErrorCode `json:"code"`
+}
+
+// problemType is the problem "type" URI of an error code.
+func problemType(code thermostat.ErrorCode) string {
+	return "urn:thermocktat:problem:" + string(code)
+}
+
+// errorStatus maps an error code to its HTTP status:
+//   - 400: the request itself is malformed
+//   - 409: the value is valid but conflicts with the current state
+//   - 412: an If-Match precondition does not hold
+//   - 422: the value is well-formed but rejected
+func errorStatus(code thermostat.ErrorCode) int {
+	switch code {
+	case thermostat.CodeInvalidRequest:
+		return http.StatusBadRequest
+	case thermostat.CodeSetpointOutOfRange:
+		return http.StatusConflict
+	case thermostat.CodeVersionMismatch:
+		return http.StatusPreconditionFailed
+	case thermostat.CodeInternal:
+		return http.StatusInternalServerError
+	case codeNotFound:
+		return http.StatusNotFound
+	case codeMethodNotAllowed:
+		return http.StatusMethodNotAllowed
+	case codeUnauthorized:
+		return http.StatusUnauthorized
+	case codeForbidden:
+		return http.StatusForbidden
+	default:
+		return http.StatusUnprocessableEntity
+	}
+}
+
+func writeProblem(w http.ResponseWriter, code thermostat.ErrorCode, detail string) {
+	writeProblemStatus(w, errorStatus(code), code, detail)
+}
+
+// writeProblemStatus is writeProblem with a status that does not follow from
+// the code, e.g. the configurable status of injected faults.
+func writeProblemStatus(w http.ResponseWriter, status int, code thermostat.ErrorCode, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(problem{
+		Type:   problemType(code),
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	})
+}
+
+// writeError replies with the problem matching a service error.
+func writeError(w http.ResponseWriter, err error) {
+	writeProblem(w, thermostat.Code(err), err.Error())
+}
+
+// writeInvalidRequest replies to a malformed request body or parameter.
+func writeInvalidRequest(w http.ResponseWriter, detail string) {
+	writeProblem(w, thermostat.CodeInvalidRequest, detail)
+}
+
+// problemFallback replaces the mux's plain-text 404 and 405 replies with
+// problem details.
+func problemFallback(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); pattern == "" {
+			w = &fallbackWriter{ResponseWriter: w, r: r}
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+type fallbackWriter struct {
+	http.ResponseWriter
+	r       *http.Request
+	replied bool
+}
+
+func (fw *fallbackWriter) WriteHeader(status int) {
+	switch status {
+	case http.StatusNotFound:
+		writeProblem(fw.ResponseWriter, codeNotFound, "no route for "+fw.r.URL.Path)
+	case http.StatusMethodNotAllowed:
+		writeProblem(fw.ResponseWriter, codeMethodNotAllowed, fw.r.Method+" is not allowed on "+fw.r.URL.Path)
+	default:
+		fw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	fw.replied = true
+}
+
+// Write drops the mux's plain-text body once a problem has been written.
+func (fw *fallbackWriter) Write(b []byte) (int, error) {
+	if fw.replied {
+		return len(b), nil
+	}
+	return fw.ResponseWriter.Write(b)
+}
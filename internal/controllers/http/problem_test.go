@@ -0,0 +1,59 @@
+package httpctrl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+)
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		code thermostat.ErrorCode
+		want int
+	}{
+		{thermostat.CodeInvalidRequest, http.StatusBadRequest},
+		{thermostat.CodeSetpointOutOfRange, http.StatusConflict},
+		{thermostat.CodeInvalidMinMax, http.StatusUnprocessableEntity},
+		{thermostat.CodeInvalidMode, http.StatusUnprocessableEntity},
+		{thermostat.CodeInvalidStepDuration, http.StatusUnprocessableEntity},
+		{thermostat.CodeInternal, http.StatusInternalServerError},
+		{codeNotFound, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if got := errorStatus(tt.code); got != tt.want {
+			t.Errorf("errorStatus(%q)=%d want %d", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestProblem_UnknownRoute(t *testing.T) {
+	srv, _ := newTestServer()
+
+	rr := httptest.NewRecorder()
+	srv.srv.Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/nope", nil))
+	assertStatus(t, rr, http.StatusNotFound)
+	assertProblem(t, rr, codeNotFound)
+}
+
+func TestProblem_MethodNotAllowed(t *testing.T) {
+	srv, _ := newTestServer()
+
+	rr := httptest.NewRecorder()
+	srv.srv.Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/v1/mode", nil))
+	assertStatus(t, rr, http.StatusMethodNotAllowed)
+	assertProblem(t, rr, codeMethodNotAllowed)
+	if rr.Header().Get("Allow") == "" {
+		t.Fatal("405 should keep the Allow header")
+	}
+}
+
+func TestProblem_StepDurationRejectedByService(t *testing.T) {
+	srv, f := newTestServer()
+	f.StepErr = thermostat.ErrInvalidStepDuration
+
+	rr := doJSONRequest(t, srv.srv.Handler, http.MethodPost, "/v1/sim/step", map[string]any{"value": "-1s"})
+	assertStatus(t, rr, http.StatusUnprocessableEntity)
+	assertProblem(t, rr, thermostat.CodeInvalidStepDuration)
+}
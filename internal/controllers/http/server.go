@@ -1,45 +1,127 @@
 package httpctrl
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/Agrid-Dev/thermocktat/internal/buildinfo"
+	"github.com/Agrid-Dev/thermocktat/internal/controllers/wire"
 	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
 )
 
+// Config for the HTTP controller.
+type Config struct {
+	DeviceID string
+	Addr     string
+
+	// SwaggerUI serves an interactive page for the OpenAPI document at /docs.
+	// The Swagger UI assets are loaded by the browser from a public CDN.
+	SwaggerUI bool
+
+	// Dashboard serves an embedded single-page UI at /ui/ showing the live
+	// state, with controls for every writable attribute.
+	Dashboard bool
+
+	// Auth is disabled unless at least one credential source is configured.
+	Auth AuthConfig
+
+	// TLS serves HTTPS when a certificate is configured or generated.
+	TLS TLSConfig
+
+	// Chaos injects latency and faults into /v1 requests; it can also be
+	// changed at runtime through /v1/chaos.
+	Chaos ChaosConfig
+}
+
+// TLSConfig enables HTTPS with CertFile/KeyFile, or with an in-memory
+// self-signed certificate. ClientCAFile additionally requires clients to
+// present a certificate signed by one of its CAs (mutual TLS).
+type TLSConfig struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+	SelfSigned   bool
+}
+
+func (c TLSConfig) enabled() bool {
+	return c.CertFile != "" || c.SelfSigned
+}
+
 type Server struct {
 	svc      thermostat.Service
 	srv      *http.Server
+	events   *eventHub
+	chaos    *chaos
 	deviceID string
 	log      *slog.Logger
+
+	routes []string // registered mux patterns, documented in the OpenAPI spec
 }
 
 // New returns a runnable server.
-func New(svc thermostat.Service, addr string, deviceID string, logger *slog.Logger) *Server {
+func New(svc thermostat.Service, cfg Config, logger *slog.Logger) (*Server, error) {
 	if logger == nil {
 		logger = slog.New(slog.DiscardHandler)
 	}
+	auth, err := newAuthenticator(cfg.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("http auth: %w", err)
+	}
+	tlsConfig, err := newTLSConfig(cfg.TLS, cfg.Addr, logger)
+	if err != nil {
+		return nil, fmt.Errorf("http tls: %w", err)
+	}
+	chaos, err := newChaos(cfg.Chaos)
+	if err != nil {
+		return nil, fmt.Errorf("http chaos: %w", err)
+	}
 	mux := http.NewServeMux()
-	s := &Server{svc: svc, deviceID: deviceID, log: logger}
+	s := &Server{svc: svc, events: newEventHub(svc), chaos: chaos, deviceID: cfg.DeviceID, log: logger}
+	handle := func(pattern string, h http.HandlerFunc) {
+		mux.HandleFunc(pattern, h)
+		s.routes = append(s.routes, pattern)
+	}
 
 	// Read
-	mux.HandleFunc("GET /v1", s.handleGet)
+	handle("GET /v1", s.handleGet)
+	handle("GET /v1/weather", s.handleGetWeather)
+	handle("GET /v1/events", s.handleEvents)
+	handle("GET /v1/ws", s.handleWebSocket)
+	handle("GET /v1/simulation", s.handleGetSimulation)
+	handle("GET /v1/sim/status", s.handleGetSimStatus)
+
+	// Write: several variables at once, applied atomically
+	handle("PATCH /v1", s.handlePatch)
+	handle("PATCH /v1/simulation", s.handlePatchSimulation)
+
+	// Simulation control
+	handle("POST /v1/sim/pause", s.handlePostSimPause)
+	handle("POST /v1/sim/resume", s.handlePostSimResume)
+	handle("POST /v1/sim/ambient_temperature", s.handlePostSimAmbient)
+	handle("POST /v1/sim/step", s.handlePostSimStep)
+
+	// Fault injection
+	handle("GET "+chaosPath, s.handleGetChaos)
+	handle("PATCH "+chaosPath, s.handlePatchChaos)
 
 	// Write: one endpoint per variable
-	mux.HandleFunc("POST /v1/enabled", s.handlePostEnabled)
-	mux.HandleFunc("POST /v1/temperature_setpoint", s.handlePostSetpoint)
-	mux.HandleFunc("POST /v1/temperature_setpoint_min", s.handlePostMinSetpoint)
-	mux.HandleFunc("POST /v1/temperature_setpoint_max", s.handlePostMaxSetpoint)
-	mux.HandleFunc("POST /v1/mode", s.handlePostMode)
-	mux.HandleFunc("POST /v1/fan_speed", s.handlePostFanSpeed)
-	mux.HandleFunc("POST /v1/fault_code", s.handlePostFaultCode)
-
-	mux.HandleFunc("GET /version", func(w http.ResponseWriter, _ *http.Request) {
+	handle("POST /v1/enabled", s.handlePostEnabled)
+	handle("POST /v1/temperature_setpoint", s.handlePostSetpoint)
+	handle("POST /v1/temperature_setpoint_min", s.handlePostMinSetpoint)
+	handle("POST /v1/temperature_setpoint_max", s.handlePostMaxSetpoint)
+	handle("POST /v1/mode", s.handlePostMode)
+	handle("POST /v1/fan_speed", s.handlePostFanSpeed)
+	handle("POST /v1/fault_code", s.handlePostFaultCode)
+
+	handle("GET /version", func(w http.ResponseWriter, _ *http.Request) {
 		writeJSON(w, http.StatusOK, map[string]string{
 			"version": buildinfo.Version,
 			"commit":  buildinfo.Commit,
@@ -47,17 +129,27 @@ func New(svc thermostat.Service, addr string, deviceID string, logger *slog.Logg
 		})
 	})
 
-	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+	handle("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("ok"))
 	})
 
+	// API description
+	handle("GET /openapi.json", s.handleOpenAPI)
+	if cfg.SwaggerUI {
+		handle("GET /docs", handleSwaggerUI)
+	}
+	if cfg.Dashboard {
+		handle("GET "+dashboardPath, handleDashboard())
+	}
+
 	s.srv = &http.Server{
-		Addr:              addr,
-		Handler:           logRequest(logger, mux),
+		Addr:              cfg.Addr,
+		Handler:           logRequest(logger, injectFaults(chaos, logger, requireAuth(auth, problemFallback(mux)))),
+		TLSConfig:         tlsConfig,
 		ReadHeaderTimeout: 5 * time.Second,
 	}
-	return s
+	return s, nil
 }
 
 // logRequest wraps an http.Handler to emit a debug line per request.
@@ -83,11 +175,34 @@ func (sw *statusWriter) WriteHeader(code int) {
 	sw.ResponseWriter.WriteHeader(code)
 }
 
+// Hijack hands the connection over for protocol upgrades (WebSocket).
+func (sw *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	sw.status = http.StatusSwitchingProtocols
+	return http.NewResponseController(sw.ResponseWriter).Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer (e.g. to
+// flush event streams).
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
 func (s *Server) Run(ctx context.Context) error {
 	errCh := make(chan error, 1)
 
+	// Request contexts derive from ctx so long-lived streams end on shutdown.
+	s.srv.BaseContext = func(net.Listener) context.Context { return ctx }
+	go s.events.run(ctx)
+
 	go func() {
-		if err := s.srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		var err error
+		if s.srv.TLSConfig != nil {
+			// Certificates are already in TLSConfig.
+			err = s.srv.ListenAndServeTLS("", "")
+		} else {
+			err = s.srv.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			errCh <- err
 			return
 		}
@@ -117,6 +232,7 @@ type snapshotDTO struct {
 	FanSpeed               string  `json:"fan_speed"`
 	AmbientTemperature     float64 `json:"ambient_temperature"`
 	FaultCode              int     `json:"fault_code"`
+	Version                uint64  `json:"version"`
 }
 
 func toDTO(s thermostat.Snapshot) snapshotDTO {
@@ -129,98 +245,183 @@ func toDTO(s thermostat.Snapshot) snapshotDTO {
 		FanSpeed:               s.FanSpeed.String(),
 		AmbientTemperature:     s.AmbientTemperature,
 		FaultCode:              s.FaultCode,
+		Version:                s.Version,
+	}
+}
+
+type weatherStatusDTO struct {
+	Provider            string     `json:"provider"`
+	Healthy             bool       `json:"healthy"`
+	OutdoorTemperature  float64    `json:"outdoor_temperature"`
+	LastSuccess         *time.Time `json:"last_success"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastErrorAt         *time.Time `json:"last_error_at,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	ProviderSwitches    int        `json:"provider_switches"`
+}
+
+func toWeatherStatusDTO(st thermostat.WeatherStatus) weatherStatusDTO {
+	dto := weatherStatusDTO{
+		Provider:            st.Provider,
+		Healthy:             !st.LastSuccess.IsZero() && st.ConsecutiveFailures == 0,
+		OutdoorTemperature:  st.OutdoorTemperature,
+		LastError:           st.LastError,
+		ConsecutiveFailures: st.ConsecutiveFailures,
+		ProviderSwitches:    st.ProviderSwitches,
+	}
+	if !st.LastSuccess.IsZero() {
+		dto.LastSuccess = &st.LastSuccess
 	}
+	if !st.LastErrorAt.IsZero() {
+		dto.LastErrorAt = &st.LastErrorAt
+	}
+	return dto
 }
 
 // ---- Handlers ----
 
-func (s *Server) handleGet(w http.ResponseWriter, _ *http.Request) {
-	s.respondSnapshot(w)
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
+	snap := s.svc.Get()
+	dto := s.snapshotDTO(snap)
+	tag := etag(snap.Version, dto)
+	if inm := r.Header.Get("If-None-Match"); inm != "" && matchesETag(inm, tag) {
+		w.Header().Set("ETag", tag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", tag)
+	writeJSON(w, http.StatusOK, dto)
+}
+
+func (s *Server) handlePatch(w http.ResponseWriter, r *http.Request) {
+	// body: partial snapshot, e.g. {"temperature_setpoint_min": 10, "temperature_setpoint": 12}
+	p, err := wire.DecodePatch(r.Body)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	s.applyPatch(w, r, p)
+}
+
+func (s *Server) handleGetWeather(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, toWeatherStatusDTO(s.svc.WeatherStatus()))
 }
 
+// Single-attribute writes go through ApplyPatch too, so that If-Match is
+// checked atomically with the write.
+
 func (s *Server) handlePostEnabled(w http.ResponseWriter, r *http.Request) {
-	postValue(s, w, r, func(v bool) error {
-		s.svc.SetEnabled(v)
-		return nil
+	postValue(s, w, r, func(v bool) (thermostat.Patch, error) {
+		return thermostat.Patch{Enabled: &v}, nil
 	})
 }
 
 func (s *Server) handlePostSetpoint(w http.ResponseWriter, r *http.Request) {
-	postValue(s, w, r, func(v float64) error {
-		return s.svc.SetSetpoint(v)
+	postValue(s, w, r, func(v float64) (thermostat.Patch, error) {
+		return thermostat.Patch{TemperatureSetpoint: &v}, nil
 	})
 }
 
 func (s *Server) handlePostMinSetpoint(w http.ResponseWriter, r *http.Request) {
-	postValue(s, w, r, func(v float64) error {
-		cur := s.svc.Get()
-		return s.svc.SetMinMax(v, cur.TemperatureSetpointMax)
+	postValue(s, w, r, func(v float64) (thermostat.Patch, error) {
+		return thermostat.Patch{TemperatureSetpointMin: &v}, nil
 	})
 }
 
 func (s *Server) handlePostMaxSetpoint(w http.ResponseWriter, r *http.Request) {
-	postValue(s, w, r, func(v float64) error {
-		cur := s.svc.Get()
-		return s.svc.SetMinMax(cur.TemperatureSetpointMin, v)
+	postValue(s, w, r, func(v float64) (thermostat.Patch, error) {
+		return thermostat.Patch{TemperatureSetpointMax: &v}, nil
 	})
 }
 
 func (s *Server) handlePostMode(w http.ResponseWriter, r *http.Request) {
 	// body: {"value": "heat"}
-	postValue(s, w, r, func(v string) error {
+	postValue(s, w, r, func(v string) (thermostat.Patch, error) {
 		m, err := thermostat.ParseMode(v)
-		if err != nil {
-			return err
-		}
-		return s.svc.SetMode(m)
+		return thermostat.Patch{Mode: &m}, err
 	})
 }
 
 func (s *Server) handlePostFanSpeed(w http.ResponseWriter, r *http.Request) {
 	// body: {"value": "high"}
-	postValue(s, w, r, func(v string) error {
+	postValue(s, w, r, func(v string) (thermostat.Patch, error) {
 		f, err := thermostat.ParseFanSpeed(v)
-		if err != nil {
-			return err
-		}
-		return s.svc.SetFanSpeed(f)
+		return thermostat.Patch{FanSpeed: &f}, err
 	})
 }
 
 func (s *Server) handlePostFaultCode(w http.ResponseWriter, r *http.Request) {
-	postValue(s, w, r, func(v int) error {
-		s.svc.SetFaultCode(v)
-		return nil
+	postValue(s, w, r, func(v int) (thermostat.Patch, error) {
+		return thermostat.Patch{FaultCode: &v}, nil
 	})
 }
 
 // ---- generic helpers ----
 func (s *Server) respondSnapshot(w http.ResponseWriter) {
-	dto := toDTO(s.svc.Get())
-	dto.DeviceID = s.deviceID
+	s.writeSnapshot(w, s.svc.Get())
+}
+
+// writeSnapshot replies with snap and its ETag.
+func (s *Server) writeSnapshot(w http.ResponseWriter, snap thermostat.Snapshot) {
+	dto := s.snapshotDTO(snap)
+	w.Header().Set("ETag", etag(snap.Version, dto))
 	writeJSON(w, http.StatusOK, dto)
 }
 
-func postValue[T any](s *Server, w http.ResponseWriter, r *http.Request, apply func(T) error) {
-	dec := json.NewDecoder(r.Body)
-	var req struct {
-		Value *T `json:"value"`
+func (s *Server) snapshotDTO(snap thermostat.Snapshot) snapshotDTO {
+	dto := toDTO(snap)
+	dto.DeviceID = s.deviceID
+	return dto
+}
+
+// applyPatch applies p if the If-Match precondition holds, then replies with
+// the updated snapshot.
+func (s *Server) applyPatch(w http.ResponseWriter, r *http.Request, p thermostat.Patch) {
+	if im := strings.TrimSpace(r.Header.Get("If-Match")); im != "" && im != "*" {
+		cur := s.svc.Get().Version
+		if !matchesVersion(im, cur) {
+			writeError(w, thermostat.ErrVersionMismatch)
+			return
+		}
+		// Re-checked by ApplyPatch, in case another write lands in between.
+		p.IfVersion = &cur
 	}
-	if err := dec.Decode(&req); err != nil {
-		writeErr(w, http.StatusBadRequest, "invalid json")
+	if err := s.svc.ApplyPatch(p); err != nil {
+		writeError(w, err)
 		return
 	}
-	if req.Value == nil {
-		writeErr(w, http.StatusBadRequest, "missing field 'value'")
+	s.respondSnapshot(w)
+}
+
+func postValue[T any](s *Server, w http.ResponseWriter, r *http.Request, toPatch func(T) (thermostat.Patch, error)) {
+	v, ok := decodeValue[T](w, r)
+	if !ok {
 		return
 	}
-
-	if err := apply(*req.Value); err != nil {
-		writeErr(w, http.StatusBadRequest, err.Error())
+	p, err := toPatch(v)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
+	s.applyPatch(w, r, p)
+}
 
-	s.respondSnapshot(w)
+// decodeValue reads a {"value": ...} body, replying with an error if invalid.
+func decodeValue[T any](w http.ResponseWriter, r *http.Request) (T, bool) {
+	var zero T
+	dec := json.NewDecoder(r.Body)
+	var req struct {
+		Value *T `json:"value"`
+	}
+	if err := dec.Decode(&req); err != nil {
+		writeInvalidRequest(w, "invalid json")
+		return zero, false
+	}
+	if req.Value == nil {
+		writeInvalidRequest(w, "missing field 'value'")
+		return zero, false
+	}
+	return *req.Value, true
 }
 
 func writeJSON(w http.ResponseWriter, code int, v any) {
@@ -228,7 +429,3 @@ func writeJSON(w http.ResponseWriter, code int, v any) {
 	w.WriteHeader(code)
 	_ = json.NewEncoder(w).Encode(v)
 }
-
-func writeErr(w http.ResponseWriter, code int, msg string) {
-	writeJSON(w, code, map[string]string{"error": msg})
-}
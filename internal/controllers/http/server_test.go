@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/Agrid-Dev/thermocktat/internal/testutil"
 	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
@@ -37,8 +38,8 @@ func TestPOST_mode_Valid(t *testing.T) {
 	})
 	assertStatus(t, rr, http.StatusOK)
 
-	if !f.SetModeCalled || f.SetModeArg != thermostat.ModeHeat {
-		t.Fatalf("expected SetMode(Heat) called, got called=%v arg=%v", f.SetModeCalled, f.SetModeArg)
+	if p := f.ApplyPatchArg; !f.ApplyPatchCalled || p.Mode == nil || *p.Mode != thermostat.ModeHeat {
+		t.Fatalf("expected mode heat applied, got called=%v patch=%+v", f.ApplyPatchCalled, p)
 	}
 }
 
@@ -50,7 +51,7 @@ func TestPOST_mode_InvalidPayload(t *testing.T) {
 		"mode": "weird",
 	})
 	assertStatus(t, rr, http.StatusBadRequest)
-	_ = assertErrorResponse(t, rr)
+	assertProblem(t, rr, thermostat.CodeInvalidRequest)
 }
 
 func TestPOST_mode_InvalidString(t *testing.T) {
@@ -59,19 +60,19 @@ func TestPOST_mode_InvalidString(t *testing.T) {
 	rr := doJSONRequest(t, srv.srv.Handler, http.MethodPost, "/v1/mode", map[string]any{
 		"value": "weird",
 	})
-	assertStatus(t, rr, http.StatusBadRequest)
-	_ = assertErrorResponse(t, rr)
+	assertStatus(t, rr, http.StatusUnprocessableEntity)
+	assertProblem(t, rr, thermostat.CodeInvalidMode)
 }
 
 func TestPOST_setpoint_ErrorFromService(t *testing.T) {
 	srv, f := newTestServer()
-	f.SetSetpointErr = thermostat.ErrSetpointOutOfRange
+	f.ApplyPatchErr = thermostat.ErrSetpointOutOfRange
 
 	rr := doJSONRequest(t, srv.srv.Handler, http.MethodPost, "/v1/temperature_setpoint", map[string]any{
 		"value": 999,
 	})
-	assertStatus(t, rr, http.StatusBadRequest)
-	_ = assertErrorResponse(t, rr)
+	assertStatus(t, rr, http.StatusConflict)
+	assertProblem(t, rr, thermostat.CodeSetpointOutOfRange)
 }
 
 func TestPOST_enabled(t *testing.T) {
@@ -91,8 +92,8 @@ func TestPOST_fan_speed(t *testing.T) {
 	rr := postValueEndpoint(t, srv, "/v1/fan_speed", "high")
 	assertStatus(t, rr, http.StatusOK)
 
-	if !f.SetFanSpeedCalled || f.SetFanSpeedArg != thermostat.FanHigh {
-		t.Fatalf("expected SetFanSpeedSpeed(High), got called=%v arg=%v", f.SetFanSpeedCalled, f.SetFanSpeedArg)
+	if p := f.ApplyPatchArg; !f.ApplyPatchCalled || p.FanSpeed == nil || *p.FanSpeed != thermostat.FanHigh {
+		t.Fatalf("expected fan speed high applied, got called=%v patch=%+v", f.ApplyPatchCalled, p)
 	}
 }
 
@@ -108,10 +109,10 @@ func TestPOST_min_setpoint(t *testing.T) {
 	}
 
 	// Test invalid min setpoint (greater than current max)
-	f.SetMinMaxErr = thermostat.ErrInvalidMinMax
+	f.ApplyPatchErr = thermostat.ErrInvalidMinMax
 	rr = postValueEndpoint(t, srv, "/v1/temperature_setpoint_min", 30.0)
-	assertStatus(t, rr, http.StatusBadRequest)
-	_ = assertErrorResponse(t, rr)
+	assertStatus(t, rr, http.StatusUnprocessableEntity)
+	assertProblem(t, rr, thermostat.CodeInvalidMinMax)
 }
 
 func TestPOST_max_setpoint(t *testing.T) {
@@ -126,10 +127,10 @@ func TestPOST_max_setpoint(t *testing.T) {
 	}
 
 	// Test invalid max setpoint (less than current min)
-	f.SetMinMaxErr = thermostat.ErrInvalidMinMax
+	f.ApplyPatchErr = thermostat.ErrInvalidMinMax
 	rr = postValueEndpoint(t, srv, "/v1/temperature_setpoint_max", 15.0)
-	assertStatus(t, rr, http.StatusBadRequest)
-	_ = assertErrorResponse(t, rr)
+	assertStatus(t, rr, http.StatusUnprocessableEntity)
+	assertProblem(t, rr, thermostat.CodeInvalidMinMax)
 }
 
 func TestPOST_fault_code(t *testing.T) {
@@ -138,8 +139,8 @@ func TestPOST_fault_code(t *testing.T) {
 	rr := postValueEndpoint(t, srv, "/v1/fault_code", 7)
 	assertStatus(t, rr, http.StatusOK)
 
-	if !f.SetFaultCodeCalled || f.SetFaultCodeArg != 7 {
-		t.Fatalf("expected SetFaultCode(7), got called=%v arg=%v", f.SetFaultCodeCalled, f.SetFaultCodeArg)
+	if p := f.ApplyPatchArg; !f.ApplyPatchCalled || p.FaultCode == nil || *p.FaultCode != 7 {
+		t.Fatalf("expected fault code 7 applied, got called=%v patch=%+v", f.ApplyPatchCalled, p)
 	}
 
 	got := decodeJSON[map[string]any](t, rr)
@@ -148,6 +149,112 @@ func TestPOST_fault_code(t *testing.T) {
 	}
 }
 
+func TestPATCH_v1_AppliesAllFields(t *testing.T) {
+	srv, f := newTestServer()
+
+	rr := doJSONRequest(t, srv.srv.Handler, http.MethodPatch, "/v1", map[string]any{
+		"temperature_setpoint_min": 10,
+		"temperature_setpoint_max": 15,
+		"temperature_setpoint":     12,
+		"mode":                     "heat",
+	})
+	assertStatus(t, rr, http.StatusOK)
+
+	if !f.ApplyPatchCalled {
+		t.Fatal("expected ApplyPatch called")
+	}
+	p := f.ApplyPatchArg
+	if p.TemperatureSetpointMin == nil || *p.TemperatureSetpointMin != 10 ||
+		p.TemperatureSetpointMax == nil || *p.TemperatureSetpointMax != 15 ||
+		p.TemperatureSetpoint == nil || *p.TemperatureSetpoint != 12 ||
+		p.Mode == nil || *p.Mode != thermostat.ModeHeat {
+		t.Fatalf("unexpected patch: %+v", p)
+	}
+	if p.Enabled != nil || p.FanSpeed != nil || p.FaultCode != nil {
+		t.Fatalf("absent fields must stay nil: %+v", p)
+	}
+
+	got := decodeJSON[map[string]any](t, rr)
+	if got["temperature_setpoint"] != float64(12) || got["mode"] != "heat" {
+		t.Fatalf("expected patched snapshot in response, got %v", got)
+	}
+}
+
+func TestPATCH_v1_Rejections(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   any
+		status int
+		code   thermostat.ErrorCode
+	}{
+		{"unknown field", map[string]any{"ambient_temperature": 30}, http.StatusBadRequest, thermostat.CodeInvalidRequest},
+		{"invalid mode", map[string]any{"mode": "weird", "enabled": false}, http.StatusUnprocessableEntity, thermostat.CodeInvalidMode},
+		{"invalid fan speed", map[string]any{"fan_speed": "turbo"}, http.StatusUnprocessableEntity, thermostat.CodeInvalidFanSpeed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, f := newTestServer()
+
+			rr := doJSONRequest(t, srv.srv.Handler, http.MethodPatch, "/v1", tt.body)
+			assertStatus(t, rr, tt.status)
+			assertProblem(t, rr, tt.code)
+			if f.ApplyPatchCalled {
+				t.Fatal("expected ApplyPatch not called")
+			}
+		})
+	}
+}
+
+func TestPATCH_v1_ErrorFromService(t *testing.T) {
+	srv, f := newTestServer()
+	f.ApplyPatchErr = thermostat.ErrSetpointOutOfRange
+
+	rr := doJSONRequest(t, srv.srv.Handler, http.MethodPatch, "/v1", map[string]any{
+		"temperature_setpoint_max": 18,
+	})
+	assertStatus(t, rr, http.StatusConflict)
+	assertProblem(t, rr, thermostat.CodeSetpointOutOfRange)
+}
+
+func TestGET_weather(t *testing.T) {
+	srv, f := newTestServer()
+	f.Weather = thermostat.WeatherStatus{
+		Provider:            "static",
+		OutdoorTemperature:  5,
+		LastSuccess:         time.Date(2026, 6, 26, 14, 0, 0, 0, time.UTC),
+		LastError:           "open-meteo: status 502",
+		LastErrorAt:         time.Date(2026, 6, 26, 13, 0, 0, 0, time.UTC),
+		ConsecutiveFailures: 0,
+		ProviderSwitches:    2,
+	}
+
+	rr := doJSONRequest(t, srv.srv.Handler, http.MethodGet, "/v1/weather", nil)
+	assertStatus(t, rr, http.StatusOK)
+
+	got := decodeJSON[map[string]any](t, rr)
+	if got["provider"] != "static" || got["healthy"] != true || got["outdoor_temperature"] != float64(5) {
+		t.Fatalf("unexpected weather status: %v", got)
+	}
+	if got["last_error"] != "open-meteo: status 502" {
+		t.Fatalf("expected last_error to be reported, got %v", got["last_error"])
+	}
+	if got["provider_switches"] != float64(2) {
+		t.Fatalf("expected provider_switches 2, got %v", got["provider_switches"])
+	}
+}
+
+func TestGET_weather_BeforeFirstRefresh(t *testing.T) {
+	srv, _ := newTestServer()
+
+	rr := doJSONRequest(t, srv.srv.Handler, http.MethodGet, "/v1/weather", nil)
+	assertStatus(t, rr, http.StatusOK)
+
+	got := decodeJSON[map[string]any](t, rr)
+	if got["healthy"] != false || got["last_success"] != nil {
+		t.Fatalf("expected unhealthy status without success, got %v", got)
+	}
+}
+
 func TestGET_healthz(t *testing.T) {
 	srv, _ := newTestServer()
 
@@ -165,8 +272,11 @@ func TestGET_healthz(t *testing.T) {
 
 func newTestServer() (*Server, *testutil.FakeThermostatService) {
 	f := testutil.NewFakeThermostatService()
-	deviceID := "default"
-	return New(f, ":0", deviceID, nil), f
+	srv, err := New(f, Config{DeviceID: "default", Addr: ":0"}, nil)
+	if err != nil {
+		panic(err)
+	}
+	return srv, f
 }
 
 func doJSONRequest(t *testing.T, h http.Handler, method, path string, body any) *httptest.ResponseRecorder {
@@ -206,18 +316,21 @@ func decodeJSON[T any](t *testing.T, rr *httptest.ResponseRecorder) T {
 }
 
 // Handy when you only care about error responses.
-func assertErrorResponse(t *testing.T, rr *httptest.ResponseRecorder) string {
+// assertProblem checks an RFC 7807 response with the given code and returns
+// its detail.
+func assertProblem(t *testing.T, rr *httptest.ResponseRecorder, code thermostat.ErrorCode) string {
 	t.Helper()
-	var resp struct {
-		Error string `json:"error"`
+	if ct := rr.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Fatalf("expected application/problem+json, got %q", ct)
+	}
+	p := decodeJSON[problem](t, rr)
+	if p.Code != code || p.Type != problemType(code) || p.Status != rr.Code {
+		t.Fatalf("expected problem %q with status %d, got %+v", code, rr.Code, p)
 	}
-	resp = decodeJSON[struct {
-		Error string `json:"error"`
-	}](t, rr)
-	if resp.Error == "" {
-		t.Fatalf("expected non-empty error field, got body=%s", rr.Body.String())
+	if p.Detail == "" {
+		t.Fatalf("expected non-empty detail, got body=%s", rr.Body.String())
 	}
-	return resp.Error
+	return p.Detail
 }
 
 func postValueEndpoint[T any](t *testing.T, srv *Server, path string, value T) *httptest.ResponseRecorder {
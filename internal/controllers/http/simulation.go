@@ -0,0 +1,88 @@
+package httpctrl
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Agrid-Dev/thermocktat/internal/controllers/wire"
+)
+
+func (s *Server) handleGetSimulation(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, wire.FromSimulation(s.svc.Simulation()))
+}
+
+func (s *Server) handlePatchSimulation(w http.ResponseWriter, r *http.Request) {
+	// body: partial simulation, e.g. {"regulator": {"kp": 0.5}, "heat_loss": {"outdoor_temperature_override": null}}
+	p, err := wire.DecodeSimulationPatch(r.Body)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.svc.ApplySimulationPatch(p); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, wire.FromSimulation(s.svc.Simulation()))
+}
+
+type simStatusDTO struct {
+	Paused             bool    `json:"paused"`
+	Regulation         string  `json:"regulation"`
+	AmbientTemperature float64 `json:"ambient_temperature"`
+	OutdoorTemperature float64 `json:"outdoor_temperature"`
+}
+
+func (s *Server) respondSimStatus(w http.ResponseWriter) {
+	st := s.svc.SimulationState()
+	writeJSON(w, http.StatusOK, simStatusDTO{
+		Paused:             st.Paused,
+		Regulation:         st.Regulation.String(),
+		AmbientTemperature: s.svc.Get().AmbientTemperature,
+		OutdoorTemperature: s.svc.Simulation().OutdoorTemperature,
+	})
+}
+
+func (s *Server) handleGetSimStatus(w http.ResponseWriter, _ *http.Request) {
+	s.respondSimStatus(w)
+}
+
+func (s *Server) handlePostSimPause(w http.ResponseWriter, _ *http.Request) {
+	s.svc.SetPaused(true)
+	s.respondSimStatus(w)
+}
+
+func (s *Server) handlePostSimResume(w http.ResponseWriter, _ *http.Request) {
+	s.svc.SetPaused(false)
+	s.respondSimStatus(w)
+}
+
+func (s *Server) handlePostSimAmbient(w http.ResponseWriter, r *http.Request) {
+	// body: {"value": 30}
+	v, ok := decodeValue[float64](w, r)
+	if !ok {
+		return
+	}
+	if err := s.svc.SetAmbientTemperature(v); err != nil {
+		writeError(w, err)
+		return
+	}
+	s.respondSimStatus(w)
+}
+
+func (s *Server) handlePostSimStep(w http.ResponseWriter, r *http.Request) {
+	// body: {"value": "15m"}
+	v, ok := decodeValue[string](w, r)
+	if !ok {
+		return
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		writeInvalidRequest(w, "invalid duration: "+err.Error())
+		return
+	}
+	if err := s.svc.Step(d); err != nil {
+		writeError(w, err)
+		return
+	}
+	s.respondSimStatus(w)
+}
@@ -0,0 +1,188 @@
+package httpctrl
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Agrid-Dev/thermocktat/internal/controllers/wire"
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+)
+
+func TestGET_simulation(t *testing.T) {
+	srv, f := newTestServer()
+	override := 5.0
+	f.Sim = thermostat.SimulationParams{
+		Regulator:                  thermostat.PIDRegulatorParams{Kp: 1, TargetHysteresis: 1, ModeChangeHysteresis: 2},
+		HeatLossCoefficient:        0.001,
+		OutdoorTemperature:         5,
+		OutdoorTemperatureOverride: &override,
+		RegulationInterval:         time.Second,
+	}
+
+	rr := doJSONRequest(t, srv.srv.Handler, http.MethodGet, "/v1/simulation", nil)
+	assertStatus(t, rr, http.StatusOK)
+
+	got := decodeJSON[wire.Simulation](t, rr)
+	if got.Regulator.Kp != 1 || got.Regulator.Interval != "1s" {
+		t.Fatalf("unexpected regulator %+v", got.Regulator)
+	}
+	if got.HeatLoss.OutdoorTemperatureOverride == nil || *got.HeatLoss.OutdoorTemperatureOverride != 5 {
+		t.Fatalf("unexpected heat loss %+v", got.HeatLoss)
+	}
+}
+
+func TestPATCH_simulation(t *testing.T) {
+	srv, f := newTestServer()
+
+	rr := doJSONRequest(t, srv.srv.Handler, http.MethodPatch, "/v1/simulation", map[string]any{
+		"regulator": map[string]any{"kp": 0.5, "interval": "250ms"},
+		"heat_loss": map[string]any{"coefficient": 0.01, "outdoor_temperature_override": 35},
+	})
+	assertStatus(t, rr, http.StatusOK)
+
+	p := f.ApplySimulationPatchArg
+	if p.Kp == nil || *p.Kp != 0.5 || p.Ki != nil {
+		t.Fatalf("unexpected regulator patch %+v", p)
+	}
+	if p.RegulationInterval == nil || *p.RegulationInterval != 250*time.Millisecond {
+		t.Fatalf("unexpected interval %v", p.RegulationInterval)
+	}
+	if p.HeatLossCoefficient == nil || *p.HeatLossCoefficient != 0.01 {
+		t.Fatalf("unexpected coefficient %v", p.HeatLossCoefficient)
+	}
+	if p.OutdoorTemperatureOverride == nil || *p.OutdoorTemperatureOverride != 35 || p.ClearOutdoorTemperatureOverride {
+		t.Fatalf("unexpected override %+v", p)
+	}
+	if got := decodeJSON[wire.Simulation](t, rr); got.Regulator.Interval != "250ms" {
+		t.Fatalf("response should reflect the update, got %+v", got)
+	}
+}
+
+func TestPATCH_simulation_NullClearsOverride(t *testing.T) {
+	srv, f := newTestServer()
+
+	rr := doJSONRequest(t, srv.srv.Handler, http.MethodPatch, "/v1/simulation", map[string]any{
+		"heat_loss": map[string]any{"outdoor_temperature_override": nil},
+	})
+	assertStatus(t, rr, http.StatusOK)
+	if p := f.ApplySimulationPatchArg; !p.ClearOutdoorTemperatureOverride || p.OutdoorTemperatureOverride != nil {
+		t.Fatalf("expected override cleared, got %+v", p)
+	}
+}
+
+func TestPATCH_simulation_Rejections(t *testing.T) {
+	tests := []struct {
+		name string
+		body any
+	}{
+		{"unknown field", map[string]any{"regulator": map[string]any{"kq": 1}}},
+		{"read-only field", map[string]any{"heat_loss": map[string]any{"outdoor_temperature": 3}}},
+		{"bad interval", map[string]any{"regulator": map[string]any{"interval": "soon"}}},
+		{"bad override", map[string]any{"heat_loss": map[string]any{"outdoor_temperature_override": "warm"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, f := newTestServer()
+			rr := doJSONRequest(t, srv.srv.Handler, http.MethodPatch, "/v1/simulation", tt.body)
+			assertStatus(t, rr, http.StatusBadRequest)
+			assertProblem(t, rr, thermostat.CodeInvalidRequest)
+			if f.ApplySimulationPatchCalled {
+				t.Fatal("service must not be called")
+			}
+		})
+	}
+}
+
+func TestPATCH_simulation_ErrorFromService(t *testing.T) {
+	srv, f := newTestServer()
+	f.ApplySimulationPatchErr = thermostat.ErrInvalidRegulatorHysteresis
+
+	rr := doJSONRequest(t, srv.srv.Handler, http.MethodPatch, "/v1/simulation", map[string]any{
+		"regulator": map[string]any{"target_hysteresis": 5},
+	})
+	assertStatus(t, rr, http.StatusUnprocessableEntity)
+	if msg := assertProblem(t, rr, thermostat.CodeInvalidRegulatorHysteresis); msg != thermostat.ErrInvalidRegulatorHysteresis.Error() {
+		t.Fatalf("expected service error, got %q", msg)
+	}
+}
+
+func TestPATCH_simulation_UncodedErrorIsInternal(t *testing.T) {
+	srv, f := newTestServer()
+	f.ApplySimulationPatchErr = errors.New("boom")
+
+	rr := doJSONRequest(t, srv.srv.Handler, http.MethodPatch, "/v1/simulation", map[string]any{
+		"heat_loss": map[string]any{"coefficient": 0.1},
+	})
+	assertStatus(t, rr, http.StatusInternalServerError)
+	assertProblem(t, rr, thermostat.CodeInternal)
+}
+
+func TestSim_PauseResume(t *testing.T) {
+	srv, f := newTestServer()
+	f.State.Regulation = thermostat.RegulationHeating
+
+	rr := doJSONRequest(t, srv.srv.Handler, http.MethodPost, "/v1/sim/pause", nil)
+	assertStatus(t, rr, http.StatusOK)
+	got := decodeJSON[simStatusDTO](t, rr)
+	if !got.Paused || got.Regulation != "heating" || !f.State.Paused {
+		t.Fatalf("expected paused heating, got %+v", got)
+	}
+
+	rr = doJSONRequest(t, srv.srv.Handler, http.MethodPost, "/v1/sim/resume", nil)
+	assertStatus(t, rr, http.StatusOK)
+	if got := decodeJSON[simStatusDTO](t, rr); got.Paused || f.State.Paused {
+		t.Fatalf("expected resumed, got %+v", got)
+	}
+
+	rr = doJSONRequest(t, srv.srv.Handler, http.MethodGet, "/v1/sim/status", nil)
+	assertStatus(t, rr, http.StatusOK)
+	if got := decodeJSON[simStatusDTO](t, rr); got.Paused {
+		t.Fatalf("status should report resumed, got %+v", got)
+	}
+}
+
+func TestPOST_simAmbientTemperature(t *testing.T) {
+	srv, f := newTestServer()
+
+	rr := doJSONRequest(t, srv.srv.Handler, http.MethodPost, "/v1/sim/ambient_temperature", map[string]any{"value": 30})
+	assertStatus(t, rr, http.StatusOK)
+	if !f.SetAmbientTemperatureCalled || f.SetAmbientTemperatureArg != 30 {
+		t.Fatalf("expected SetAmbientTemperature(30), got %v", f.SetAmbientTemperatureArg)
+	}
+	if got := decodeJSON[simStatusDTO](t, rr); got.AmbientTemperature != 30 {
+		t.Fatalf("response should reflect the new ambient temperature, got %+v", got)
+	}
+}
+
+func TestPOST_simStep(t *testing.T) {
+	srv, f := newTestServer()
+
+	rr := doJSONRequest(t, srv.srv.Handler, http.MethodPost, "/v1/sim/step", map[string]any{"value": "15m"})
+	assertStatus(t, rr, http.StatusOK)
+	if !f.StepCalled || f.StepArg != 15*time.Minute {
+		t.Fatalf("expected Step(15m), got %v", f.StepArg)
+	}
+}
+
+func TestPOST_simStep_Rejections(t *testing.T) {
+	tests := []struct {
+		name string
+		body any
+	}{
+		{"not a duration", map[string]any{"value": "soon"}},
+		{"not a string", map[string]any{"value": 900}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, f := newTestServer()
+			rr := doJSONRequest(t, srv.srv.Handler, http.MethodPost, "/v1/sim/step", tt.body)
+			assertStatus(t, rr, http.StatusBadRequest)
+			assertProblem(t, rr, thermostat.CodeInvalidRequest)
+			if f.StepCalled {
+				t.Fatal("service must not be called")
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package httpctrl
+
+import (
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net"
+
+	"github.com/Agrid-Dev/thermocktat/internal/tlsutil"
+)
+
+// newTLSConfig returns nil when TLS is disabled.
+func newTLSConfig(cfg TLSConfig, addr string, log *slog.Logger) (*tls.Config, error) {
+	if !cfg.enabled() {
+		if cfg.ClientCAFile != "" || cfg.KeyFile != "" {
+			return nil, errors.New("client_ca_file and key_file need cert_file or self_signed")
+		}
+		return nil, nil
+	}
+
+	var cert tls.Certificate
+	var err error
+	switch {
+	case cfg.CertFile != "" && cfg.SelfSigned:
+		return nil, errors.New("cert_file and self_signed are mutually exclusive")
+	case cfg.CertFile != "":
+		if cfg.KeyFile == "" {
+			return nil, errors.New("cert_file requires key_file")
+		}
+		if cert, err = tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile); err != nil {
+			return nil, err
+		}
+	default:
+		host, _, _ := net.SplitHostPort(addr)
+		if cert, err = tlsutil.SelfSigned("thermocktat", host); err != nil {
+			return nil, err
+		}
+		fp, _ := tlsutil.Fingerprint(cert)
+		log.Warn("serving a generated self-signed certificate", "sha256_fingerprint", fp)
+	}
+
+	c := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+	if cfg.ClientCAFile != "" {
+		pool, err := tlsutil.LoadCertPool(cfg.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		c.ClientCAs = pool
+		c.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return c, nil
+}
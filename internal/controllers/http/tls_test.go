@@ -0,0 +1,203 @@
+package httpctrl
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Agrid-Dev/thermocktat/internal/testutil"
+)
+
+func newTLSTestServer(t *testing.T, cfg TLSConfig) *httptest.Server {
+	t.Helper()
+	srv, err := New(testutil.NewFakeThermostatService(), Config{DeviceID: "default", Addr: "127.0.0.1:0", TLS: cfg}, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ts := httptest.NewUnstartedServer(srv.srv.Handler)
+	ts.TLS = srv.srv.TLSConfig
+	ts.Config.ErrorLog = log.New(io.Discard, "", 0) // expected handshake failures
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func tlsClient(roots *x509.CertPool, certs ...tls.Certificate) *http.Client {
+	return &http.Client{
+		Timeout: 2 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:      roots,
+			Certificates: certs,
+		}},
+	}
+}
+
+// testCA issues certificates for the mutual TLS tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key}
+}
+
+func (ca *testCA) issue(t *testing.T, cn string, usage x509.ExtKeyUsage) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	if ip := net.ParseIP(cn); ip != nil {
+		tmpl.IPAddresses = []net.IP{ip}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func (ca *testCA) pool() *x509.CertPool {
+	p := x509.NewCertPool()
+	p.AddCert(ca.cert)
+	return p
+}
+
+func writePEM(t *testing.T, dir, name, typ string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestTLS_SelfSigned(t *testing.T) {
+	ts := newTLSTestServer(t, TLSConfig{SelfSigned: true})
+
+	// Pin the served certificate as the only root.
+	roots := x509.NewCertPool()
+	roots.AddCert(ts.TLS.Certificates[0].Leaf)
+
+	resp, err := tlsClient(roots).Get(ts.URL + "/v1")
+	if err != nil {
+		t.Fatalf("GET over TLS: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	if _, err := tlsClient(x509.NewCertPool()).Get(ts.URL + "/v1"); err == nil {
+		t.Fatal("expected an untrusted certificate to be rejected")
+	}
+}
+
+func TestTLS_MutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	server := ca.issue(t, "127.0.0.1", x509.ExtKeyUsageServerAuth)
+	key, err := x509.MarshalECPrivateKey(server.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := newTLSTestServer(t, TLSConfig{
+		CertFile:     writePEM(t, dir, "server.pem", "CERTIFICATE", server.Certificate[0]),
+		KeyFile:      writePEM(t, dir, "server-key.pem", "EC PRIVATE KEY", key),
+		ClientCAFile: writePEM(t, dir, "ca.pem", "CERTIFICATE", ca.cert.Raw),
+	})
+	roots := ca.pool()
+	client := ca.issue(t, "bms", x509.ExtKeyUsageClientAuth)
+
+	tests := []struct {
+		name   string
+		certs  []tls.Certificate
+		wantOK bool
+	}{
+		{"client certificate from the CA", []tls.Certificate{client}, true},
+		{"no client certificate", nil, false},
+		{"client certificate from another CA", []tls.Certificate{newTestCA(t).issue(t, "intruder", x509.ExtKeyUsageClientAuth)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := tlsClient(roots, tt.certs...).Get(ts.URL + "/healthz")
+			if !tt.wantOK {
+				if err == nil {
+					_ = resp.Body.Close()
+					t.Fatal("expected handshake failure")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GET: %v", err)
+			}
+			_ = resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("expected 200, got %d", resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestTLS_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  TLSConfig
+	}{
+		{"cert without key", TLSConfig{CertFile: "cert.pem"}},
+		{"cert and self-signed", TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem", SelfSigned: true}},
+		{"client CA without server certificate", TLSConfig{ClientCAFile: "ca.pem"}},
+		{"missing files", TLSConfig{CertFile: "/does/not/exist.pem", KeyFile: "/does/not/exist.pem"}},
+		{"missing client CA", TLSConfig{SelfSigned: true, ClientCAFile: "/does/not/exist.pem"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(testutil.NewFakeThermostatService(), Config{Addr: ":0", TLS: tt.cfg}, nil); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
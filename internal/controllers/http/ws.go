@@ -0,0 +1,167 @@
+package httpctrl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Agrid-Dev/thermocktat/internal/controllers/wire"
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsWriteTimeout = 5 * time.Second
+	wsPongTimeout  = 60 * time.Second
+	wsPingInterval = 30 * time.Second
+	wsMaxMessage   = 64 << 10
+)
+
+// Emulated devices are driven from arbitrary test tools and apps, so any
+// origin is accepted.
+var wsUpgrader = websocket.Upgrader{
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// wsCommand is a client message: {"id": "42", "set": {"mode": "heat"}}. The id
+// is echoed in the matching acknowledgement or error.
+type wsCommand struct {
+	ID  json.RawMessage `json:"id,omitempty"`
+	Set json.RawMessage `json:"set"`
+}
+
+// wsMessage is a server message:
+//   - {"type": "snapshot", "data": {...}} on connect and after every change,
+//   - {"type": "ack", "id": ..., "data": {...}} when a command is applied,
+//   - {"type": "error", "id": ..., "code": "...", "error": "..."} when it is
+//     rejected, with the same error codes as the HTTP problem details.
+type wsMessage struct {
+	Type  string               `json:"type"`
+	ID    json.RawMessage      `json:"id,omitempty"`
+	Data  *snapshotDTO         `json:"data,omitempty"`
+	Code  thermostat.ErrorCode `json:"code,omitempty"`
+	Error string               `json:"error,omitempty"`
+}
+
+func wsError(id json.RawMessage, code thermostat.ErrorCode, msg string) wsMessage {
+	return wsMessage{Type: "error", ID: id, Code: code, Error: msg}
+}
+
+// handleWebSocket serves a bidirectional control channel: the client receives
+// the full snapshot whenever the state changes, and sends commands applied
+// atomically like PATCH /v1.
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade already replied with an HTTP error.
+		s.log.Debug("websocket upgrade failed", "err", err)
+		return
+	}
+	defer conn.Close()
+
+	changes, _, _, _ := s.events.subscribe(nil)
+	defer s.events.unsubscribe(changes)
+
+	writable := canWrite(r.Context())
+	replies := make(chan wsMessage, 8)
+	done := make(chan struct{})
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		defer close(done)
+		s.readWebSocket(conn, writable, replies, stop)
+	}()
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	if err := writeWS(conn, s.snapshotMessage("snapshot", nil)); err != nil {
+		return
+	}
+	for {
+		var msg wsMessage
+		select {
+		case <-r.Context().Done():
+			_ = conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+				time.Now().Add(wsWriteTimeout))
+			return
+		case <-done:
+			return
+		case _, ok := <-changes:
+			if !ok {
+				return
+			}
+			msg = s.snapshotMessage("snapshot", nil)
+		case msg = <-replies:
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				return
+			}
+			continue
+		}
+		if err := writeWS(conn, msg); err != nil {
+			return
+		}
+	}
+}
+
+// readWebSocket applies client commands until the connection fails or stop is
+// closed, queueing one reply per command.
+func (s *Server) readWebSocket(conn *websocket.Conn, writable bool, replies chan<- wsMessage, stop <-chan struct{}) {
+	conn.SetReadLimit(wsMaxMessage)
+	_ = conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			if !errors.As(err, &closeErr) {
+				s.log.Debug("websocket read failed", "err", err)
+			}
+			return
+		}
+		select {
+		case replies <- s.applyWSCommand(data, writable):
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (s *Server) applyWSCommand(data []byte, writable bool) wsMessage {
+	var cmd wsCommand
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return wsError(nil, thermostat.CodeInvalidRequest, "invalid json: "+err.Error())
+	}
+	if cmd.Set == nil {
+		return wsError(cmd.ID, thermostat.CodeInvalidRequest, "missing field 'set'")
+	}
+	if !writable {
+		return wsError(cmd.ID, codeForbidden, "read-only credentials")
+	}
+	p, err := wire.DecodePatch(bytes.NewReader(cmd.Set))
+	if err != nil {
+		return wsError(cmd.ID, thermostat.Code(err), "invalid 'set': "+err.Error())
+	}
+	if err := s.svc.ApplyPatch(p); err != nil {
+		return wsError(cmd.ID, thermostat.Code(err), err.Error())
+	}
+	return s.snapshotMessage("ack", cmd.ID)
+}
+
+func (s *Server) snapshotMessage(typ string, id json.RawMessage) wsMessage {
+	dto := toDTO(s.svc.Get())
+	dto.DeviceID = s.deviceID
+	return wsMessage{Type: typ, ID: id, Data: &dto}
+}
+
+func writeWS(conn *websocket.Conn, msg wsMessage) error {
+	_ = conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return conn.WriteJSON(msg)
+}
@@ -0,0 +1,134 @@
+package httpctrl
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type wsTestMessage struct {
+	Type  string          `json:"type"`
+	ID    json.RawMessage `json:"id"`
+	Data  map[string]any  `json:"data"`
+	Code  string          `json:"code"`
+	Error string          `json:"error"`
+}
+
+func dialWS(t *testing.T, ts *httptest.Server) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/v1/ws"
+	conn, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial %s: %v", url, err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected 101, got %d", resp.StatusCode)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func readWS(t *testing.T, conn *websocket.Conn) wsTestMessage {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg wsTestMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return msg
+}
+
+// readWSType skips snapshot pushes interleaved with the reply under test.
+func readWSType(t *testing.T, conn *websocket.Conn, typ string) wsTestMessage {
+	t.Helper()
+	for {
+		if msg := readWS(t, conn); msg.Type == typ {
+			return msg
+		}
+	}
+}
+
+func TestWebSocket_SnapshotOnConnect(t *testing.T) {
+	_, _, ts := newEventsTestServer(t)
+	conn := dialWS(t, ts)
+
+	msg := readWS(t, conn)
+	if msg.Type != "snapshot" || msg.Data["device_id"] != "default" || msg.Data["mode"] != "auto" {
+		t.Fatalf("expected initial snapshot, got %+v", msg)
+	}
+}
+
+func TestWebSocket_SetAcknowledged(t *testing.T) {
+	_, th, ts := newEventsTestServer(t)
+	conn := dialWS(t, ts)
+	_ = readWS(t, conn)
+
+	cmd := `{"id":"req-1","set":{"temperature_setpoint_min":10,"temperature_setpoint_max":15,"temperature_setpoint":12}}`
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(cmd)); err != nil {
+		t.Fatal(err)
+	}
+
+	ack := readWSType(t, conn, "ack")
+	if string(ack.ID) != `"req-1"` {
+		t.Fatalf("expected echoed id, got %s", ack.ID)
+	}
+	if ack.Data["temperature_setpoint"] != float64(12) {
+		t.Fatalf("expected updated snapshot in ack, got %v", ack.Data)
+	}
+	if got := th.Get().TemperatureSetpointMax; got != 15 {
+		t.Fatalf("expected max=15 applied, got %v", got)
+	}
+}
+
+func TestWebSocket_CommandErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		cmd      string
+		wantID   string
+		wantCode string
+	}{
+		{"rejected by service", `{"id":7,"set":{"temperature_setpoint":40}}`, "7", "setpoint_out_of_range"},
+		{"invalid enum", `{"id":"a","set":{"mode":"weird"}}`, `"a"`, "invalid_mode"},
+		{"unknown field", `{"id":"b","set":{"ambient_temperature":30}}`, `"b"`, "invalid_request"},
+		{"missing set", `{"id":"c"}`, `"c"`, "invalid_request"},
+		{"invalid json", `{"id":`, "", "invalid_request"},
+	}
+	_, th, ts := newEventsTestServer(t)
+	conn := dialWS(t, ts)
+	_ = readWS(t, conn)
+	before := th.Get()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(tt.cmd)); err != nil {
+				t.Fatal(err)
+			}
+			msg := readWSType(t, conn, "error")
+			if string(msg.ID) != tt.wantID || msg.Code != tt.wantCode || msg.Error == "" {
+				t.Fatalf("expected error for id %s, got %+v", tt.wantID, msg)
+			}
+		})
+	}
+	if th.Get() != before {
+		t.Fatal("rejected commands must not change the state")
+	}
+}
+
+func TestWebSocket_PushesChanges(t *testing.T) {
+	srv, th, ts := newEventsTestServer(t)
+	conn := dialWS(t, ts)
+	_ = readWS(t, conn)
+
+	th.SetFaultCode(12)
+	srv.events.poll()
+
+	msg := readWS(t, conn)
+	if msg.Type != "snapshot" || msg.Data["fault_code"] != float64(12) {
+		t.Fatalf("expected pushed snapshot with fault_code=12, got %+v", msg)
+	}
+}
@@ -73,6 +73,28 @@ func (f *spyThermostatService) SetFaultCode(code int) {
 	f.s.FaultCode = code
 	f.setFaultCodeCalls = append(f.setFaultCodeCalls, code)
 }
+func (f *spyThermostatService) ApplyPatch(thermostat.Patch) error {
+	return nil
+}
+func (f *spyThermostatService) WeatherStatus() thermostat.WeatherStatus {
+	return thermostat.WeatherStatus{}
+}
+func (f *spyThermostatService) Simulation() thermostat.SimulationParams {
+	return thermostat.SimulationParams{}
+}
+func (f *spyThermostatService) ApplySimulationPatch(thermostat.SimulationPatch) error {
+	return nil
+}
+func (f *spyThermostatService) SimulationState() thermostat.SimulationState {
+	return thermostat.SimulationState{}
+}
+func (f *spyThermostatService) SetPaused(bool) {}
+func (f *spyThermostatService) SetAmbientTemperature(float64) error {
+	return nil
+}
+func (f *spyThermostatService) Step(time.Duration) error {
+	return nil
+}
 
 func findFreeTCPAddr(t *testing.T) string {
 	l, err := net.Listen("tcp", "127.0.0.1:0")
@@ -0,0 +1,45 @@
+package mqttctrl
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Availability payloads, published retained to <base>/availability:
+// online on every connection, offline on shutdown or, as the client's will,
+// by the broker when the connection is lost.
+const (
+	availabilityOnline  = "online"
+	availabilityOffline = "offline"
+)
+
+// will returns the Last Will and Testament of the payload format: the
+// offline availability, Sparkplug B NDEATH or Homie "lost" state.
+func (c *Controller) will() (topic string, payload []byte, qos byte, retained bool) {
+	switch c.cfg.PayloadFormat {
+	case PayloadSparkplugB:
+		c.spMu.Lock()
+		defer c.spMu.Unlock()
+		return c.sparkplugTopic("NDEATH"), ndeathPayload(c.bdSeq), 1, false
+	case PayloadHomie:
+		return c.homieTopic("$state"), []byte(homieLost), 1, true
+	}
+	return c.topic("availability"), []byte(availabilityOffline), c.cfg.QoS, true
+}
+
+// heartbeatDTO is published every HeartbeatInterval, not retained.
+type heartbeatDTO struct {
+	DeviceID string    `json:"device_id"`
+	Time     time.Time `json:"time"`
+	Uptime   float64   `json:"uptime"` // seconds
+}
+
+func (c *Controller) publishHeartbeat() {
+	now := time.Now()
+	b, _ := json.Marshal(heartbeatDTO{
+		DeviceID: c.cfg.DeviceID,
+		Time:     now.UTC(),
+		Uptime:   now.Sub(c.started).Round(time.Second).Seconds(),
+	})
+	c.publish(c.cfg.HeartbeatTopic, false, b)
+}
@@ -0,0 +1,56 @@
+package mqttctrl
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestClientOptions_Will(t *testing.T) {
+	c, _ := New(newDefaultSvc(), Config{DeviceID: "room101", QoS: 1, KeepAlive: 5 * time.Second}, nil)
+
+	opts := c.clientOptions()
+
+	if !opts.WillEnabled || opts.WillTopic != "thermocktat/room101/availability" ||
+		string(opts.WillPayload) != availabilityOffline || !opts.WillRetained || opts.WillQos != 1 {
+		t.Fatalf("unexpected will: enabled=%v topic=%q payload=%q retained=%v qos=%d",
+			opts.WillEnabled, opts.WillTopic, opts.WillPayload, opts.WillRetained, opts.WillQos)
+	}
+	if opts.KeepAlive != 5 {
+		t.Fatalf("keep alive = %ds, want 5s", opts.KeepAlive)
+	}
+}
+
+func TestPublishHeartbeat(t *testing.T) {
+	c, err := New(newDefaultSvc(), Config{DeviceID: "room101"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc := &fakeClient{}
+	c.client = fc
+	c.started = time.Now().Add(-time.Minute)
+
+	c.publishHeartbeat()
+
+	p, n := findPublish(fc, "thermocktat/room101/heartbeat")
+	if n != 1 || p.retain {
+		t.Fatalf("expected 1 non-retained heartbeat, got %d (retain=%v)", n, p.retain)
+	}
+	var got heartbeatDTO
+	if err := json.Unmarshal(p.payload, &got); err != nil {
+		t.Fatalf("invalid heartbeat %s: %v", p.payload, err)
+	}
+	if got.DeviceID != "room101" || got.Uptime != 60 || time.Since(got.Time) > time.Minute {
+		t.Fatalf("unexpected heartbeat %s", p.payload)
+	}
+}
+
+func TestNewValidation_Heartbeat(t *testing.T) {
+	c, _ := New(newDefaultSvc(), Config{DeviceID: "x", HeartbeatTopic: "bms/alive"}, nil)
+	if c.cfg.HeartbeatTopic != "bms/alive" {
+		t.Fatalf("heartbeat topic = %q", c.cfg.HeartbeatTopic)
+	}
+	if _, err := New(newDefaultSvc(), Config{DeviceID: "x", HeartbeatInterval: -time.Second}, nil); err == nil {
+		t.Fatal("expected error for negative heartbeat interval")
+	}
+}
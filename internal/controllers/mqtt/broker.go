@@ -0,0 +1,49 @@
+package mqttctrl
+
+import (
+	"fmt"
+	"net"
+
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+)
+
+// startBroker starts the in-process broker on EmbeddedBrokerAddr. It
+// accepts any client, MQTT 3.1.1 and 5, without authentication, and returns
+// the URL the controller connects to.
+func (c *Controller) startBroker() (*mochi.Server, string, error) {
+	srv := mochi.New(&mochi.Options{Logger: c.log.With("component", "mqtt_broker")})
+	if err := srv.AddHook(new(auth.AllowHook), nil); err != nil {
+		return nil, "", err
+	}
+	tcp := listeners.NewTCP(listeners.Config{ID: "tcp", Address: c.cfg.EmbeddedBrokerAddr})
+	if err := srv.AddListener(tcp); err != nil {
+		return nil, "", fmt.Errorf("mqtt broker: %w", err)
+	}
+	if err := srv.Serve(); err != nil {
+		_ = srv.Close()
+		return nil, "", fmt.Errorf("mqtt broker: %w", err)
+	}
+
+	// The listener address is resolved, e.g. ":0" got a port.
+	url, err := dialURL(tcp.Address())
+	if err != nil {
+		_ = srv.Close()
+		return nil, "", err
+	}
+	return srv, url, nil
+}
+
+// dialURL returns the URL to connect to a broker listening on addr, through
+// loopback when it listens on every interface.
+func dialURL(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	if ip := net.ParseIP(host); host == "" || ip.IsUnspecified() {
+		host = "127.0.0.1"
+	}
+	return "tcp://" + net.JoinHostPort(host, port), nil
+}
@@ -0,0 +1,135 @@
+package mqttctrl
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// freeAddr returns a loopback address with a port free for the broker.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+// TestEmbeddedBroker runs the controller end to end on its own broker, with
+// a regular client connected to it, for both protocol versions.
+func TestEmbeddedBroker(t *testing.T) {
+	for _, version := range []int{ProtocolV311, ProtocolV5} {
+		t.Run(map[int]string{ProtocolV311: "mqtt3", ProtocolV5: "mqtt5"}[version], func(t *testing.T) {
+			// A real thermostat: the fake is not safe for the controller's
+			// concurrent handlers.
+			th, err := thermostat.New(
+				thermostat.Snapshot{
+					Enabled:                true,
+					TemperatureSetpoint:    22,
+					TemperatureSetpointMin: 16,
+					TemperatureSetpointMax: 28,
+					Mode:                   thermostat.ModeAuto,
+					FanSpeed:               thermostat.FanAuto,
+					AmbientTemperature:     21,
+				},
+				thermostat.PIDRegulatorParams{TargetHysteresis: 1, ModeChangeHysteresis: 2},
+				thermostat.HeatLossSimulatorParams{},
+				nil,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			addr := freeAddr(t)
+			c, err := New(th, Config{
+				DeviceID:           "room101",
+				EmbeddedBrokerAddr: addr,
+				ProtocolVersion:    version,
+				QoS:                1,
+				RetainSnapshot:     true,
+				PublishInterval:    20 * time.Millisecond,
+			}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error, 1)
+			go func() { done <- c.Run(ctx) }()
+			defer func() {
+				cancel()
+				<-done
+			}()
+
+			cl := mqtt.NewClient(mqtt.NewClientOptions().
+				AddBroker("tcp://" + addr).
+				SetClientID("test-client").
+				SetConnectRetry(true).
+				SetConnectRetryInterval(20 * time.Millisecond))
+			if tok := cl.Connect(); !tok.WaitTimeout(5*time.Second) || tok.Error() != nil {
+				t.Fatalf("connect to the embedded broker: %v", tok.Error())
+			}
+			defer cl.Disconnect(0)
+
+			snapshots := make(chan snapshotDTO, 16)
+			tok := cl.Subscribe("thermocktat/room101/snapshot", 1, func(_ mqtt.Client, m mqtt.Message) {
+				var s snapshotDTO
+				if json.Unmarshal(m.Payload(), &s) == nil {
+					snapshots <- s
+				}
+			})
+			if !tok.WaitTimeout(5*time.Second) || tok.Error() != nil {
+				t.Fatalf("subscribe: %v", tok.Error())
+			}
+
+			waitFor := func(what string, ok func(snapshotDTO) bool) {
+				t.Helper()
+				timeout := time.After(5 * time.Second)
+				for {
+					select {
+					case s := <-snapshots:
+						if ok(s) {
+							return
+						}
+					case <-timeout:
+						t.Fatalf("no snapshot with %s", what)
+					}
+				}
+			}
+			waitFor("the device id", func(s snapshotDTO) bool { return s.DeviceId == "room101" })
+
+			cl.Publish("thermocktat/room101/set/mode", 1, false, `{"value":"heat"}`).Wait()
+			waitFor("mode heat", func(s snapshotDTO) bool { return s.Mode == "heat" })
+		})
+	}
+}
+
+func TestNew_EmbeddedBrokerURL(t *testing.T) {
+	tests := map[string]string{
+		":1884":           "tcp://127.0.0.1:1884",
+		"0.0.0.0:1884":    "tcp://127.0.0.1:1884",
+		"localhost:1884":  "tcp://localhost:1884",
+		"192.0.2.10:1884": "tcp://192.0.2.10:1884",
+	}
+	for addr, want := range tests {
+		c, err := New(newDefaultSvc(), Config{
+			DeviceID:           "room101",
+			BrokerURL:          "tcp://host.docker.internal:1883",
+			EmbeddedBrokerAddr: addr,
+		}, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", addr, err)
+		}
+		if got := c.BrokerURL(); got != want {
+			t.Errorf("%s: BrokerURL() = %q, want %q", addr, got, want)
+		}
+	}
+	if _, err := New(newDefaultSvc(), Config{DeviceID: "room101", EmbeddedBrokerAddr: "1884"}, nil); err == nil {
+		t.Fatal("expected an error for an address without port")
+	}
+}
@@ -3,13 +3,16 @@ package mqttctrl
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/Agrid-Dev/thermocktat/internal/controllers/wire"
 	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 )
@@ -19,6 +22,13 @@ const (
 	PublishInterval string = "interval"
 )
 
+const (
+	PayloadJSON       string = "json"
+	PayloadSparkplugB string = "sparkplug_b"
+	PayloadHomie      string = "homie"
+	PayloadTemplate   string = "template"
+)
+
 type Config struct {
 	// Identity
 	DeviceID string
@@ -26,6 +36,11 @@ type Config struct {
 	// MQTT connection
 	BrokerURL string
 	ClientID  string
+	// EmbeddedBrokerAddr starts an in-process broker listening on this
+	// address (e.g. ":1883"), and connects to it instead of BrokerURL.
+	EmbeddedBrokerAddr string
+	// ProtocolVersion is ProtocolV311 (default) or ProtocolV5.
+	ProtocolVersion int
 
 	// Topics
 	BaseTopic string
@@ -35,12 +50,54 @@ type Config struct {
 	RetainSnapshot  bool
 	PublishInterval time.Duration
 	// PublishMode controls when snapshots are published:
-	// - "on_change": publish only when snapshot has changed (default)
+	// - "on_change": publish only when snapshot has changed (default),
+	//   checked every PublishInterval
 	// - "interval":  publish every PublishInterval even if unchanged
 	PublishMode string
+	// AttributeTopics also publishes each changed attribute to
+	// <base>/state/<attribute>.
+	AttributeTopics bool
+	// Deadband is how much ambient_temperature must move before it counts
+	// as a change.
+	Deadband float64
 
 	Username string
 	Password string
+	// TLS applies to ssl:// and wss:// brokers.
+	TLS TLSConfig
+
+	// KeepAlive bounds how long the broker takes to notice a lost
+	// connection and publish the will (30s when zero).
+	KeepAlive time.Duration
+	// HeartbeatInterval publishes a heartbeat to HeartbeatTopic
+	// (<base>/heartbeat by default); zero disables heartbeats.
+	HeartbeatInterval time.Duration
+	HeartbeatTopic    string
+
+	// PayloadFormat is PayloadJSON (default), PayloadSparkplugB to act as
+	// a Sparkplug B edge node (SparkplugEdgeNodeID, ClientID by default) in
+	// group SparkplugGroupID ("thermocktat" by default), or PayloadHomie
+	// for the Homie 4 convention. Both replace the topics under BaseTopic.
+	// PayloadTemplate publishes the snapshot and takes commands in the
+	// schema of Template.
+	PayloadFormat       string
+	SparkplugGroupID    string
+	SparkplugEdgeNodeID string
+	Template            Template
+
+	// HomeAssistant publishes MQTT discovery config for a climate entity
+	// under DiscoveryPrefix ("homeassistant" by default), and turns
+	// AttributeTopics on for its state topics.
+	HomeAssistant   bool
+	DiscoveryPrefix string
+}
+
+// mqttClient is the part of an MQTT client the controller uses: paho's
+// mqtt.Client for MQTT 3.1.1, client5 for MQTT 5.
+type mqttClient interface {
+	Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token
+	Subscribe(topic string, qos byte, callback mqtt.MessageHandler) mqtt.Token
+	Disconnect(quiesce uint)
 }
 
 type Controller struct {
@@ -48,7 +105,27 @@ type Controller struct {
 	cfg Config
 	log *slog.Logger
 
-	client mqtt.Client
+	client  mqttClient
+	tls     *tls.Config // nil: plain TCP, or the system roots
+	started time.Time
+
+	// pubMu guards the attribute values last published in the snapshot and
+	// to the state topics.
+	pubMu          sync.Mutex
+	published      map[string]any
+	publishedAttrs map[string]any
+
+	// discoveryMu guards discovered, the setpoint bounds last advertised
+	// to Home Assistant.
+	discoveryMu sync.Mutex
+	discovered  *[2]float64
+
+	// spMu orders Sparkplug B messages and guards the sequence numbers;
+	// born reports whether DBIRTH was published in the current session.
+	spMu  sync.Mutex
+	seq   uint64
+	bdSeq uint64
+	born  bool
 }
 
 func New(svc thermostat.Service, cfg Config, logger *slog.Logger) (*Controller, error) {
@@ -77,87 +154,241 @@ func New(svc thermostat.Service, cfg Config, logger *slog.Logger) (*Controller,
 	if cfg.PublishMode != PublishOnChange && cfg.PublishMode != PublishInterval {
 		return nil, fmt.Errorf("mqtt: invalid PublishMode %q", cfg.PublishMode)
 	}
+	if cfg.HeartbeatTopic == "" {
+		cfg.HeartbeatTopic = strings.TrimRight(cfg.BaseTopic, "/") + "/heartbeat"
+	}
+	if cfg.DiscoveryPrefix == "" {
+		cfg.DiscoveryPrefix = "homeassistant"
+	}
+	if cfg.HomeAssistant {
+		// Home Assistant reads each state from its attribute topic.
+		cfg.AttributeTopics = true
+	}
+	if cfg.ProtocolVersion == 0 {
+		cfg.ProtocolVersion = ProtocolV311
+	}
+	if cfg.ProtocolVersion != ProtocolV311 && cfg.ProtocolVersion != ProtocolV5 {
+		return nil, fmt.Errorf("mqtt: invalid ProtocolVersion %d (expected 4 for MQTT 3.1.1 or 5)", cfg.ProtocolVersion)
+	}
 	if cfg.QoS > 1 {
 		return nil, errors.New("mqtt: QoS must be 0 or 1")
 	}
+	if cfg.KeepAlive < 0 || cfg.HeartbeatInterval < 0 {
+		return nil, errors.New("mqtt: KeepAlive and HeartbeatInterval must not be negative")
+	}
+	if cfg.Deadband < 0 {
+		return nil, errors.New("mqtt: Deadband must not be negative")
+	}
+	if cfg.PayloadFormat == "" {
+		cfg.PayloadFormat = PayloadJSON
+	}
+	if cfg.SparkplugGroupID == "" {
+		cfg.SparkplugGroupID = "thermocktat"
+	}
+	if cfg.SparkplugEdgeNodeID == "" {
+		cfg.SparkplugEdgeNodeID = cfg.ClientID
+	}
+	switch cfg.PayloadFormat {
+	case PayloadJSON:
+	case PayloadSparkplugB:
+		if err := validateSparkplug(cfg); err != nil {
+			return nil, err
+		}
+	case PayloadHomie:
+		if err := validateHomie(cfg); err != nil {
+			return nil, err
+		}
+	case PayloadTemplate:
+		if cfg.Template.SnapshotTopic == "" {
+			cfg.Template.SnapshotTopic = strings.TrimRight(cfg.BaseTopic, "/") + "/snapshot"
+		}
+		if cfg.Template.CommandTopic == "" {
+			cfg.Template.CommandTopic = strings.TrimRight(cfg.BaseTopic, "/") + "/set"
+		}
+		if err := validateTemplate(cfg); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("mqtt: invalid PayloadFormat %q", cfg.PayloadFormat)
+	}
+	if cfg.EmbeddedBrokerAddr != "" {
+		if cfg.TLS.enabled() {
+			return nil, errors.New("mqtt: TLS options cannot be combined with EmbeddedBrokerAddr")
+		}
+		url, err := dialURL(cfg.EmbeddedBrokerAddr)
+		if err != nil {
+			return nil, fmt.Errorf("mqtt: invalid EmbeddedBrokerAddr: %w", err)
+		}
+		cfg.BrokerURL = url
+	}
+	tlsConfig, err := newTLSConfig(cfg.TLS, cfg.BrokerURL)
+	if err != nil {
+		return nil, fmt.Errorf("mqtt tls: %w", err)
+	}
 	return &Controller{
-		svc: svc,
-		cfg: cfg,
-		log: logger,
+		svc:            svc,
+		cfg:            cfg,
+		log:            logger,
+		tls:            tlsConfig,
+		published:      make(map[string]any),
+		publishedAttrs: make(map[string]any),
 	}, nil
 }
 
-func (c *Controller) Run(ctx context.Context) error {
-	opts := mqtt.NewClientOptions().
-		AddBroker(c.cfg.BrokerURL).
-		SetClientID(c.cfg.ClientID).
-		SetAutoReconnect(true).
-		SetConnectRetry(true).
-		SetConnectRetryInterval(2 * time.Second)
+// BrokerURL returns the URL of the broker the controller connects to, the
+// embedded one when EmbeddedBrokerAddr is set. Call it before Run: an
+// embedded broker on port 0 only gets its port once started.
+func (c *Controller) BrokerURL() string { return c.cfg.BrokerURL }
 
-	if c.cfg.Username != "" {
-		opts.SetUsername(c.cfg.Username)
-		opts.SetPassword(c.cfg.Password)
-	}
-
-	// Subscribe when connected/reconnected.
-	opts.OnConnect = func(cl mqtt.Client) {
-		c.log.Info("mqtt broker connected",
-			"base_topic", c.cfg.BaseTopic,
-			"publish_mode", c.cfg.PublishMode,
-		)
-		topicSet := c.topic("set/+")
-		tokenSet := cl.Subscribe(topicSet, c.cfg.QoS, c.onMessage)
-		tokenSet.Wait()
-
-		topicGet := c.topic("get/+")
-		tokenGet := cl.Subscribe(topicGet, c.cfg.QoS, c.onMessage)
-		tokenGet.Wait()
+func (c *Controller) Run(ctx context.Context) error {
+	c.started = time.Now()
+	if c.cfg.EmbeddedBrokerAddr != "" {
+		broker, url, err := c.startBroker()
+		if err != nil {
+			return err
+		}
+		// Closed once the client has disconnected, below.
+		defer broker.Close()
+		c.cfg.BrokerURL = url
+		c.log.Info("embedded mqtt broker started", "addr", c.cfg.EmbeddedBrokerAddr, "url", url)
 	}
-
-	c.client = mqtt.NewClient(opts)
-	tok := c.client.Connect()
-	tok.Wait()
-	if err := tok.Error(); err != nil {
-		return fmt.Errorf("mqtt connect: %w", err)
+	if c.cfg.ProtocolVersion == ProtocolV5 {
+		if err := c.connect5(ctx); err != nil {
+			return err
+		}
+	} else {
+		cl := mqtt.NewClient(c.clientOptions())
+		c.client = cl
+		tok := cl.Connect()
+		tok.Wait()
+		if err := tok.Error(); err != nil {
+			return fmt.Errorf("mqtt connect: %w", err)
+		}
 	}
 
-	// Publish loop: publish snapshot on interval, and only when changed.
+	// Publish loop: publish snapshot on every interval, or only when changed.
 	ticker := time.NewTicker(c.cfg.PublishInterval)
 	defer ticker.Stop()
 
 	c.publishSnapshot()
 
+	// Heartbeats are off unless HeartbeatInterval is set.
+	var heartbeat <-chan time.Time
+	if c.cfg.HeartbeatInterval > 0 {
+		t := time.NewTicker(c.cfg.HeartbeatInterval)
+		defer t.Stop()
+		heartbeat = t.C
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
+			switch c.cfg.PayloadFormat {
+			case PayloadSparkplugB:
+				c.publishDeath().Wait()
+			case PayloadHomie:
+				c.publishHomie("$state", homieDisconnected).Wait()
+			default:
+				c.publish(c.topic("availability"), true, []byte(availabilityOffline)).Wait()
+			}
 			c.client.Disconnect(250)
 			return ctx.Err()
 
 		case <-ticker.C:
 			if c.cfg.PublishMode == PublishInterval {
 				c.publishSnapshot()
+			} else {
+				c.publishIfChanged()
 			}
+
+		case <-heartbeat:
+			c.publishHeartbeat()
 		}
 	}
 }
 
-func (c *Controller) publishSnapshot() {
-	s := c.svc.Get()
-	dto := snapshotDTO{
-		Enabled:                s.Enabled,
-		TemperatureSetpoint:    s.TemperatureSetpoint,
-		TemperatureSetpointMin: s.TemperatureSetpointMin,
-		TemperatureSetpointMax: s.TemperatureSetpointMax,
-		Mode:                   s.Mode.String(),
-		FanSpeed:               s.FanSpeed.String(),
-		AmbientTemperature:     s.AmbientTemperature,
-		FaultCode:              s.FaultCode,
-		DeviceId:               c.cfg.DeviceID,
-	}
-
-	b, _ := json.Marshal(dto)
-	c.client.Publish(c.topic("snapshot"), c.cfg.QoS, c.cfg.RetainSnapshot, b)
+// clientOptions configures the MQTT 3.1.1 client.
+func (c *Controller) clientOptions() *mqtt.ClientOptions {
+	opts := mqtt.NewClientOptions().
+		AddBroker(c.cfg.BrokerURL).
+		SetClientID(c.cfg.ClientID).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(2 * time.Second)
+
+	// The broker publishes the will when the connection is lost without a
+	// clean disconnect, e.g. when the process is killed.
+	opts.SetBinaryWill(c.will())
+	if c.cfg.PayloadFormat == PayloadSparkplugB {
+		opts.SetReconnectingHandler(c.onSparkplugReconnecting)
+	}
+
+	if c.cfg.KeepAlive > 0 {
+		opts.SetKeepAlive(c.cfg.KeepAlive)
+	}
+	if c.cfg.Username != "" {
+		opts.SetUsername(c.cfg.Username)
+		opts.SetPassword(c.cfg.Password)
+	}
+	if c.tls != nil {
+		opts.SetTLSConfig(c.tls)
+	}
+
+	opts.OnConnect = func(cl mqtt.Client) { c.onConnect(cl) }
+	return opts
+}
+
+// onConnect subscribes when connected/reconnected, and announces the
+// device.
+func (c *Controller) onConnect(cl mqttClient) {
+	c.log.Info("mqtt broker connected",
+		"base_topic", c.cfg.BaseTopic,
+		"publish_mode", c.cfg.PublishMode,
+		"protocol_version", c.cfg.ProtocolVersion,
+		"payload_format", c.cfg.PayloadFormat,
+	)
+	switch c.cfg.PayloadFormat {
+	case PayloadSparkplugB:
+		c.onConnectSparkplug(cl)
+		return
+	case PayloadHomie:
+		c.onConnectHomie(cl)
+		return
+	}
+	topicSet := c.topic("set/+")
+	tokenSet := cl.Subscribe(topicSet, c.cfg.QoS, c.onMessage)
+	tokenSet.Wait()
+
+	topicPatch := c.topic("set")
+	tokenPatch := cl.Subscribe(topicPatch, c.cfg.QoS, c.onMessage)
+	tokenPatch.Wait()
+
+	topicGet := c.topic("get/+")
+	tokenGet := cl.Subscribe(topicGet, c.cfg.QoS, c.onMessage)
+	tokenGet.Wait()
+
+	if cmd := c.cfg.Template.CommandTopic; c.cfg.PayloadFormat == PayloadTemplate && cmd != topicPatch {
+		cl.Subscribe(cmd, c.cfg.QoS, c.onMessage).Wait()
+		cl.Subscribe(cmd+"/+", c.cfg.QoS, c.onMessage).Wait()
+	}
+
+	if c.cfg.HomeAssistant {
+		// Home Assistant announces its restarts here; discovery is then
+		// published again.
+		tokenStatus := cl.Subscribe(c.cfg.DiscoveryPrefix+"/status", c.cfg.QoS, c.onHomeAssistantStatus)
+		tokenStatus.Wait()
+		c.publishDiscovery()
+	}
+	c.publish(c.topic("availability"), true, []byte(availabilityOnline))
+}
+
+// requestErrorCode classifies a payload decoding error: domain errors keep
+// their code (e.g. an invalid mode), anything else is a malformed request.
+func requestErrorCode(err error) thermostat.ErrorCode {
+	if code := thermostat.Code(err); code != thermostat.CodeInternal {
+		return code
+	}
+	return thermostat.CodeInvalidRequest
 }
 
 type snapshotDTO struct {
@@ -172,9 +403,25 @@ type snapshotDTO struct {
 	DeviceId               string  `json:"device_id"`
 }
 
-// Command payload format: {"value": ...}
+// Command payload format: {"value": ...}, with an optional request_id
+// echoed in the response.
 type valueReq[T any] struct {
-	Value *T `json:"value"`
+	Value     *T              `json:"value"`
+	RequestID json.RawMessage `json:"request_id"`
+}
+
+// Multi-field command payload: a partial snapshot applied atomically.
+type patchReq struct {
+	wire.Patch
+	RequestID json.RawMessage `json:"request_id"`
+}
+
+func decodePatchStrict(b []byte) (thermostat.Patch, error) {
+	var req patchReq
+	if err := wire.Decode(bytes.NewReader(b), &req); err != nil {
+		return thermostat.Patch{}, err
+	}
+	return req.ToPatch()
 }
 
 func (c *Controller) onMessage(_ mqtt.Client, msg mqtt.Message) {
@@ -183,10 +430,35 @@ func (c *Controller) onMessage(_ mqtt.Client, msg mqtt.Message) {
 	c.log.Debug("mqtt message received", "topic", t, "payload_len", len(msg.Payload()))
 	// Request: <base>/get/<what>
 	if what, ok := strings.CutPrefix(t, c.cfg.BaseTopic+"/get/"); ok {
+		// MQTT 5 requests with a Response Topic get the answer there.
 		switch what {
 		case "snapshot":
-			c.publishSnapshot()
+			if !c.replySnapshot(msg) {
+				c.publishSnapshot()
+			}
+		case "simulation":
+			if !c.reply(msg, c.simulationPayload()) {
+				c.publishSimulation()
+			}
+		}
+		return
+	}
+
+	// Commands in the template schema, on <base>/set by default.
+	if c.cfg.PayloadFormat == PayloadTemplate && c.onTemplateCommand(msg) {
+		return
+	}
+
+	// Command: <base>/set with several fields
+	if t == c.topic("set") {
+		p, err := decodePatchStrict(msg.Payload())
+		if err != nil {
+			c.respond(msg, "", requestErrorCode(err), err)
+			return
 		}
+		err = c.svc.ApplyPatch(p)
+		c.publishSnapshot()
+		c.respond(msg, "", thermostat.Code(err), err)
 		return
 	}
 
@@ -194,88 +466,103 @@ func (c *Controller) onMessage(_ mqtt.Client, msg mqtt.Message) {
 	if field, ok := strings.CutPrefix(t, c.cfg.BaseTopic+"/set/"); ok {
 		payload := msg.Payload()
 
-		// Dispatch by field
-		switch field {
-		case "enabled":
-			v, err := decodeValueStrict[bool](payload)
-			if err != nil {
-				c.log.Warn("mqtt decode failed", "field", field, "err", err)
-				return
-			}
-			c.svc.SetEnabled(v)
+		// Simulation parameters are not part of the snapshot.
+		if field == "simulation" {
+			c.setSimulation(msg)
+			return
+		}
 
-		case "temperature_setpoint":
-			v, err := decodeValueStrict[float64](payload)
-			if err != nil {
-				c.log.Warn("mqtt decode failed", "field", field, "err", err)
-				return
-			}
-			if err := c.svc.SetSetpoint(v); err != nil {
-				c.log.Warn("mqtt set failed", "field", field, "err", err)
-			}
+		code, err := c.setAttribute(field, payload)
+		if errors.Is(err, errUnknownAttribute) {
+			// Messages on unknown attributes are ignored.
+			return
+		}
+		c.publishSnapshot()
+		c.respond(msg, field, code, err)
+	}
+}
 
-		case "temperature_setpoint_min":
-			v, err := decodeValueStrict[float64](payload)
-			if err != nil {
-				c.log.Warn("mqtt decode failed", "field", field, "err", err)
-				return
-			}
-			cur := c.svc.Get()
-			if err := c.svc.SetMinMax(v, cur.TemperatureSetpointMax); err != nil {
-				c.log.Warn("mqtt set failed", "field", field, "err", err)
-			}
+var errUnknownAttribute = errors.New("unknown attribute")
+
+// setAttribute decodes a {"value": ...} payload and applies it to field. The
+// returned code classifies the error, if any: invalid_request when the
+// payload could not be decoded.
+func (c *Controller) setAttribute(field string, payload []byte) (thermostat.ErrorCode, error) {
+	// Dispatch by field
+	switch field {
+	case "enabled":
+		v, err := decodeValueStrict[bool](payload)
+		if err != nil {
+			return thermostat.CodeInvalidRequest, err
+		}
+		c.svc.SetEnabled(v)
 
-		case "temperature_setpoint_max":
-			v, err := decodeValueStrict[float64](payload)
-			if err != nil {
-				c.log.Warn("mqtt decode failed", "field", field, "err", err)
-				return
-			}
-			cur := c.svc.Get()
-			if err := c.svc.SetMinMax(cur.TemperatureSetpointMin, v); err != nil {
-				c.log.Warn("mqtt set failed", "field", field, "err", err)
-			}
+	case "temperature_setpoint":
+		v, err := decodeValueStrict[float64](payload)
+		if err != nil {
+			return thermostat.CodeInvalidRequest, err
+		}
+		if err := c.svc.SetSetpoint(v); err != nil {
+			return thermostat.Code(err), err
+		}
 
-		case "mode":
-			s, err := decodeValueStrict[string](payload)
-			if err != nil {
-				c.log.Warn("mqtt decode failed", "field", field, "err", err)
-				return
-			}
-			m, err := thermostat.ParseMode(s)
-			if err != nil {
-				c.log.Warn("mqtt parse failed", "field", field, "value", s, "err", err)
-				return
-			}
-			if err := c.svc.SetMode(m); err != nil {
-				c.log.Warn("mqtt set failed", "field", field, "err", err)
-			}
+	case "temperature_setpoint_min":
+		v, err := decodeValueStrict[float64](payload)
+		if err != nil {
+			return thermostat.CodeInvalidRequest, err
+		}
+		cur := c.svc.Get()
+		if err := c.svc.SetMinMax(v, cur.TemperatureSetpointMax); err != nil {
+			return thermostat.Code(err), err
+		}
 
-		case "fan_speed":
-			s, err := decodeValueStrict[string](payload)
-			if err != nil {
-				c.log.Warn("mqtt decode failed", "field", field, "err", err)
-				return
-			}
-			f, err := thermostat.ParseFanSpeed(s)
-			if err != nil {
-				c.log.Warn("mqtt parse failed", "field", field, "value", s, "err", err)
-				return
-			}
-			if err := c.svc.SetFanSpeed(f); err != nil {
-				c.log.Warn("mqtt set failed", "field", field, "err", err)
-			}
+	case "temperature_setpoint_max":
+		v, err := decodeValueStrict[float64](payload)
+		if err != nil {
+			return thermostat.CodeInvalidRequest, err
+		}
+		cur := c.svc.Get()
+		if err := c.svc.SetMinMax(cur.TemperatureSetpointMin, v); err != nil {
+			return thermostat.Code(err), err
+		}
 
-		case "fault_code":
-			v, err := decodeValueStrict[int](payload)
-			if err != nil {
-				c.log.Warn("mqtt decode failed", "field", field, "err", err)
-				return
-			}
-			c.svc.SetFaultCode(v)
+	case "mode":
+		s, err := decodeValueStrict[string](payload)
+		if err != nil {
+			return thermostat.CodeInvalidRequest, err
 		}
-		c.publishSnapshot()
+		m, err := thermostat.ParseMode(s)
+		if err != nil {
+			return thermostat.Code(err), err
+		}
+		if err := c.svc.SetMode(m); err != nil {
+			return thermostat.Code(err), err
+		}
+
+	case "fan_speed":
+		s, err := decodeValueStrict[string](payload)
+		if err != nil {
+			return thermostat.CodeInvalidRequest, err
+		}
+		f, err := thermostat.ParseFanSpeed(s)
+		if err != nil {
+			return thermostat.Code(err), err
+		}
+		if err := c.svc.SetFanSpeed(f); err != nil {
+			return thermostat.Code(err), err
+		}
+
+	case "fault_code":
+		v, err := decodeValueStrict[int](payload)
+		if err != nil {
+			return thermostat.CodeInvalidRequest, err
+		}
+		c.svc.SetFaultCode(v)
+
+	default:
+		return "", errUnknownAttribute
 	}
+	return "", nil
 }
 
 func (c *Controller) topic(suffix string) string {
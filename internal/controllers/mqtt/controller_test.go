@@ -48,14 +48,14 @@ type publishCall struct {
 	payload []byte
 }
 
+// fakeClient records publishes, as the mqttClient of a controller.
 type fakeClient struct {
 	publishes []publishCall
 }
 
-func (c *fakeClient) IsConnected() bool      { return true }
-func (c *fakeClient) IsConnectionOpen() bool { return true }
-func (c *fakeClient) Connect() mqtt.Token    { return fakeToken{} }
-func (c *fakeClient) Disconnect(_ uint)      {}
+var _ mqttClient = (*fakeClient)(nil)
+
+func (c *fakeClient) Disconnect(_ uint) {}
 func (c *fakeClient) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
 	var b []byte
 	switch v := payload.(type) {
@@ -76,12 +76,6 @@ func (c *fakeClient) Publish(topic string, qos byte, retained bool, payload inte
 func (c *fakeClient) Subscribe(_ string, _ byte, _ mqtt.MessageHandler) mqtt.Token {
 	return fakeToken{}
 }
-func (c *fakeClient) SubscribeMultiple(_ map[string]byte, _ mqtt.MessageHandler) mqtt.Token {
-	return fakeToken{}
-}
-func (c *fakeClient) Unsubscribe(_ ...string) mqtt.Token       { return fakeToken{} }
-func (c *fakeClient) AddRoute(_ string, _ mqtt.MessageHandler) {}
-func (c *fakeClient) OptionsReader() mqtt.ClientOptionsReader  { return mqtt.ClientOptionsReader{} }
 
 // ---- tests ----
 func newDefaultSvc() *testutil.FakeThermostatService {
@@ -281,6 +275,83 @@ func TestOnMessage_ModeInvalid_DoesNotCallService(t *testing.T) {
 	if svc.SetModeCalled {
 		t.Fatal("expected SetMode not called")
 	}
+	assertErrorPublished(t, fc, "thermocktat/room101/set/mode", thermostat.CodeInvalidMode)
+}
+
+func TestOnMessage_PublishesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		topic   string
+		payload string
+		setup   func(*testutil.FakeThermostatService)
+		want    thermostat.ErrorCode
+	}{
+		{"invalid json", "thermocktat/room101/set/enabled", `{"value":`, nil, thermostat.CodeInvalidRequest},
+		{"missing value", "thermocktat/room101/set/fault_code", `{}`, nil, thermostat.CodeInvalidRequest},
+		{"unknown patch field", "thermocktat/room101/set", `{"ambient_temperature":30}`, nil, thermostat.CodeInvalidRequest},
+		{"invalid patch enum", "thermocktat/room101/set", `{"fan_speed":"turbo"}`, nil, thermostat.CodeInvalidFanSpeed},
+		{
+			"rejected by service", "thermocktat/room101/set/temperature_setpoint", `{"value":40}`,
+			func(f *testutil.FakeThermostatService) { f.SetSetpointErr = thermostat.ErrSetpointOutOfRange },
+			thermostat.CodeSetpointOutOfRange,
+		},
+		{
+			"patch rejected by service", "thermocktat/room101/set", `{"temperature_setpoint_min":30}`,
+			func(f *testutil.FakeThermostatService) { f.ApplyPatchErr = thermostat.ErrInvalidMinMax },
+			thermostat.CodeInvalidMinMax,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newDefaultSvc()
+			if tt.setup != nil {
+				tt.setup(svc)
+			}
+			c, _ := New(svc, Config{DeviceID: "room101"}, nil)
+			fc := &fakeClient{}
+			c.client = fc
+
+			c.onMessage(nil, fakeMessage{topic: tt.topic, payload: []byte(tt.payload)})
+
+			assertErrorPublished(t, fc, tt.topic, tt.want)
+		})
+	}
+}
+
+func TestOnMessage_ValidCommandPublishesNoError(t *testing.T) {
+	svc := newDefaultSvc()
+	c, _ := New(svc, Config{DeviceID: "room101"}, nil)
+	fc := &fakeClient{}
+	c.client = fc
+
+	c.onMessage(nil, fakeMessage{topic: "thermocktat/room101/set/mode", payload: []byte(`{"value":"heat"}`)})
+
+	for _, p := range fc.publishes {
+		if p.topic == "thermocktat/room101/error" {
+			t.Fatalf("unexpected error published: %s", p.payload)
+		}
+	}
+}
+
+func assertErrorPublished(t *testing.T, fc *fakeClient, topic string, code thermostat.ErrorCode) {
+	t.Helper()
+	for _, p := range fc.publishes {
+		if p.topic != "thermocktat/room101/error" {
+			continue
+		}
+		if p.retain {
+			t.Fatal("errors must not be retained")
+		}
+		var got errorDTO
+		if err := json.Unmarshal(p.payload, &got); err != nil {
+			t.Fatalf("invalid error payload %s: %v", p.payload, err)
+		}
+		if got.Topic != topic || got.Code != code || got.Message == "" {
+			t.Fatalf("expected %q error for %s, got %+v", code, topic, got)
+		}
+		return
+	}
+	t.Fatalf("no error published, got %+v", fc.publishes)
 }
 
 func TestOnMessage_FanSpeed(t *testing.T) {
@@ -329,6 +400,48 @@ func TestOnMessage_FanSpeedInvalid_DoesNotCallService(t *testing.T) {
 	}
 }
 
+func TestOnMessage_Patch(t *testing.T) {
+	svc := newDefaultSvc()
+	c, _ := New(svc, Config{DeviceID: "room101"}, nil)
+	fc := &fakeClient{}
+	c.client = fc
+
+	c.onMessage(nil, fakeMessage{
+		topic:   "thermocktat/room101/set",
+		payload: []byte(`{"temperature_setpoint_min":10,"temperature_setpoint_max":15,"temperature_setpoint":12,"fan_speed":"low"}`),
+	})
+
+	if !svc.ApplyPatchCalled {
+		t.Fatal("expected ApplyPatch called")
+	}
+	if svc.S.TemperatureSetpointMin != 10 || svc.S.TemperatureSetpointMax != 15 ||
+		svc.S.TemperatureSetpoint != 12 || svc.S.FanSpeed != thermostat.FanLow {
+		t.Fatalf("unexpected snapshot after patch: %+v", svc.S)
+	}
+	if len(fc.publishes) != 2 || fc.publishes[0].topic != "thermocktat/room101/snapshot" {
+		t.Fatalf("expected a snapshot publish after patch, got %+v", fc.publishes)
+	}
+	assertResponse(t, fc, responseDTO{Topic: "thermocktat/room101/set", Status: statusAccepted})
+}
+
+func TestOnMessage_PatchInvalid_DoesNotCallService(t *testing.T) {
+	for _, payload := range []string{
+		`{"mode":"weird"}`,
+		`{"temperature_setpoint":22,"extra":1}`,
+		`{"temperature_setpoint":`,
+	} {
+		svc := newDefaultSvc()
+		c, _ := New(svc, Config{DeviceID: "room101"}, nil)
+		c.client = &fakeClient{}
+
+		c.onMessage(nil, fakeMessage{topic: "thermocktat/room101/set", payload: []byte(payload)})
+
+		if svc.ApplyPatchCalled {
+			t.Fatalf("payload %s: expected ApplyPatch not called", payload)
+		}
+	}
+}
+
 func TestPublishSnapshot_PublishesJSON(t *testing.T) {
 	svc := newDefaultSvc()
 	c, _ := New(svc, Config{DeviceID: "room101", QoS: 1, RetainSnapshot: true}, nil)
@@ -0,0 +1,164 @@
+package mqttctrl
+
+import (
+	"encoding/json"
+
+	"github.com/Agrid-Dev/thermocktat/internal/buildinfo"
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Home Assistant reads each state from <base>/state/<attribute>, where
+// strings are JSON encoded. Our "fan" mode is its "fan_only"; "off" turns the
+// thermostat off rather than selecting a mode.
+const (
+	haModeStateTemplate   = `{{ 'fan_only' if value_json == 'fan' else value_json }}`
+	haModeCommandTemplate = `{% if value == 'off' %}{"enabled": false}` +
+		`{% else %}{"enabled": true, "mode": "{{ 'fan' if value == 'fan_only' else value }}"}{% endif %}`
+)
+
+// haDevice groups the entities of one thermocktat in Home Assistant.
+type haDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+	SWVersion    string   `json:"sw_version"`
+}
+
+// haClimateDTO is the discovery config of an MQTT HVAC entity, see
+// https://www.home-assistant.io/integrations/climate.mqtt/. States use the
+// state/<attribute> topics and commands the set/<attribute> ones, except
+// modes which go through set to change enabled and mode atomically.
+type haClimateDTO struct {
+	Name     *string  `json:"name"` // null: named after the device
+	UniqueID string   `json:"unique_id"`
+	Device   haDevice `json:"device"`
+	QoS      byte     `json:"qos"`
+
+	AvailabilityTopic   string `json:"availability_topic"`
+	PayloadAvailable    string `json:"payload_available"`
+	PayloadNotAvailable string `json:"payload_not_available"`
+
+	Modes               []string `json:"modes"`
+	ModeStateTopic      string   `json:"mode_state_topic"`
+	ModeStateTemplate   string   `json:"mode_state_template"`
+	ModeCommandTopic    string   `json:"mode_command_topic"`
+	ModeCommandTemplate string   `json:"mode_command_template"`
+
+	PowerStateTopic      string `json:"power_state_topic"`
+	PowerCommandTopic    string `json:"power_command_topic"`
+	PowerCommandTemplate string `json:"power_command_template"`
+	PayloadOn            string `json:"payload_on"`
+	PayloadOff           string `json:"payload_off"`
+
+	TemperatureStateTopic      string `json:"temperature_state_topic"`
+	TemperatureCommandTopic    string `json:"temperature_command_topic"`
+	TemperatureCommandTemplate string `json:"temperature_command_template"`
+
+	CurrentTemperatureTopic string `json:"current_temperature_topic"`
+
+	FanModes               []string `json:"fan_modes"`
+	FanModeStateTopic      string   `json:"fan_mode_state_topic"`
+	FanModeStateTemplate   string   `json:"fan_mode_state_template"`
+	FanModeCommandTopic    string   `json:"fan_mode_command_topic"`
+	FanModeCommandTemplate string   `json:"fan_mode_command_template"`
+
+	MinTemp         float64 `json:"min_temp"`
+	MaxTemp         float64 `json:"max_temp"`
+	TempStep        float64 `json:"temp_step"`
+	Precision       float64 `json:"precision"`
+	TemperatureUnit string  `json:"temperature_unit"`
+}
+
+func (c *Controller) discoveryTopic() string {
+	return c.cfg.DiscoveryPrefix + "/climate/" + c.cfg.DeviceID + "/config"
+}
+
+func (c *Controller) discoveryConfig(s thermostat.Snapshot) haClimateDTO {
+	id := "thermocktat_" + c.cfg.DeviceID
+	valueTemplate := `{"value": {{ value }}}`
+
+	return haClimateDTO{
+		UniqueID: id,
+		Device: haDevice{
+			Identifiers:  []string{id},
+			Name:         c.cfg.DeviceID,
+			Manufacturer: "Agrid",
+			Model:        "thermocktat",
+			SWVersion:    buildinfo.Version,
+		},
+		QoS: c.cfg.QoS,
+
+		AvailabilityTopic:   c.topic("availability"),
+		PayloadAvailable:    availabilityOnline,
+		PayloadNotAvailable: availabilityOffline,
+
+		Modes:               []string{"off", "heat", "cool", "fan_only", "auto"},
+		ModeStateTopic:      c.topic("state/mode"),
+		ModeStateTemplate:   haModeStateTemplate,
+		ModeCommandTopic:    c.topic("set"),
+		ModeCommandTemplate: haModeCommandTemplate,
+
+		PowerStateTopic:      c.topic("state/enabled"),
+		PowerCommandTopic:    c.topic("set/enabled"),
+		PowerCommandTemplate: valueTemplate,
+		PayloadOn:            "true",
+		PayloadOff:           "false",
+
+		TemperatureStateTopic:      c.topic("state/temperature_setpoint"),
+		TemperatureCommandTopic:    c.topic("set/temperature_setpoint"),
+		TemperatureCommandTemplate: valueTemplate,
+
+		CurrentTemperatureTopic: c.topic("state/ambient_temperature"),
+
+		FanModes:               []string{"auto", "low", "medium", "high"},
+		FanModeStateTopic:      c.topic("state/fan_speed"),
+		FanModeStateTemplate:   "{{ value_json }}",
+		FanModeCommandTopic:    c.topic("set/fan_speed"),
+		FanModeCommandTemplate: `{"value": "{{ value }}"}`,
+
+		MinTemp:         s.TemperatureSetpointMin,
+		MaxTemp:         s.TemperatureSetpointMax,
+		TempStep:        0.5,
+		Precision:       0.1,
+		TemperatureUnit: "C",
+	}
+}
+
+// publishDiscovery publishes the retained discovery config, then the
+// snapshot and every attribute topic so that Home Assistant has a state even
+// if they are not retained.
+func (c *Controller) publishDiscovery() {
+	s := c.svc.Get()
+	c.discoveryMu.Lock()
+	c.discovered = &[2]float64{s.TemperatureSetpointMin, s.TemperatureSetpointMax}
+	c.discoveryMu.Unlock()
+
+	c.pubMu.Lock()
+	clear(c.publishedAttrs)
+	c.pubMu.Unlock()
+
+	b, _ := json.Marshal(c.discoveryConfig(s))
+	c.publish(c.discoveryTopic(), true, b)
+	c.publishSnapshot()
+}
+
+// refreshDiscovery publishes the discovery config again when the setpoint
+// bounds, advertised as min_temp/max_temp, have changed.
+func (c *Controller) refreshDiscovery(s thermostat.Snapshot) {
+	c.discoveryMu.Lock()
+	d := c.discovered
+	stale := d != nil && (d[0] != s.TemperatureSetpointMin || d[1] != s.TemperatureSetpointMax)
+	c.discoveryMu.Unlock()
+	if stale {
+		c.publishDiscovery()
+	}
+}
+
+func (c *Controller) onHomeAssistantStatus(_ mqtt.Client, msg mqtt.Message) {
+	if string(msg.Payload()) == availabilityOnline {
+		c.log.Debug("home assistant restarted, publishing discovery")
+		c.publishDiscovery()
+	}
+}
@@ -0,0 +1,139 @@
+package mqttctrl
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func findPublish(fc *fakeClient, topic string) (publishCall, int) {
+	var last publishCall
+	n := 0
+	for _, p := range fc.publishes {
+		if p.topic == topic {
+			last = p
+			n++
+		}
+	}
+	return last, n
+}
+
+func TestOnConnect_PublishesDiscoveryAndAvailability(t *testing.T) {
+	svc := newDefaultSvc()
+	svc.S.TemperatureSetpointMin = 16
+	svc.S.TemperatureSetpointMax = 28
+	c, _ := New(svc, Config{DeviceID: "room101", HomeAssistant: true, QoS: 1}, nil)
+	fc := &fakeClient{}
+	c.client = fc
+
+	c.onConnect(fc)
+
+	p, n := findPublish(fc, "homeassistant/climate/room101/config")
+	if n != 1 {
+		t.Fatalf("expected 1 discovery publish, got %d", n)
+	}
+	if !p.retain || p.qos != 1 {
+		t.Fatalf("expected retained qos=1 discovery, got retain=%v qos=%d", p.retain, p.qos)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(p.payload, &got); err != nil {
+		t.Fatalf("invalid discovery json: %v", err)
+	}
+	want := map[string]any{
+		"unique_id":                 "thermocktat_room101",
+		"availability_topic":        "thermocktat/room101/availability",
+		"mode_state_topic":          "thermocktat/room101/state/mode",
+		"mode_command_topic":        "thermocktat/room101/set",
+		"power_state_topic":         "thermocktat/room101/state/enabled",
+		"power_command_topic":       "thermocktat/room101/set/enabled",
+		"temperature_state_topic":   "thermocktat/room101/state/temperature_setpoint",
+		"temperature_command_topic": "thermocktat/room101/set/temperature_setpoint",
+		"current_temperature_topic": "thermocktat/room101/state/ambient_temperature",
+		"fan_mode_state_topic":      "thermocktat/room101/state/fan_speed",
+		"fan_mode_command_topic":    "thermocktat/room101/set/fan_speed",
+		"min_temp":                  16.0,
+		"max_temp":                  28.0,
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %v, want %v", k, got[k], v)
+		}
+	}
+	for _, k := range []string{"temperature_state_template", "current_temperature_template"} {
+		if _, ok := got[k]; ok {
+			t.Errorf("unexpected %s: states are read from the attribute topics", k)
+		}
+	}
+
+	if _, n := findPublish(fc, "thermocktat/room101/snapshot"); n == 0 {
+		t.Fatal("expected a snapshot along with discovery")
+	}
+	for _, attr := range []string{"mode", "enabled", "temperature_setpoint", "ambient_temperature", "fan_speed"} {
+		if _, n := findPublish(fc, "thermocktat/room101/state/"+attr); n == 0 {
+			t.Errorf("expected state/%s to be published along with discovery", attr)
+		}
+	}
+	a, n := findPublish(fc, "thermocktat/room101/availability")
+	if n != 1 || string(a.payload) != availabilityOnline || !a.retain {
+		t.Fatalf("expected retained %q availability, got %+v (%d)", availabilityOnline, a, n)
+	}
+}
+
+func TestOnConnect_NoDiscoveryByDefault(t *testing.T) {
+	c, _ := New(newDefaultSvc(), Config{DeviceID: "room101"}, nil)
+	fc := &fakeClient{}
+	c.client = fc
+
+	c.onConnect(fc)
+
+	if _, n := findPublish(fc, "homeassistant/climate/room101/config"); n != 0 {
+		t.Fatal("discovery published while Home Assistant is disabled")
+	}
+	if _, n := findPublish(fc, "thermocktat/room101/availability"); n != 1 {
+		t.Fatal("expected availability to be published")
+	}
+}
+
+func TestPublishSnapshot_RefreshesDiscoveryOnNewBounds(t *testing.T) {
+	svc := newDefaultSvc()
+	c, _ := New(svc, Config{DeviceID: "room101", HomeAssistant: true, DiscoveryPrefix: "ha"}, nil)
+	fc := &fakeClient{}
+	c.client = fc
+	c.publishDiscovery()
+
+	c.publishSnapshot()
+	if _, n := findPublish(fc, "ha/climate/room101/config"); n != 1 {
+		t.Fatalf("expected no new discovery while bounds are unchanged, got %d", n)
+	}
+
+	svc.S.TemperatureSetpointMax = 25
+	c.publishSnapshot()
+	p, n := findPublish(fc, "ha/climate/room101/config")
+	if n != 2 {
+		t.Fatalf("expected discovery to be published again, got %d", n)
+	}
+	var got haClimateDTO
+	if err := json.Unmarshal(p.payload, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.MaxTemp != 25 {
+		t.Fatalf("max_temp = %v, want 25", got.MaxTemp)
+	}
+}
+
+func TestHomeAssistantStatus_Republishes(t *testing.T) {
+	c, _ := New(newDefaultSvc(), Config{DeviceID: "room101", HomeAssistant: true}, nil)
+	fc := &fakeClient{}
+	c.client = fc
+	c.publishSnapshot()
+
+	c.onHomeAssistantStatus(nil, fakeMessage{topic: "homeassistant/status", payload: []byte("offline")})
+	c.onHomeAssistantStatus(nil, fakeMessage{topic: "homeassistant/status", payload: []byte("online")})
+
+	if _, n := findPublish(fc, "homeassistant/climate/room101/config"); n != 1 {
+		t.Fatalf("expected 1 discovery publish after HA birth, got %d", n)
+	}
+	// Unchanged attributes are published again for Home Assistant.
+	if _, n := findPublish(fc, "thermocktat/room101/state/mode"); n != 2 {
+		t.Fatalf("expected state/mode to be published again, got %d", n)
+	}
+}
@@ -0,0 +1,195 @@
+package mqttctrl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// With PayloadHomie, the thermostat follows the Homie 4 convention
+// (https://homieiot.github.io/specification/spec-core-v4_0_0/): device
+// homie/<device_id> has one node, "thermostat", with a property per
+// snapshot attribute. Property values are plain strings ("21.5", "heat",
+// "true"), published retained, and written on <property>/set.
+
+const (
+	homieRoot = "homie"
+	homieNode = "thermostat"
+)
+
+// Device states, published retained to $state. The will is "lost".
+const (
+	homieInit         = "init"
+	homieReady        = "ready"
+	homieDisconnected = "disconnected"
+	homieLost         = "lost"
+)
+
+// homieID matches valid Homie topic IDs.
+var homieID = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// homieProperty describes the snapshot attribute attr as a Homie property,
+// whose ID is attr with dashes.
+type homieProperty struct {
+	attr     string
+	name     string
+	datatype string // integer, float, boolean or enum
+	format   string
+	unit     string
+	settable bool
+}
+
+var homieProperties = []homieProperty{
+	{attr: "enabled", name: "Enabled", datatype: "boolean", settable: true},
+	{attr: "temperature_setpoint", name: "Temperature Setpoint", datatype: "float", unit: "°C", settable: true},
+	{attr: "temperature_setpoint_min", name: "Temperature Setpoint Min", datatype: "float", unit: "°C", settable: true},
+	{attr: "temperature_setpoint_max", name: "Temperature Setpoint Max", datatype: "float", unit: "°C", settable: true},
+	{attr: "mode", name: "Mode", datatype: "enum", format: "heat,cool,fan,auto", settable: true},
+	{attr: "fan_speed", name: "Fan Speed", datatype: "enum", format: "auto,low,medium,high", settable: true},
+	{attr: "ambient_temperature", name: "Ambient Temperature", datatype: "float", unit: "°C"},
+	{attr: "fault_code", name: "Fault Code", datatype: "integer", settable: true},
+}
+
+func (p homieProperty) id() string {
+	return strings.ReplaceAll(p.attr, "_", "-")
+}
+
+func (c *Controller) homieTopic(suffix string) string {
+	return homieRoot + "/" + c.cfg.DeviceID + "/" + suffix
+}
+
+func validateHomie(cfg Config) error {
+	switch {
+	case !homieID.MatchString(cfg.DeviceID):
+		return fmt.Errorf("mqtt: homie requires a DeviceID of lowercase letters, digits and hyphens, got %q", cfg.DeviceID)
+	case cfg.HomeAssistant || cfg.AttributeTopics:
+		return errors.New("mqtt: homie cannot be combined with HomeAssistant or AttributeTopics")
+	}
+	return nil
+}
+
+// publishHomie publishes a retained Homie message. The convention asks for
+// QoS 1 whatever the configured QoS.
+func (c *Controller) publishHomie(suffix, payload string) mqtt.Token {
+	return c.client.Publish(c.homieTopic(suffix), 1, true, []byte(payload))
+}
+
+// onConnectHomie publishes the device description in the init state, then
+// the property values, and subscribes to the settable properties.
+func (c *Controller) onConnectHomie(cl mqttClient) {
+	c.publishHomie("$state", homieInit)
+	c.publishHomie("$homie", "4.0")
+	c.publishHomie("$name", c.cfg.DeviceID)
+	c.publishHomie("$nodes", homieNode)
+	c.publishHomie("$extensions", "")
+
+	node := homieNode + "/"
+	ids := make([]string, len(homieProperties))
+	for i, p := range homieProperties {
+		ids[i] = p.id()
+		prop := node + p.id() + "/"
+		c.publishHomie(prop+"$name", p.name)
+		c.publishHomie(prop+"$datatype", p.datatype)
+		c.publishHomie(prop+"$settable", strconv.FormatBool(p.settable))
+		if p.format != "" {
+			c.publishHomie(prop+"$format", p.format)
+		}
+		if p.unit != "" {
+			c.publishHomie(prop+"$unit", p.unit)
+		}
+	}
+	c.publishHomie(node+"$name", "Thermostat")
+	c.publishHomie(node+"$type", "thermostat")
+	c.publishHomie(node+"$properties", strings.Join(ids, ","))
+
+	c.publishHomieValues(true)
+	cl.Subscribe(c.homieTopic(node+"+/set"), c.cfg.QoS, c.onHomieSet).Wait()
+	c.publishHomie("$state", homieReady)
+}
+
+// publishHomieValues publishes the property values that changed since they
+// were last published, or all of them.
+func (c *Controller) publishHomieValues(all bool) {
+	dto := toSnapshotDTO(c.svc.Get(), c.cfg.DeviceID)
+
+	c.pubMu.Lock()
+	record(c.published, dto.attributes())
+	changed := dto.attributes()
+	if !all {
+		changed = changedAttributes(c.publishedAttrs, dto, c.cfg.Deadband)
+	}
+	record(c.publishedAttrs, changed)
+	c.pubMu.Unlock()
+
+	for _, a := range changed {
+		c.publishHomie(homieNode+"/"+strings.ReplaceAll(a.name, "_", "-"), homieValue(a.value))
+	}
+}
+
+func homieValue(v any) string {
+	switch v := v.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// onHomieSet applies <property>/set. Homie has no error reporting: invalid
+// values are logged, and the property keeps its published value.
+func (c *Controller) onHomieSet(_ mqtt.Client, msg mqtt.Message) {
+	c.log.Debug("mqtt message received", "topic", msg.Topic(), "payload_len", len(msg.Payload()))
+	id, ok := strings.CutPrefix(msg.Topic(), c.homieTopic(homieNode+"/"))
+	if !ok {
+		return
+	}
+	id = strings.TrimSuffix(id, "/set")
+	var prop *homieProperty
+	for i, p := range homieProperties {
+		if p.id() == id && p.settable {
+			prop = &homieProperties[i]
+		}
+	}
+	if prop == nil {
+		return
+	}
+
+	payload, err := homieSetPayload(prop.datatype, string(msg.Payload()))
+	if err == nil {
+		_, err = c.setAttribute(prop.attr, payload)
+	}
+	if err != nil {
+		c.log.Warn("homie set rejected", "property", id, "err", err)
+		return
+	}
+	c.publishSnapshot()
+}
+
+// homieSetPayload converts a Homie value to the {"value": ...} payload of
+// setAttribute.
+func homieSetPayload(datatype, s string) ([]byte, error) {
+	var v any
+	var err error
+	switch datatype {
+	case "boolean":
+		if s != "true" && s != "false" {
+			return nil, fmt.Errorf("invalid boolean %q", s)
+		}
+		v = s == "true"
+	case "float":
+		v, err = strconv.ParseFloat(s, 64)
+	case "integer":
+		v, err = strconv.ParseInt(s, 10, 64)
+	default:
+		v = s
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(map[string]any{"value": v})
+}
@@ -0,0 +1,106 @@
+package mqttctrl
+
+import (
+	"testing"
+
+	"github.com/Agrid-Dev/thermocktat/internal/testutil"
+)
+
+func newHomieController(t *testing.T) (*Controller, *testutil.FakeThermostatService, *fakeClient) {
+	t.Helper()
+	svc := newDefaultSvc()
+	svc.S.TemperatureSetpoint = 21.5
+	c, err := New(svc, Config{DeviceID: "room101", PayloadFormat: PayloadHomie}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc := &fakeClient{}
+	c.client = fc
+	return c, svc, fc
+}
+
+func assertHomie(t *testing.T, fc *fakeClient, topic, want string) {
+	t.Helper()
+	p, n := findPublish(fc, "homie/room101/"+topic)
+	if n == 0 {
+		t.Fatalf("%s not published", topic)
+	}
+	if string(p.payload) != want || !p.retain || p.qos != 1 {
+		t.Fatalf("%s = %q (retain %v, QoS %d), want %q retained with QoS 1", topic, p.payload, p.retain, p.qos, want)
+	}
+}
+
+func TestHomie_DeviceDescription(t *testing.T) {
+	c, _, fc := newHomieController(t)
+	c.onConnect(fc)
+
+	if first := fc.publishes[0]; first.topic != "homie/room101/$state" || string(first.payload) != homieInit {
+		t.Fatalf("expected $state init first, got %s %q", first.topic, first.payload)
+	}
+	if last := fc.publishes[len(fc.publishes)-1]; last.topic != "homie/room101/$state" || string(last.payload) != homieReady {
+		t.Fatalf("expected $state ready last, got %s %q", last.topic, last.payload)
+	}
+	assertHomie(t, fc, "$homie", "4.0")
+	assertHomie(t, fc, "$nodes", "thermostat")
+	assertHomie(t, fc, "thermostat/$properties",
+		"enabled,temperature-setpoint,temperature-setpoint-min,temperature-setpoint-max,mode,fan-speed,ambient-temperature,fault-code")
+	assertHomie(t, fc, "thermostat/mode/$datatype", "enum")
+	assertHomie(t, fc, "thermostat/mode/$format", "heat,cool,fan,auto")
+	assertHomie(t, fc, "thermostat/temperature-setpoint/$unit", "°C")
+	assertHomie(t, fc, "thermostat/ambient-temperature/$settable", "false")
+	assertHomie(t, fc, "thermostat/fault-code/$datatype", "integer")
+
+	assertHomie(t, fc, "thermostat/temperature-setpoint", "21.5")
+	assertHomie(t, fc, "thermostat/mode", "auto")
+	assertHomie(t, fc, "thermostat/enabled", "true")
+}
+
+func TestHomie_Set(t *testing.T) {
+	c, svc, fc := newHomieController(t)
+	c.onConnect(fc)
+	fc.publishes = nil
+
+	c.onHomieSet(nil, fakeMessage{topic: "homie/room101/thermostat/temperature-setpoint/set", payload: []byte("23")})
+	if !svc.SetSetpointCalled || svc.SetSetpointArg != 23 {
+		t.Fatalf("expected SetSetpoint(23), got %v %v", svc.SetSetpointCalled, svc.SetSetpointArg)
+	}
+	assertHomie(t, fc, "thermostat/temperature-setpoint", "23")
+	if len(fc.publishes) != 1 {
+		t.Fatalf("expected only the changed property, got %+v", fc.publishes)
+	}
+
+	c.onHomieSet(nil, fakeMessage{topic: "homie/room101/thermostat/fan-speed/set", payload: []byte("high")})
+	assertHomie(t, fc, "thermostat/fan-speed", "high")
+}
+
+func TestHomie_SetRejected(t *testing.T) {
+	tests := []struct{ topic, payload string }{
+		{"homie/room101/thermostat/enabled/set", "yes"},
+		{"homie/room101/thermostat/temperature-setpoint/set", "warm"},
+		{"homie/room101/thermostat/mode/set", "turbo"},
+		{"homie/room101/thermostat/ambient-temperature/set", "30"}, // not settable
+	}
+	for _, tt := range tests {
+		c, svc, fc := newHomieController(t)
+		c.onConnect(fc)
+		fc.publishes = nil
+		c.onHomieSet(nil, fakeMessage{topic: tt.topic, payload: []byte(tt.payload)})
+		if len(fc.publishes) != 0 || svc.SetEnabledCalled || svc.SetSetpointCalled || svc.SetModeCalled {
+			t.Errorf("%s %q: expected the value to be rejected", tt.topic, tt.payload)
+		}
+	}
+}
+
+func TestHomie_WillAndValidation(t *testing.T) {
+	c, _, _ := newHomieController(t)
+	opts := c.clientOptions()
+	if opts.WillTopic != "homie/room101/$state" || string(opts.WillPayload) != homieLost || !opts.WillRetained {
+		t.Fatalf("unexpected will %s %q", opts.WillTopic, opts.WillPayload)
+	}
+
+	for _, id := range []string{"Room101", "room_101", "-room"} {
+		if _, err := New(newDefaultSvc(), Config{DeviceID: id, PayloadFormat: PayloadHomie}, nil); err == nil {
+			t.Errorf("expected error for device id %q", id)
+		}
+	}
+}
@@ -0,0 +1,371 @@
+package mqttctrl
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+	"github.com/eclipse/paho.golang/autopaho"
+	"github.com/eclipse/paho.golang/packets"
+	"github.com/eclipse/paho.golang/paho"
+	"github.com/eclipse/paho.golang/paho/session/state"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// MQTT 5 runs on paho.golang. client5 adapts its connection to mqttClient,
+// the subset of mqtt.Client the rest of the controller uses, so that both
+// protocol versions share topics and payloads; MQTT 5 properties are added
+// by publish and reply.
+
+// Protocol versions, as MQTT protocol levels.
+const (
+	ProtocolV311 = 4
+	ProtocolV5   = 5
+)
+
+// MQTT 5 reason codes of the PUBACK of QoS 1 commands, also reported in
+// responses.
+const (
+	reasonSuccess             byte = 0x00
+	reasonUnspecifiedError    byte = 0x80
+	reasonImplementationError byte = 0x83
+	reasonPayloadFormat       byte = 0x99
+)
+
+// reasonCode maps an error code to an MQTT 5 reason code: malformed
+// commands are invalid payloads, commands rejected by the thermostat are
+// implementation specific errors.
+func reasonCode(code thermostat.ErrorCode) byte {
+	switch code {
+	case "":
+		return reasonSuccess
+	case thermostat.CodeInvalidRequest:
+		return reasonPayloadFormat
+	case thermostat.CodeInternal:
+		return reasonUnspecifiedError
+	default:
+		return reasonImplementationError
+	}
+}
+
+// conn5 is the part of an autopaho connection used by client5.
+type conn5 interface {
+	Publish(context.Context, *paho.Publish) (*paho.PublishResponse, error)
+	Subscribe(context.Context, *paho.Subscribe) (*paho.Suback, error)
+	AwaitConnection(context.Context) error
+	Disconnect(context.Context) error
+}
+
+const packetTimeout = 10 * time.Second
+
+type client5 struct {
+	user    paho.UserProperties // added to every message
+	stop    context.CancelFunc  // ends the connection manager
+	session *ackSession         // nil until connect5
+
+	mu       sync.Mutex
+	conn     conn5
+	handlers map[string]mqtt.MessageHandler // by topic filter
+}
+
+var _ mqttClient = (*client5)(nil)
+
+func newClient5(deviceID string) *client5 {
+	return &client5{
+		user:     paho.UserProperties{{Key: "device_id", Value: deviceID}},
+		handlers: make(map[string]mqtt.MessageHandler),
+	}
+}
+
+// connect5 connects to the broker with MQTT 5, retrying until ctx is done.
+func (c *Controller) connect5(ctx context.Context) error {
+	u, err := url.Parse(c.cfg.BrokerURL)
+	if err != nil {
+		return fmt.Errorf("mqtt: invalid broker url: %w", err)
+	}
+	cl := newClient5(c.cfg.DeviceID)
+	cl.session = newAckSession()
+	keepAlive := c.cfg.KeepAlive
+	if keepAlive == 0 {
+		keepAlive = 30 * time.Second
+	}
+	cfg := autopaho.ClientConfig{
+		ServerUrls:                    []*url.URL{u},
+		KeepAlive:                     uint16(keepAlive.Seconds()),
+		CleanStartOnInitialConnection: true,
+		ReconnectBackoff:              autopaho.NewConstantBackoff(2 * time.Second),
+		TlsCfg:                        c.tls,
+		OnConnectionUp: func(cm *autopaho.ConnectionManager, _ *paho.Connack) {
+			cl.attach(cm)
+			// Subscribing waits for the broker, which this callback must not.
+			go c.onConnect(cl)
+		},
+		OnConnectionDown: func() bool {
+			c.log.Warn("mqtt broker connection lost")
+			return true
+		},
+		OnConnectError: func(err error) {
+			c.log.Warn("mqtt connect failed", "err", err)
+		},
+		ClientConfig: paho.ClientConfig{
+			ClientID: c.cfg.ClientID,
+			Session:  cl.session,
+			OnPublishReceived: []func(paho.PublishReceived) (bool, error){
+				func(pr paho.PublishReceived) (bool, error) {
+					return cl.route(pr.Packet), nil
+				},
+			},
+		},
+	}
+	cfg.SetWillMessage(c.will())
+	if c.cfg.Username != "" {
+		cfg.SetUsernamePassword(c.cfg.Username, []byte(c.cfg.Password))
+	}
+
+	// Published messages, including from onConnect, go through cl.
+	c.client = cl
+
+	// The connection outlives ctx so that "offline" can be published on
+	// shutdown; Disconnect ends it.
+	connCtx, stop := context.WithCancel(context.Background())
+	cl.stop = stop
+	cm, err := autopaho.NewConnection(connCtx, cfg)
+	if err != nil {
+		stop()
+		return fmt.Errorf("mqtt connect: %w", err)
+	}
+	cl.attach(cm)
+	if err := cm.AwaitConnection(ctx); err != nil {
+		stop()
+		return fmt.Errorf("mqtt connect: %w", err)
+	}
+	return nil
+}
+
+func (cl *client5) attach(conn conn5) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	cl.conn = conn
+}
+
+func (cl *client5) connection() conn5 {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	return cl.conn
+}
+
+// route passes an incoming message to the handlers of the matching
+// subscriptions, and reports whether there was one.
+func (cl *client5) route(p *paho.Publish) bool {
+	cl.mu.Lock()
+	var hs []mqtt.MessageHandler
+	for filter, h := range cl.handlers {
+		if topicMatches(filter, p.Topic) {
+			hs = append(hs, h)
+		}
+	}
+	cl.mu.Unlock()
+	// Handlers do not use their client argument.
+	for _, h := range hs {
+		h(nil, message5{p})
+	}
+	return len(hs) > 0
+}
+
+// topicMatches reports whether topic matches filter, with MQTT wildcards.
+func topicMatches(filter, topic string) bool {
+	fs, ts := strings.Split(filter, "/"), strings.Split(topic, "/")
+	for i, f := range fs {
+		switch {
+		case f == "#":
+			return true
+		case i >= len(ts):
+			return false
+		case f != "+" && f != ts[i]:
+			return false
+		}
+	}
+	return len(fs) == len(ts)
+}
+
+// publish5 publishes p with the client's user properties added.
+func (cl *client5) publish5(p *paho.Publish) error {
+	if p.Properties == nil {
+		p.Properties = &paho.PublishProperties{}
+	}
+	p.Properties.User = append(append(paho.UserProperties{}, cl.user...), p.Properties.User...)
+	ctx, cancel := context.WithTimeout(context.Background(), packetTimeout)
+	defer cancel()
+	_, err := cl.connection().Publish(ctx, p)
+	return err
+}
+
+func (cl *client5) Disconnect(quiesce uint) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(quiesce)*time.Millisecond)
+	defer cancel()
+	_ = cl.connection().Disconnect(ctx)
+	if cl.stop != nil {
+		cl.stop()
+	}
+}
+
+func (cl *client5) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+	var b []byte
+	switch v := payload.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return token5{fmt.Errorf("mqtt: unsupported payload type %T", payload)}
+	}
+	return token5{cl.publish5(&paho.Publish{Topic: topic, QoS: qos, Retain: retained, Payload: b})}
+}
+
+func (cl *client5) Subscribe(topic string, qos byte, callback mqtt.MessageHandler) mqtt.Token {
+	cl.mu.Lock()
+	cl.handlers[topic] = callback
+	cl.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), packetTimeout)
+	defer cancel()
+	s := &paho.Subscribe{Subscriptions: []paho.SubscribeOptions{{Topic: topic, QoS: qos}}}
+	_, err := cl.connection().Subscribe(ctx, s)
+	return token5{err}
+}
+
+// token5 is an already completed mqtt.Token: client5 calls are synchronous,
+// so Wait returns once the broker has answered or packetTimeout expired.
+type token5 struct{ err error }
+
+func (t token5) Wait() bool                     { return true }
+func (t token5) WaitTimeout(time.Duration) bool { return true }
+func (t token5) Error() error                   { return t.err }
+func (t token5) Done() <-chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}
+
+// ackSession is paho.golang's in-memory session, except that QoS 1
+// commands are acknowledged with the reason code of their outcome, where
+// paho.golang always acknowledges with success.
+type ackSession struct {
+	*state.State
+
+	mu      sync.Mutex
+	conn    io.Writer       // nil while disconnected
+	reasons map[uint16]byte // by packet id, until acknowledged
+}
+
+func newAckSession() *ackSession {
+	return &ackSession{State: state.NewInMemory(), reasons: make(map[uint16]byte)}
+}
+
+func (s *ackSession) ConAckReceived(conn io.Writer, cp *packets.Connect, ca *packets.Connack) error {
+	s.mu.Lock()
+	s.conn = conn
+	s.mu.Unlock()
+	return s.State.ConAckReceived(conn, cp, ca)
+}
+
+func (s *ackSession) ConnectionLost(dp *packets.Disconnect) error {
+	s.mu.Lock()
+	s.conn = nil
+	clear(s.reasons)
+	s.mu.Unlock()
+	return s.State.ConnectionLost(dp)
+}
+
+// setReason records the reason code to acknowledge the message p with.
+func (s *ackSession) setReason(p *paho.Publish, code byte) {
+	if p.QoS != 1 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reasons[p.PacketID] = code
+}
+
+// Ack is called once the handlers have run.
+func (s *ackSession) Ack(pb *packets.Publish) error {
+	s.mu.Lock()
+	code, ok := s.reasons[pb.PacketID]
+	delete(s.reasons, pb.PacketID)
+	conn := s.conn
+	s.mu.Unlock()
+	if !ok || code == reasonSuccess || pb.QoS != 1 {
+		return s.State.Ack(pb)
+	}
+	if conn == nil {
+		return nil // the broker sends the message again on reconnection
+	}
+	// Encoded first so that the packet is written at once.
+	var b bytes.Buffer
+	puback := &packets.Puback{PacketID: pb.PacketID, ReasonCode: code, Properties: &packets.Properties{}}
+	if _, err := puback.WriteTo(&b); err != nil {
+		return err
+	}
+	_, err := conn.Write(b.Bytes())
+	return err
+}
+
+// message5 is an incoming MQTT 5 message, with its properties.
+type message5 struct{ p *paho.Publish }
+
+func (m message5) Duplicate() bool   { return false }
+func (m message5) Qos() byte         { return m.p.QoS }
+func (m message5) Retained() bool    { return m.p.Retain }
+func (m message5) Topic() string     { return m.p.Topic }
+func (m message5) MessageID() uint16 { return m.p.PacketID }
+func (m message5) Payload() []byte   { return m.p.Payload }
+func (m message5) Ack()              {}
+
+// publish sends payload to topic. On MQTT 5, user properties are attached
+// to the message, along with the device id; MQTT 3.1.1 drops them.
+func (c *Controller) publish(topic string, retain bool, payload []byte, user ...paho.UserProperty) mqtt.Token {
+	if cl, ok := c.client.(*client5); ok {
+		p := &paho.Publish{Topic: topic, QoS: c.cfg.QoS, Retain: retain, Payload: payload}
+		p.Properties = &paho.PublishProperties{User: user}
+		err := cl.publish5(p)
+		if err != nil {
+			c.log.Warn("mqtt publish failed", "topic", topic, "err", err)
+		}
+		return token5{err}
+	}
+	return c.client.Publish(topic, c.cfg.QoS, retain, payload)
+}
+
+// reply sends payload to the Response Topic of an MQTT 5 request, with its
+// Correlation Data. It reports false when msg has no Response Topic: the
+// answer then goes to the usual topic.
+func (c *Controller) reply(msg mqtt.Message, payload []byte, user ...paho.UserProperty) bool {
+	m, ok := msg.(message5)
+	cl, isV5 := c.client.(*client5)
+	if !ok || !isV5 || m.p.Properties == nil || m.p.Properties.ResponseTopic == "" {
+		return false
+	}
+	p := &paho.Publish{
+		Topic:   m.p.Properties.ResponseTopic,
+		QoS:     c.cfg.QoS,
+		Payload: payload,
+		Properties: &paho.PublishProperties{
+			CorrelationData: m.p.Properties.CorrelationData,
+			User:            user,
+		},
+	}
+	if err := cl.publish5(p); err != nil {
+		c.log.Warn("mqtt reply failed", "topic", p.Topic, "err", err)
+	}
+	return true
+}
+
+func versionProperty(v uint64) paho.UserProperty {
+	return paho.UserProperty{Key: "version", Value: strconv.FormatUint(v, 10)}
+}
@@ -0,0 +1,252 @@
+package mqttctrl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+	"github.com/eclipse/paho.golang/packets"
+	"github.com/eclipse/paho.golang/paho"
+)
+
+type fakeConn5 struct {
+	mu         sync.Mutex
+	publishes  []*paho.Publish
+	subscribes []paho.SubscribeOptions
+}
+
+func (f *fakeConn5) Publish(_ context.Context, p *paho.Publish) (*paho.PublishResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.publishes = append(f.publishes, p)
+	return &paho.PublishResponse{}, nil
+}
+
+func (f *fakeConn5) Subscribe(_ context.Context, s *paho.Subscribe) (*paho.Suback, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.subscribes = append(f.subscribes, s.Subscriptions...)
+	return &paho.Suback{}, nil
+}
+
+func (f *fakeConn5) AwaitConnection(context.Context) error { return nil }
+func (f *fakeConn5) Disconnect(context.Context) error      { return nil }
+
+func (f *fakeConn5) find(topic string) []*paho.Publish {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var ps []*paho.Publish
+	for _, p := range f.publishes {
+		if p.Topic == topic {
+			ps = append(ps, p)
+		}
+	}
+	return ps
+}
+
+// newController5 returns a controller on a fake MQTT 5 connection, with its
+// subscriptions made.
+func newController5(t *testing.T, svc thermostat.Service) (*Controller, *client5, *fakeConn5) {
+	t.Helper()
+	c, err := New(svc, Config{DeviceID: "room101", ProtocolVersion: ProtocolV5, QoS: 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := &fakeConn5{}
+	cl := newClient5("room101")
+	cl.attach(conn)
+	c.client = cl
+	c.onConnect(cl)
+	return c, cl, conn
+}
+
+func assertUserProperty(t *testing.T, p *paho.Publish, key, want string) {
+	t.Helper()
+	if got := p.Properties.User.Get(key); got != want {
+		t.Fatalf("%s: user property %s = %q, want %q", p.Topic, key, got, want)
+	}
+}
+
+func TestTopicMatches(t *testing.T) {
+	tests := []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"a/set/+", "a/set/mode", true},
+		{"a/set/+", "a/set", false},
+		{"a/set/+", "a/set/mode/x", false},
+		{"a/set", "a/set", true},
+		{"a/#", "a/set/mode", true},
+		{"a/get/+", "a/set/mode", false},
+	}
+	for _, tt := range tests {
+		if got := topicMatches(tt.filter, tt.topic); got != tt.want {
+			t.Errorf("topicMatches(%q, %q) = %v, want %v", tt.filter, tt.topic, got, tt.want)
+		}
+	}
+}
+
+func TestClient5_SubscribesAndPublishesWithDeviceID(t *testing.T) {
+	_, _, conn := newController5(t, newDefaultSvc())
+
+	if len(conn.subscribes) != 3 {
+		t.Fatalf("expected 3 subscriptions, got %+v", conn.subscribes)
+	}
+	ps := conn.find("thermocktat/room101/availability")
+	if len(ps) != 1 || string(ps[0].Payload) != availabilityOnline || !ps[0].Retain || ps[0].QoS != 1 {
+		t.Fatalf("expected retained online availability, got %+v", ps)
+	}
+	assertUserProperty(t, ps[0], "device_id", "room101")
+}
+
+func TestMQTT5_GetWithResponseTopic(t *testing.T) {
+	svc := newDefaultSvc()
+	svc.S.Version = 7
+	_, cl, conn := newController5(t, svc)
+
+	cl.route(&paho.Publish{
+		Topic:      "thermocktat/room101/get/snapshot",
+		Properties: &paho.PublishProperties{ResponseTopic: "gw/replies", CorrelationData: []byte("req-1")},
+	})
+
+	if ps := conn.find("thermocktat/room101/snapshot"); len(ps) != 0 {
+		t.Fatalf("snapshot broadcast despite a response topic: %+v", ps)
+	}
+	ps := conn.find("gw/replies")
+	if len(ps) != 1 {
+		t.Fatalf("expected 1 reply, got %d", len(ps))
+	}
+	if string(ps[0].Properties.CorrelationData) != "req-1" {
+		t.Fatalf("correlation data = %q", ps[0].Properties.CorrelationData)
+	}
+	var got snapshotDTO
+	if err := json.Unmarshal(ps[0].Payload, &got); err != nil || got.DeviceId != "room101" {
+		t.Fatalf("invalid snapshot reply %s: %v", ps[0].Payload, err)
+	}
+	assertUserProperty(t, ps[0], "device_id", "room101")
+	assertUserProperty(t, ps[0], "version", "7")
+}
+
+func TestMQTT5_SetWithResponseTopic(t *testing.T) {
+	svc := newDefaultSvc()
+	_, cl, conn := newController5(t, svc)
+
+	cl.route(&paho.Publish{
+		Topic:      "thermocktat/room101/set/mode",
+		Payload:    []byte(`{"value":"heat"}`),
+		Properties: &paho.PublishProperties{ResponseTopic: "gw/replies", CorrelationData: []byte{1, 2}},
+	})
+	cl.route(&paho.Publish{
+		Topic:      "thermocktat/room101/set/fan_speed",
+		Payload:    []byte(`{"value":`),
+		Properties: &paho.PublishProperties{ResponseTopic: "gw/replies", CorrelationData: []byte{3}},
+	})
+
+	if !svc.SetModeCalled {
+		t.Fatal("expected SetMode called")
+	}
+	if ps := conn.find("thermocktat/room101/response"); len(ps) != 0 {
+		t.Fatalf("response broadcast despite a response topic: %+v", ps)
+	}
+	ps := conn.find("gw/replies")
+	if len(ps) != 2 {
+		t.Fatalf("expected 2 replies, got %d", len(ps))
+	}
+	var accepted, rejected responseDTO
+	_ = json.Unmarshal(ps[0].Payload, &accepted)
+	_ = json.Unmarshal(ps[1].Payload, &rejected)
+	if accepted.Status != statusAccepted || string(ps[0].Properties.CorrelationData) != "\x01\x02" {
+		t.Fatalf("unexpected reply %s", ps[0].Payload)
+	}
+	assertUserProperty(t, ps[0], "reason_code", "0x00")
+	if rejected.Code != thermostat.CodeInvalidRequest || string(ps[1].Properties.CorrelationData) != "\x03" {
+		t.Fatalf("unexpected reply %s", ps[1].Payload)
+	}
+	assertUserProperty(t, ps[1], "reason_code", "0x99")
+
+	// The snapshot is still broadcast after a write.
+	if ps := conn.find("thermocktat/room101/snapshot"); len(ps) == 0 {
+		t.Fatal("expected snapshot publish after the write")
+	}
+}
+
+func TestMQTT5_SetWithoutResponseTopic(t *testing.T) {
+	svc := newDefaultSvc()
+	svc.SetSetpointErr = thermostat.ErrSetpointOutOfRange
+	_, cl, conn := newController5(t, svc)
+
+	cl.route(&paho.Publish{Topic: "thermocktat/room101/set/temperature_setpoint", Payload: []byte(`{"value":40}`)})
+
+	ps := conn.find("thermocktat/room101/response")
+	if len(ps) != 1 {
+		t.Fatalf("expected 1 response, got %d", len(ps))
+	}
+	assertUserProperty(t, ps[0], "reason_code", "0x83")
+	assertUserProperty(t, ps[0], "device_id", "room101")
+}
+
+func TestMQTT5_PubackReasonCode(t *testing.T) {
+	_, cl, _ := newController5(t, newDefaultSvc())
+	cl.session = newAckSession()
+	var conn bytes.Buffer
+	cl.session.conn = &conn
+
+	ack := func(p *paho.Publish) *packets.Puback {
+		t.Helper()
+		conn.Reset()
+		cl.route(p)
+		if err := cl.session.Ack(&packets.Publish{QoS: p.QoS, PacketID: p.PacketID}); err != nil {
+			t.Fatalf("Ack: %v", err)
+		}
+		if conn.Len() == 0 {
+			return nil
+		}
+		cp, err := packets.ReadPacket(&conn)
+		if err != nil {
+			t.Fatalf("read PUBACK: %v", err)
+		}
+		return cp.Content.(*packets.Puback)
+	}
+
+	pa := ack(&paho.Publish{Topic: "thermocktat/room101/set", QoS: 1, PacketID: 7, Payload: []byte(`{"mode":`)})
+	if pa == nil || pa.PacketID != 7 || pa.ReasonCode != reasonPayloadFormat {
+		t.Fatalf("PUBACK = %+v, want packet 7 with reason %#x", pa, reasonPayloadFormat)
+	}
+	pa = ack(&paho.Publish{Topic: "thermocktat/room101/set", QoS: 1, PacketID: 8, Payload: []byte(`{"mode":"dry"}`)})
+	if pa == nil || pa.ReasonCode != reasonImplementationError {
+		t.Fatalf("PUBACK = %+v, want reason %#x", pa, reasonImplementationError)
+	}
+	// Accepted commands are acknowledged by paho.golang's session, which has
+	// no connection here.
+	if pa := ack(&paho.Publish{Topic: "thermocktat/room101/set", QoS: 1, PacketID: 9, Payload: []byte(`{"mode":"heat"}`)}); pa != nil {
+		t.Fatalf("unexpected PUBACK %+v for an accepted command", pa)
+	}
+}
+
+func TestReasonCode(t *testing.T) {
+	tests := map[thermostat.ErrorCode]byte{
+		"":                                reasonSuccess,
+		thermostat.CodeInvalidRequest:     reasonPayloadFormat,
+		thermostat.CodeInvalidMode:        reasonImplementationError,
+		thermostat.CodeSetpointOutOfRange: reasonImplementationError,
+		thermostat.CodeInternal:           reasonUnspecifiedError,
+	}
+	for code, want := range tests {
+		if got := reasonCode(code); got != want {
+			t.Errorf("reasonCode(%q) = %#x, want %#x", code, got, want)
+		}
+	}
+}
+
+func TestNewValidation_ProtocolVersion(t *testing.T) {
+	c, err := New(newDefaultSvc(), Config{DeviceID: "x"}, nil)
+	if err != nil || c.cfg.ProtocolVersion != ProtocolV311 {
+		t.Fatalf("expected MQTT 3.1.1 by default, got %d (%v)", c.cfg.ProtocolVersion, err)
+	}
+	if _, err := New(newDefaultSvc(), Config{DeviceID: "x", ProtocolVersion: 3}, nil); err == nil {
+		t.Fatal("expected error for protocol version 3")
+	}
+}
@@ -0,0 +1,88 @@
+package mqttctrl
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+	"github.com/eclipse/paho.golang/paho"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Response statuses.
+const (
+	statusAccepted = "accepted"
+	statusRejected = "rejected"
+)
+
+// responseDTO is published to <base>/response for every command on a set
+// topic, so that clients need not infer failures from the snapshot.
+type responseDTO struct {
+	// RequestID echoes the command's request_id, any JSON value.
+	RequestID json.RawMessage      `json:"request_id,omitempty"`
+	Topic     string               `json:"topic"`
+	Field     string               `json:"field,omitempty"` // empty for <base>/set
+	Status    string               `json:"status"`
+	Code      thermostat.ErrorCode `json:"code,omitempty"`
+	Message   string               `json:"message,omitempty"`
+}
+
+// errorDTO is published to <base>/error when a command is rejected. Code is
+// one of the thermostat error codes, also used by the HTTP controller.
+type errorDTO struct {
+	Topic   string               `json:"topic"`
+	Code    thermostat.ErrorCode `json:"code"`
+	Message string               `json:"message"`
+}
+
+// respond reports the outcome of the command msg: err is nil when it was
+// accepted, otherwise code classifies it. On MQTT 5, a QoS 1 command is
+// acknowledged with the matching reason code, and the response goes to its
+// Response Topic if it has one, with the reason code and snapshot version as
+// user properties.
+func (c *Controller) respond(msg mqtt.Message, field string, code thermostat.ErrorCode, err error) {
+	topic := msg.Topic()
+	resp := responseDTO{
+		RequestID: requestID(msg.Payload()),
+		Topic:     topic,
+		Field:     field,
+		Status:    statusAccepted,
+	}
+	if err != nil {
+		c.log.Warn("mqtt command rejected", "topic", topic, "code", code, "err", err)
+		c.publishError(topic, code, err)
+		resp.Status, resp.Code, resp.Message = statusRejected, code, err.Error()
+	}
+	b, _ := json.Marshal(resp)
+	var props []paho.UserProperty
+	if cl, ok := c.client.(*client5); ok {
+		reason := reasonCode(code)
+		if m, ok := msg.(message5); ok && cl.session != nil {
+			cl.session.setReason(m.p, reason)
+		}
+		props = []paho.UserProperty{
+			{Key: "reason_code", Value: fmt.Sprintf("0x%02x", reason)},
+			versionProperty(c.svc.Get().Version),
+		}
+	}
+	if !c.reply(msg, b, props...) {
+		c.publish(c.topic("response"), false, b, props...)
+	}
+}
+
+func (c *Controller) publishError(topic string, code thermostat.ErrorCode, err error) {
+	b, _ := json.Marshal(errorDTO{Topic: topic, Code: code, Message: err.Error()})
+	c.publish(c.topic("error"), false, b)
+}
+
+// requestID extracts the request_id of a command payload, even one that is
+// otherwise invalid.
+func requestID(payload []byte) json.RawMessage {
+	var req struct {
+		RequestID json.RawMessage `json:"request_id"`
+	}
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil
+	}
+	return req.RequestID
+}
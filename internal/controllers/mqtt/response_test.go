@@ -0,0 +1,101 @@
+package mqttctrl
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+)
+
+func assertResponse(t *testing.T, fc *fakeClient, want responseDTO) {
+	t.Helper()
+	p, n := findPublish(fc, "thermocktat/room101/response")
+	if n != 1 {
+		t.Fatalf("expected 1 response, got %d in %+v", n, fc.publishes)
+	}
+	if p.retain {
+		t.Fatal("responses must not be retained")
+	}
+	var got responseDTO
+	if err := json.Unmarshal(p.payload, &got); err != nil {
+		t.Fatalf("invalid response payload %s: %v", p.payload, err)
+	}
+	if want.Status == statusRejected && got.Message == "" {
+		t.Fatalf("rejected response without message: %s", p.payload)
+	}
+	got.Message = ""
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("response = %s, want %+v", p.payload, want)
+	}
+}
+
+func TestOnMessage_PublishesResponses(t *testing.T) {
+	tests := []struct {
+		name    string
+		topic   string
+		payload string
+		want    responseDTO
+	}{
+		{
+			"accepted with request id", "thermocktat/room101/set/mode", `{"value":"heat","request_id":"42"}`,
+			responseDTO{RequestID: json.RawMessage(`"42"`), Topic: "thermocktat/room101/set/mode", Field: "mode", Status: statusAccepted},
+		},
+		{
+			"accepted without request id", "thermocktat/room101/set/enabled", `{"value":false}`,
+			responseDTO{Topic: "thermocktat/room101/set/enabled", Field: "enabled", Status: statusAccepted},
+		},
+		{
+			"rejected enum", "thermocktat/room101/set/fan_speed", `{"value":"turbo","request_id":7}`,
+			responseDTO{
+				RequestID: json.RawMessage(`7`), Topic: "thermocktat/room101/set/fan_speed", Field: "fan_speed",
+				Status: statusRejected, Code: thermostat.CodeInvalidFanSpeed,
+			},
+		},
+		{
+			"malformed payload keeps request id", "thermocktat/room101/set/temperature_setpoint", `{"value":"hot","request_id":"a"}`,
+			responseDTO{
+				RequestID: json.RawMessage(`"a"`), Topic: "thermocktat/room101/set/temperature_setpoint", Field: "temperature_setpoint",
+				Status: statusRejected, Code: thermostat.CodeInvalidRequest,
+			},
+		},
+		{
+			"patch", "thermocktat/room101/set", `{"mode":"cool","request_id":"b"}`,
+			responseDTO{RequestID: json.RawMessage(`"b"`), Topic: "thermocktat/room101/set", Status: statusAccepted},
+		},
+		{
+			"simulation", "thermocktat/room101/set/simulation", `{"regulator":{"interval":"soon"},"request_id":"c"}`,
+			responseDTO{
+				RequestID: json.RawMessage(`"c"`), Topic: "thermocktat/room101/set/simulation", Field: "simulation",
+				Status: statusRejected, Code: thermostat.CodeInvalidRequest,
+			},
+		},
+		{
+			"invalid json", "thermocktat/room101/set", `{"mode":`,
+			responseDTO{Topic: "thermocktat/room101/set", Status: statusRejected, Code: thermostat.CodeInvalidRequest},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := New(newDefaultSvc(), Config{DeviceID: "room101"}, nil)
+			fc := &fakeClient{}
+			c.client = fc
+
+			c.onMessage(nil, fakeMessage{topic: tt.topic, payload: []byte(tt.payload)})
+
+			assertResponse(t, fc, tt.want)
+		})
+	}
+}
+
+func TestOnMessage_UnknownAttributeGetsNoResponse(t *testing.T) {
+	c, _ := New(newDefaultSvc(), Config{DeviceID: "room101"}, nil)
+	fc := &fakeClient{}
+	c.client = fc
+
+	c.onMessage(nil, fakeMessage{topic: "thermocktat/room101/set/colour", payload: []byte(`{"value":"red"}`)})
+
+	if len(fc.publishes) != 0 {
+		t.Fatalf("expected unknown attribute to be ignored, got %+v", fc.publishes)
+	}
+}
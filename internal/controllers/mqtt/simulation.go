@@ -0,0 +1,47 @@
+package mqttctrl
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/Agrid-Dev/thermocktat/internal/controllers/wire"
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Simulation topics:
+//   - <base>/get/simulation: publish the parameters to <base>/simulation
+//   - <base>/set/simulation: apply a partial update, then publish them
+
+type simulationPatchReq struct {
+	wire.SimulationPatch
+	RequestID json.RawMessage `json:"request_id"`
+}
+
+func decodeSimulationPatchStrict(b []byte) (thermostat.SimulationPatch, error) {
+	var req simulationPatchReq
+	if err := wire.Decode(bytes.NewReader(b), &req); err != nil {
+		return thermostat.SimulationPatch{}, err
+	}
+	return req.ToPatch()
+}
+
+func (c *Controller) simulationPayload() []byte {
+	b, _ := json.Marshal(wire.FromSimulation(c.svc.Simulation()))
+	return b
+}
+
+func (c *Controller) publishSimulation() {
+	c.publish(c.topic("simulation"), false, c.simulationPayload())
+}
+
+func (c *Controller) setSimulation(msg mqtt.Message) {
+	p, err := decodeSimulationPatchStrict(msg.Payload())
+	if err != nil {
+		c.respond(msg, "simulation", thermostat.CodeInvalidRequest, err)
+		return
+	}
+	err = c.svc.ApplySimulationPatch(p)
+	c.publishSimulation()
+	c.respond(msg, "simulation", thermostat.Code(err), err)
+}
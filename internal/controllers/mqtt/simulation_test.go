@@ -0,0 +1,104 @@
+package mqttctrl
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Agrid-Dev/thermocktat/internal/controllers/wire"
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+)
+
+func TestOnMessage_SetSimulation(t *testing.T) {
+	svc := newDefaultSvc()
+	c, _ := New(svc, Config{DeviceID: "room101"}, nil)
+	fc := &fakeClient{}
+	c.client = fc
+
+	c.onMessage(nil, fakeMessage{
+		topic:   "thermocktat/room101/set/simulation",
+		payload: []byte(`{"regulator":{"kd":0.2,"interval":"2s"},"heat_loss":{"outdoor_temperature_override":-5}}`),
+	})
+
+	p := svc.ApplySimulationPatchArg
+	if !svc.ApplySimulationPatchCalled || p.Kd == nil || *p.Kd != 0.2 {
+		t.Fatalf("unexpected simulation patch %+v", p)
+	}
+	if p.RegulationInterval == nil || *p.RegulationInterval != 2*time.Second {
+		t.Fatalf("unexpected interval %v", p.RegulationInterval)
+	}
+	if p.OutdoorTemperatureOverride == nil || *p.OutdoorTemperatureOverride != -5 {
+		t.Fatalf("unexpected override %v", p.OutdoorTemperatureOverride)
+	}
+	if svc.SetEnabledCalled || svc.ApplyPatchCalled {
+		t.Fatal("simulation topic must not touch the snapshot")
+	}
+
+	if len(fc.publishes) != 2 || fc.publishes[0].topic != "thermocktat/room101/simulation" {
+		t.Fatalf("expected a simulation publish, got %+v", fc.publishes)
+	}
+	assertResponse(t, fc, responseDTO{Topic: "thermocktat/room101/set/simulation", Field: "simulation", Status: statusAccepted})
+	var got wire.Simulation
+	if err := json.Unmarshal(fc.publishes[0].payload, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Regulator.Kd != 0.2 || got.Regulator.Interval != "2s" {
+		t.Fatalf("unexpected published simulation %+v", got)
+	}
+}
+
+func TestOnMessage_SetSimulation_NullClearsOverride(t *testing.T) {
+	svc := newDefaultSvc()
+	c, _ := New(svc, Config{DeviceID: "room101"}, nil)
+	c.client = &fakeClient{}
+
+	c.onMessage(nil, fakeMessage{
+		topic:   "thermocktat/room101/set/simulation",
+		payload: []byte(`{"heat_loss":{"outdoor_temperature_override":null}}`),
+	})
+
+	if !svc.ApplySimulationPatchArg.ClearOutdoorTemperatureOverride {
+		t.Fatalf("expected override cleared, got %+v", svc.ApplySimulationPatchArg)
+	}
+}
+
+func TestOnMessage_SetSimulationInvalid_DoesNotCallService(t *testing.T) {
+	for _, payload := range []string{
+		`{"regulator":{"kp":1,"extra":1}}`,
+		`{"regulator":{"interval":"later"}}`,
+		`{"heat_loss":{"outdoor_temperature_override":"hot"}}`,
+	} {
+		svc := newDefaultSvc()
+		c, _ := New(svc, Config{DeviceID: "room101"}, nil)
+		fc := &fakeClient{}
+		c.client = fc
+
+		c.onMessage(nil, fakeMessage{topic: "thermocktat/room101/set/simulation", payload: []byte(payload)})
+
+		if svc.ApplySimulationPatchCalled {
+			t.Fatalf("payload %s: expected ApplySimulationPatch not called", payload)
+		}
+		assertErrorPublished(t, fc, "thermocktat/room101/set/simulation", thermostat.CodeInvalidRequest)
+	}
+}
+
+func TestOnMessage_GetSimulation(t *testing.T) {
+	svc := newDefaultSvc()
+	svc.Sim.HeatLossCoefficient = 0.003
+	c, _ := New(svc, Config{DeviceID: "room101"}, nil)
+	fc := &fakeClient{}
+	c.client = fc
+
+	c.onMessage(nil, fakeMessage{topic: "thermocktat/room101/get/simulation"})
+
+	if len(fc.publishes) != 1 || fc.publishes[0].topic != "thermocktat/room101/simulation" {
+		t.Fatalf("expected a simulation publish, got %+v", fc.publishes)
+	}
+	var got wire.Simulation
+	if err := json.Unmarshal(fc.publishes[0].payload, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.HeatLoss.Coefficient != 0.003 || got.HeatLoss.OutdoorTemperatureOverride != nil {
+		t.Fatalf("unexpected published simulation %+v", got)
+	}
+}
@@ -0,0 +1,251 @@
+package mqttctrl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// With PayloadSparkplugB, thermocktat is a Sparkplug B edge node with one
+// device, in the spBv1.0/<group>/<message type>/<edge node>[/<device>]
+// namespace:
+//   - NBIRTH then DBIRTH with every metric on each connection, and again
+//     when a host sends the "Node Control/Rebirth" NCMD;
+//   - DDATA with the metrics that changed;
+//   - DCMD writes, applied atomically;
+//   - DDEATH then NDEATH on shutdown, NDEATH also being the will.
+//
+// Every message but NDEATH carries seq, which restarts at 0 with NBIRTH.
+// NBIRTH and NDEATH carry bdSeq, incremented on every reconnection, so
+// that hosts can match a death with the birth it ends.
+
+const (
+	sparkplugNamespace = "spBv1.0"
+	bdSeqMetric        = "bdSeq"
+	rebirthMetric      = "Node Control/Rebirth"
+)
+
+func (c *Controller) sparkplugTopic(messageType string) string {
+	t := sparkplugNamespace + "/" + c.cfg.SparkplugGroupID + "/" + messageType + "/" + c.cfg.SparkplugEdgeNodeID
+	if strings.HasPrefix(messageType, "D") {
+		t += "/" + c.cfg.DeviceID
+	}
+	return t
+}
+
+func validateSparkplug(cfg Config) error {
+	switch {
+	case cfg.ProtocolVersion != ProtocolV311:
+		return errors.New("mqtt: sparkplug_b requires ProtocolVersion 4 (MQTT 3.1.1)")
+	case cfg.HomeAssistant || cfg.AttributeTopics:
+		return errors.New("mqtt: sparkplug_b cannot be combined with HomeAssistant or AttributeTopics")
+	}
+	for _, id := range []string{cfg.SparkplugGroupID, cfg.SparkplugEdgeNodeID, cfg.DeviceID} {
+		if strings.ContainsAny(id, "/+#") {
+			return fmt.Errorf("mqtt: invalid sparkplug id %q: '/', '+' and '#' are not allowed", id)
+		}
+	}
+	return nil
+}
+
+func nowMillis() uint64 {
+	return uint64(time.Now().UnixMilli())
+}
+
+func ndeathPayload(bdSeq uint64) []byte {
+	return spPayload{
+		Timestamp: nowMillis(),
+		Metrics:   []spMetric{{Name: bdSeqMetric, Datatype: spInt64, Value: int64(bdSeq)}},
+	}.marshal()
+}
+
+// onSparkplugReconnecting numbers every reconnection with a new bdSeq,
+// updating the NDEATH will.
+func (c *Controller) onSparkplugReconnecting(_ mqtt.Client, opts *mqtt.ClientOptions) {
+	c.spMu.Lock()
+	c.bdSeq = (c.bdSeq + 1) % 256
+	c.born = false
+	c.spMu.Unlock()
+	opts.SetBinaryWill(c.will())
+}
+
+func (c *Controller) onConnectSparkplug(cl mqttClient) {
+	cl.Subscribe(c.sparkplugTopic("NCMD"), c.cfg.QoS, c.onSparkplugCommand).Wait()
+	cl.Subscribe(c.sparkplugTopic("DCMD"), c.cfg.QoS, c.onSparkplugCommand).Wait()
+	c.publishBirth()
+}
+
+// publishSpLocked publishes metrics with the next sequence number.
+// c.spMu must be held, so that messages go out in seq order.
+func (c *Controller) publishSpLocked(messageType string, metrics []spMetric) mqtt.Token {
+	seq := c.seq
+	c.seq = (c.seq + 1) % 256
+	p := spPayload{Timestamp: nowMillis(), Metrics: metrics, Seq: &seq}
+	// Sparkplug B publishes everything but NDEATH with QoS 0, not retained.
+	return c.client.Publish(c.sparkplugTopic(messageType), 0, false, p.marshal())
+}
+
+// publishBirth publishes NBIRTH and DBIRTH, restarting the sequence.
+func (c *Controller) publishBirth() {
+	dto := toSnapshotDTO(c.svc.Get(), c.cfg.DeviceID)
+	ts := nowMillis()
+
+	c.spMu.Lock()
+	defer c.spMu.Unlock()
+	c.seq = 0
+	c.publishSpLocked("NBIRTH", []spMetric{
+		{Name: bdSeqMetric, Timestamp: ts, Datatype: spInt64, Value: int64(c.bdSeq)},
+		{Name: rebirthMetric, Timestamp: ts, Datatype: spBoolean, Value: false},
+	})
+
+	c.pubMu.Lock()
+	record(c.published, dto.attributes())
+	c.pubMu.Unlock()
+	c.publishSpLocked("DBIRTH", spMetrics(dto.attributes(), ts))
+	c.born = true
+}
+
+// publishDeviceData publishes DDATA with the metrics changed since they
+// were last published, or all of them with PublishInterval.
+func (c *Controller) publishDeviceData() {
+	dto := toSnapshotDTO(c.svc.Get(), c.cfg.DeviceID)
+
+	c.spMu.Lock()
+	defer c.spMu.Unlock()
+	if !c.born {
+		// DBIRTH, due on connection, publishes every metric.
+		return
+	}
+	c.pubMu.Lock()
+	changed := dto.attributes()
+	if c.cfg.PublishMode != PublishInterval {
+		changed = changedAttributes(c.published, dto, c.cfg.Deadband)
+	}
+	record(c.published, changed)
+	c.pubMu.Unlock()
+	if len(changed) > 0 {
+		c.publishSpLocked("DDATA", spMetrics(changed, nowMillis()))
+	}
+}
+
+// publishDeath announces a clean shutdown: the broker does not publish the
+// will on a normal disconnect.
+func (c *Controller) publishDeath() mqtt.Token {
+	c.spMu.Lock()
+	defer c.spMu.Unlock()
+	if c.born {
+		c.publishSpLocked("DDEATH", nil)
+		c.born = false
+	}
+	return c.client.Publish(c.sparkplugTopic("NDEATH"), 1, false, ndeathPayload(c.bdSeq))
+}
+
+func spMetrics(attrs []attribute, ts uint64) []spMetric {
+	ms := make([]spMetric, 0, len(attrs))
+	for _, a := range attrs {
+		m := spMetric{Name: a.name, Timestamp: ts}
+		switch v := a.value.(type) {
+		case bool:
+			m.Datatype, m.Value = spBoolean, v
+		case float64:
+			m.Datatype, m.Value = spDouble, v
+		case string:
+			m.Datatype, m.Value = spString, v
+		case int:
+			m.Datatype, m.Value = spInt32, int64(v)
+		}
+		ms = append(ms, m)
+	}
+	return ms
+}
+
+func (c *Controller) onSparkplugCommand(_ mqtt.Client, msg mqtt.Message) {
+	c.log.Debug("mqtt message received", "topic", msg.Topic(), "payload_len", len(msg.Payload()))
+	p, err := unmarshalSpPayload(msg.Payload())
+	if err != nil {
+		c.log.Warn("invalid sparkplug payload", "topic", msg.Topic(), "err", err)
+		return
+	}
+
+	if msg.Topic() == c.sparkplugTopic("NCMD") {
+		for _, m := range p.Metrics {
+			if m.Name == rebirthMetric && m.Value == true {
+				c.publishBirth()
+			}
+		}
+		return
+	}
+
+	// Sparkplug B has no response to commands: rejected writes are only
+	// logged, and DDATA reports what was applied.
+	patch, err := spPatch(p.Metrics)
+	if err == nil {
+		err = c.svc.ApplyPatch(patch)
+	}
+	if err != nil {
+		c.log.Warn("sparkplug command rejected", "code", thermostat.Code(err), "err", err)
+	}
+	c.publishDeviceData()
+}
+
+// spPatch converts DCMD metrics into a patch. Metrics are addressed by
+// name, as no aliases are declared in DBIRTH.
+func spPatch(ms []spMetric) (thermostat.Patch, error) {
+	var p thermostat.Patch
+	for _, m := range ms {
+		switch m.Name {
+		case "enabled":
+			v, ok := m.Value.(bool)
+			if !ok {
+				return p, fmt.Errorf("%s: expected a boolean value", m.Name)
+			}
+			p.Enabled = &v
+
+		case "temperature_setpoint", "temperature_setpoint_min", "temperature_setpoint_max":
+			v, err := m.number()
+			if err != nil {
+				return p, err
+			}
+			switch m.Name {
+			case "temperature_setpoint":
+				p.TemperatureSetpoint = &v
+			case "temperature_setpoint_min":
+				p.TemperatureSetpointMin = &v
+			default:
+				p.TemperatureSetpointMax = &v
+			}
+
+		case "mode":
+			s, _ := m.Value.(string)
+			mode, err := thermostat.ParseMode(s)
+			if err != nil {
+				return p, err
+			}
+			p.Mode = &mode
+
+		case "fan_speed":
+			s, _ := m.Value.(string)
+			f, err := thermostat.ParseFanSpeed(s)
+			if err != nil {
+				return p, err
+			}
+			p.FanSpeed = &f
+
+		case "fault_code":
+			v, ok := m.Value.(int64)
+			if !ok {
+				return p, fmt.Errorf("%s: expected an integer value", m.Name)
+			}
+			fc := int(v)
+			p.FaultCode = &fc
+
+		default:
+			return p, fmt.Errorf("metric %q is unknown or read-only", m.Name)
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,236 @@
+package mqttctrl
+
+import (
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Sparkplug B payloads are encoded by hand with protowire: only the
+// Payload and Metric messages of sparkplug_b.proto are used, with scalar
+// values, which does not justify generated code.
+
+// Sparkplug B metric data types.
+const (
+	spInt8    uint32 = 1
+	spInt16   uint32 = 2
+	spInt32   uint32 = 3
+	spInt64   uint32 = 4
+	spUInt8   uint32 = 5
+	spUInt16  uint32 = 6
+	spUInt32  uint32 = 7
+	spUInt64  uint32 = 8
+	spFloat   uint32 = 9
+	spDouble  uint32 = 10
+	spBoolean uint32 = 11
+	spString  uint32 = 12
+)
+
+// Field numbers in sparkplug_b.proto.
+const (
+	payloadTimestamp protowire.Number = 1
+	payloadMetrics   protowire.Number = 2
+	payloadSeq       protowire.Number = 3
+
+	metricName         protowire.Number = 1
+	metricTimestamp    protowire.Number = 3
+	metricDatatype     protowire.Number = 4
+	metricIsNull       protowire.Number = 7
+	metricIntValue     protowire.Number = 10
+	metricLongValue    protowire.Number = 11
+	metricFloatValue   protowire.Number = 12
+	metricDoubleValue  protowire.Number = 13
+	metricBooleanValue protowire.Number = 14
+	metricStringValue  protowire.Number = 15
+)
+
+// spPayload is a Sparkplug B payload. Seq is nil in NDEATH, the only
+// message without a sequence number.
+type spPayload struct {
+	Timestamp uint64 // ms since the epoch
+	Metrics   []spMetric
+	Seq       *uint64
+}
+
+// spMetric holds its value as a bool, string, float64 (Float, Double) or
+// int64 (integer types).
+type spMetric struct {
+	Name      string
+	Timestamp uint64
+	Datatype  uint32
+	Value     any
+}
+
+func (p spPayload) marshal() []byte {
+	var b []byte
+	b = protowire.AppendTag(b, payloadTimestamp, protowire.VarintType)
+	b = protowire.AppendVarint(b, p.Timestamp)
+	for _, m := range p.Metrics {
+		b = protowire.AppendTag(b, payloadMetrics, protowire.BytesType)
+		b = protowire.AppendBytes(b, m.marshal())
+	}
+	if p.Seq != nil {
+		b = protowire.AppendTag(b, payloadSeq, protowire.VarintType)
+		b = protowire.AppendVarint(b, *p.Seq)
+	}
+	return b
+}
+
+func (m spMetric) marshal() []byte {
+	var b []byte
+	b = protowire.AppendTag(b, metricName, protowire.BytesType)
+	b = protowire.AppendString(b, m.Name)
+	if m.Timestamp != 0 {
+		b = protowire.AppendTag(b, metricTimestamp, protowire.VarintType)
+		b = protowire.AppendVarint(b, m.Timestamp)
+	}
+	b = protowire.AppendTag(b, metricDatatype, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(m.Datatype))
+
+	switch v := m.Value.(type) {
+	case bool:
+		b = protowire.AppendTag(b, metricBooleanValue, protowire.VarintType)
+		b = protowire.AppendVarint(b, protowire.EncodeBool(v))
+	case string:
+		b = protowire.AppendTag(b, metricStringValue, protowire.BytesType)
+		b = protowire.AppendString(b, v)
+	case float64:
+		if m.Datatype == spFloat {
+			b = protowire.AppendTag(b, metricFloatValue, protowire.Fixed32Type)
+			b = protowire.AppendFixed32(b, math.Float32bits(float32(v)))
+		} else {
+			b = protowire.AppendTag(b, metricDoubleValue, protowire.Fixed64Type)
+			b = protowire.AppendFixed64(b, math.Float64bits(v))
+		}
+	case int64:
+		if m.Datatype == spInt64 || m.Datatype == spUInt64 {
+			b = protowire.AppendTag(b, metricLongValue, protowire.VarintType)
+			b = protowire.AppendVarint(b, uint64(v))
+		} else {
+			// 32-bit and smaller types use int_value, a uint32 holding the
+			// two's complement of signed values.
+			b = protowire.AppendTag(b, metricIntValue, protowire.VarintType)
+			b = protowire.AppendVarint(b, uint64(uint32(v)))
+		}
+	case nil:
+		b = protowire.AppendTag(b, metricIsNull, protowire.VarintType)
+		b = protowire.AppendVarint(b, 1)
+	}
+	return b
+}
+
+// unmarshalSpPayload decodes a payload, skipping the fields thermocktat
+// does not use.
+func unmarshalSpPayload(b []byte) (spPayload, error) {
+	var p spPayload
+	err := consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == payloadTimestamp && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			p.Timestamp = v
+			return n, nil
+		case num == payloadSeq && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			p.Seq = &v
+			return n, nil
+		case num == payloadMetrics && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return n, nil
+			}
+			m, err := unmarshalSpMetric(v)
+			if err != nil {
+				return 0, err
+			}
+			p.Metrics = append(p.Metrics, m)
+			return n, nil
+		}
+		return protowire.ConsumeFieldValue(num, typ, b), nil
+	})
+	return p, err
+}
+
+func unmarshalSpMetric(b []byte) (spMetric, error) {
+	var m spMetric
+	var raw uint64 // int_value or long_value, typed once the datatype is known
+	var isInt, isLong bool
+	err := consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == metricName && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(b)
+			m.Name = v
+			return n, nil
+		case num == metricTimestamp && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			m.Timestamp = v
+			return n, nil
+		case num == metricDatatype && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			m.Datatype = uint32(v)
+			return n, nil
+		case (num == metricIntValue || num == metricLongValue) && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			raw, isInt, isLong = v, num == metricIntValue, num == metricLongValue
+			return n, nil
+		case num == metricFloatValue && typ == protowire.Fixed32Type:
+			v, n := protowire.ConsumeFixed32(b)
+			m.Value = float64(math.Float32frombits(v))
+			return n, nil
+		case num == metricDoubleValue && typ == protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			m.Value = math.Float64frombits(v)
+			return n, nil
+		case num == metricBooleanValue && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			m.Value = protowire.DecodeBool(v)
+			return n, nil
+		case num == metricStringValue && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(b)
+			m.Value = v
+			return n, nil
+		}
+		return protowire.ConsumeFieldValue(num, typ, b), nil
+	})
+	switch {
+	case isInt && (m.Datatype == spInt8 || m.Datatype == spInt16 || m.Datatype == spInt32):
+		m.Value = int64(int32(uint32(raw)))
+	case isInt:
+		m.Value = int64(uint32(raw))
+	case isLong:
+		m.Value = int64(raw)
+	}
+	return m, err
+}
+
+// consumeFields calls field for every field of a message; field returns
+// the length of the value it consumed, negative on error.
+func consumeFields(b []byte, field func(protowire.Number, protowire.Type, []byte) (int, error)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		n, err := field(num, typ, b)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return fmt.Errorf("field %d: %w", num, protowire.ParseError(n))
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// number returns the value of a numeric metric as a float64.
+func (m spMetric) number() (float64, error) {
+	switch v := m.Value.(type) {
+	case float64:
+		return v, nil
+	case int64:
+		return float64(v), nil
+	}
+	return 0, fmt.Errorf("%s: expected a numeric value", m.Name)
+}
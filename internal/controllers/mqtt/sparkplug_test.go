@@ -0,0 +1,252 @@
+package mqttctrl
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Agrid-Dev/thermocktat/internal/testutil"
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+)
+
+const (
+	spNodeTopic   = "spBv1.0/thermocktat/%s/thermocktat-room101"
+	spDeviceTopic = spNodeTopic + "/room101"
+)
+
+func spTopic(format, messageType string) string {
+	return fmt.Sprintf(format, messageType)
+}
+
+func newSparkplugController(t *testing.T) (*Controller, *testutil.FakeThermostatService, *fakeClient) {
+	t.Helper()
+	svc := newDefaultSvc()
+	c, err := New(svc, Config{DeviceID: "room101", PayloadFormat: PayloadSparkplugB}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc := &fakeClient{}
+	c.client = fc
+	return c, svc, fc
+}
+
+// decodePublish decodes the i-th publish, checking its topic.
+func decodePublish(t *testing.T, fc *fakeClient, i int, topic string) spPayload {
+	t.Helper()
+	if i >= len(fc.publishes) {
+		t.Fatalf("expected a publish on %s, got %d publishes", topic, len(fc.publishes))
+	}
+	pc := fc.publishes[i]
+	if pc.topic != topic {
+		t.Fatalf("publish %d on %s, want %s", i, pc.topic, topic)
+	}
+	p, err := unmarshalSpPayload(pc.payload)
+	if err != nil {
+		t.Fatalf("%s: %v", topic, err)
+	}
+	return p
+}
+
+func metric(p spPayload, name string) (spMetric, bool) {
+	for _, m := range p.Metrics {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return spMetric{}, false
+}
+
+func assertSeq(t *testing.T, p spPayload, want uint64) {
+	t.Helper()
+	if p.Seq == nil || *p.Seq != want {
+		t.Fatalf("seq = %v, want %d", p.Seq, want)
+	}
+}
+
+func TestSpPayload_RoundTrip(t *testing.T) {
+	seq := uint64(255)
+	in := spPayload{
+		Timestamp: 1700000000000,
+		Seq:       &seq,
+		Metrics: []spMetric{
+			{Name: "b", Datatype: spBoolean, Value: true},
+			{Name: "d", Datatype: spDouble, Value: 21.5},
+			{Name: "f", Datatype: spFloat, Value: 0.5},
+			{Name: "s", Datatype: spString, Value: "heat"},
+			{Name: "i32", Datatype: spInt32, Value: int64(-3)},
+			{Name: "u32", Datatype: spUInt32, Value: int64(4000000000)},
+			{Name: "i64", Datatype: spInt64, Value: int64(-1 << 40), Timestamp: 42},
+		},
+	}
+	out, err := unmarshalSpPayload(in.marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Timestamp != in.Timestamp || out.Seq == nil || *out.Seq != seq || len(out.Metrics) != len(in.Metrics) {
+		t.Fatalf("round trip: got %+v", out)
+	}
+	for i, m := range in.Metrics {
+		if out.Metrics[i] != m {
+			t.Errorf("metric %d: got %+v, want %+v", i, out.Metrics[i], m)
+		}
+	}
+	if _, err := unmarshalSpPayload([]byte{0x12, 0x05, 0x0a}); err == nil {
+		t.Fatal("expected error for a truncated payload")
+	}
+}
+
+func TestSparkplug_Lifecycle(t *testing.T) {
+	c, svc, fc := newSparkplugController(t)
+
+	c.onConnect(fc)
+	nbirth := decodePublish(t, fc, 0, spTopic(spNodeTopic, "NBIRTH"))
+	assertSeq(t, nbirth, 0)
+	if m, ok := metric(nbirth, bdSeqMetric); !ok || m.Value != int64(0) || m.Datatype != spInt64 {
+		t.Fatalf("NBIRTH bdSeq = %+v", m)
+	}
+	if _, ok := metric(nbirth, rebirthMetric); !ok {
+		t.Fatal("NBIRTH without the rebirth metric")
+	}
+	dbirth := decodePublish(t, fc, 1, spTopic(spDeviceTopic, "DBIRTH"))
+	assertSeq(t, dbirth, 1)
+	if len(dbirth.Metrics) != 8 {
+		t.Fatalf("expected 8 metrics in DBIRTH, got %+v", dbirth.Metrics)
+	}
+	if m, _ := metric(dbirth, "fault_code"); m.Datatype != spInt32 {
+		t.Fatalf("fault_code datatype = %d", m.Datatype)
+	}
+	if m, _ := metric(dbirth, "mode"); m.Value != "auto" || m.Datatype != spString {
+		t.Fatalf("mode metric = %+v", m)
+	}
+	for _, pc := range fc.publishes {
+		if pc.qos != 0 || pc.retain {
+			t.Fatalf("%s published with QoS %d, retain %v", pc.topic, pc.qos, pc.retain)
+		}
+	}
+
+	// Nothing changed: no DDATA.
+	c.publishIfChanged()
+	if len(fc.publishes) != 2 {
+		t.Fatalf("expected no DDATA while unchanged, got %d publishes", len(fc.publishes))
+	}
+
+	svc.S.FaultCode = 7
+	c.publishIfChanged()
+	ddata := decodePublish(t, fc, 2, spTopic(spDeviceTopic, "DDATA"))
+	assertSeq(t, ddata, 2)
+	if len(ddata.Metrics) != 1 || ddata.Metrics[0].Name != "fault_code" || ddata.Metrics[0].Value != int64(7) {
+		t.Fatalf("DDATA metrics = %+v", ddata.Metrics)
+	}
+
+	c.publishDeath()
+	ddeath := decodePublish(t, fc, 3, spTopic(spDeviceTopic, "DDEATH"))
+	assertSeq(t, ddeath, 3)
+	ndeath := decodePublish(t, fc, 4, spTopic(spNodeTopic, "NDEATH"))
+	if ndeath.Seq != nil {
+		t.Fatal("NDEATH must not carry seq")
+	}
+	if m, _ := metric(ndeath, bdSeqMetric); m.Value != int64(0) {
+		t.Fatalf("NDEATH bdSeq = %+v", m)
+	}
+	if fc.publishes[4].qos != 1 {
+		t.Fatal("NDEATH must be published with QoS 1")
+	}
+}
+
+func TestSparkplug_DCMD(t *testing.T) {
+	c, svc, fc := newSparkplugController(t)
+	c.onConnect(fc)
+
+	cmd := spPayload{Metrics: []spMetric{
+		{Name: "temperature_setpoint", Datatype: spFloat, Value: 23.5},
+		{Name: "mode", Datatype: spString, Value: "heat"},
+		{Name: "enabled", Datatype: spBoolean, Value: false},
+	}}
+	c.onSparkplugCommand(nil, fakeMessage{topic: spTopic(spDeviceTopic, "DCMD"), payload: cmd.marshal()})
+
+	p := svc.ApplyPatchArg
+	if !svc.ApplyPatchCalled || *p.TemperatureSetpoint != 23.5 || *p.Mode != thermostat.ModeHeat || *p.Enabled {
+		t.Fatalf("unexpected patch %+v", p)
+	}
+	ddata := decodePublish(t, fc, 2, spTopic(spDeviceTopic, "DDATA"))
+	if len(ddata.Metrics) != 3 {
+		t.Fatalf("expected the 3 written metrics in DDATA, got %+v", ddata.Metrics)
+	}
+}
+
+func TestSparkplug_DCMDRejected(t *testing.T) {
+	tests := map[string]spMetric{
+		"read-only metric": {Name: "ambient_temperature", Datatype: spDouble, Value: 30.0},
+		"unknown metric":   {Name: "humidity", Datatype: spDouble, Value: 30.0},
+		"wrong type":       {Name: "enabled", Datatype: spString, Value: "true"},
+		"invalid mode":     {Name: "mode", Datatype: spString, Value: "turbo"},
+	}
+	for name, m := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, svc, fc := newSparkplugController(t)
+			c.onConnect(fc)
+			cmd := spPayload{Metrics: []spMetric{{Name: "fault_code", Datatype: spInt32, Value: int64(3)}, m}}
+			c.onSparkplugCommand(nil, fakeMessage{topic: spTopic(spDeviceTopic, "DCMD"), payload: cmd.marshal()})
+			if svc.ApplyPatchCalled {
+				t.Fatal("expected the whole command to be rejected")
+			}
+			if len(fc.publishes) != 2 {
+				t.Fatalf("expected no DDATA, got %d publishes", len(fc.publishes))
+			}
+		})
+	}
+}
+
+func TestSparkplug_Rebirth(t *testing.T) {
+	c, svc, fc := newSparkplugController(t)
+	c.onConnect(fc)
+	svc.S.FaultCode = 1
+	c.publishSnapshot()
+
+	cmd := spPayload{Metrics: []spMetric{{Name: rebirthMetric, Datatype: spBoolean, Value: true}}}
+	c.onSparkplugCommand(nil, fakeMessage{topic: spTopic(spNodeTopic, "NCMD"), payload: cmd.marshal()})
+
+	assertSeq(t, decodePublish(t, fc, 3, spTopic(spNodeTopic, "NBIRTH")), 0)
+	assertSeq(t, decodePublish(t, fc, 4, spTopic(spDeviceTopic, "DBIRTH")), 1)
+}
+
+func TestSparkplug_WillNumbersReconnections(t *testing.T) {
+	c, _, _ := newSparkplugController(t)
+	opts := c.clientOptions()
+	if opts.WillTopic != spTopic(spNodeTopic, "NDEATH") || opts.WillQos != 1 || opts.WillRetained {
+		t.Fatalf("unexpected will %s (QoS %d, retained %v)", opts.WillTopic, opts.WillQos, opts.WillRetained)
+	}
+
+	opts.OnReconnecting(nil, opts)
+	will, err := unmarshalSpPayload(opts.WillPayload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, _ := metric(will, bdSeqMetric); m.Value != int64(1) {
+		t.Fatalf("will bdSeq after a reconnection = %+v", m)
+	}
+
+	fc := &fakeClient{}
+	c.client = fc
+	c.onConnect(fc)
+	if m, _ := metric(decodePublish(t, fc, 0, spTopic(spNodeTopic, "NBIRTH")), bdSeqMetric); m.Value != int64(1) {
+		t.Fatalf("NBIRTH bdSeq = %+v, want the will's", m)
+	}
+}
+
+func TestNewValidation_Sparkplug(t *testing.T) {
+	tests := map[string]Config{
+		"mqtt 5":         {ProtocolVersion: ProtocolV5},
+		"home assistant": {HomeAssistant: true},
+		"wildcard group": {SparkplugGroupID: "site/a"},
+	}
+	for name, cfg := range tests {
+		cfg.DeviceID = "x"
+		cfg.PayloadFormat = PayloadSparkplugB
+		if _, err := New(newDefaultSvc(), cfg, nil); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if _, err := New(newDefaultSvc(), Config{DeviceID: "x", PayloadFormat: "xml"}, nil); err == nil {
+		t.Error("expected error for an unknown payload format")
+	}
+}
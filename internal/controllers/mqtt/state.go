@@ -0,0 +1,140 @@
+package mqttctrl
+
+import (
+	"encoding/json"
+	"math"
+
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// attribute is one snapshot field, published to <base>/state/<name> when
+// AttributeTopics is set.
+type attribute struct {
+	name  string
+	value any
+}
+
+func toSnapshotDTO(s thermostat.Snapshot, deviceID string) snapshotDTO {
+	return snapshotDTO{
+		Enabled:                s.Enabled,
+		TemperatureSetpoint:    s.TemperatureSetpoint,
+		TemperatureSetpointMin: s.TemperatureSetpointMin,
+		TemperatureSetpointMax: s.TemperatureSetpointMax,
+		Mode:                   s.Mode.String(),
+		FanSpeed:               s.FanSpeed.String(),
+		AmbientTemperature:     s.AmbientTemperature,
+		FaultCode:              s.FaultCode,
+		DeviceId:               deviceID,
+	}
+}
+
+// attributes lists the snapshot fields in payload order, without device_id.
+func (d snapshotDTO) attributes() []attribute {
+	return []attribute{
+		{"enabled", d.Enabled},
+		{"temperature_setpoint", d.TemperatureSetpoint},
+		{"temperature_setpoint_min", d.TemperatureSetpointMin},
+		{"temperature_setpoint_max", d.TemperatureSetpointMax},
+		{"mode", d.Mode},
+		{"fan_speed", d.FanSpeed},
+		{"ambient_temperature", d.AmbientTemperature},
+		{"fault_code", d.FaultCode},
+	}
+}
+
+// changedAttributes returns the attributes of cur that differ from last.
+// ambient_temperature, the only measured value, counts as changed once it
+// moved by at least deadband from its last published value; written
+// attributes always count.
+func changedAttributes(last map[string]any, cur snapshotDTO, deadband float64) []attribute {
+	var changed []attribute
+	for _, a := range cur.attributes() {
+		prev, ok := last[a.name]
+		switch {
+		case !ok:
+		case a.name == "ambient_temperature":
+			if a.value == prev || math.Abs(a.value.(float64)-prev.(float64)) < deadband {
+				continue
+			}
+		case a.value == prev:
+			continue
+		}
+		changed = append(changed, a)
+	}
+	return changed
+}
+
+func record(last map[string]any, attrs []attribute) {
+	for _, a := range attrs {
+		last[a.name] = a.value
+	}
+}
+
+// publishSnapshot publishes the current snapshot, and the attributes that
+// changed since they were last published to their own topics.
+// With Sparkplug B and Homie, DDATA and property values replace both.
+func (c *Controller) publishSnapshot() {
+	switch c.cfg.PayloadFormat {
+	case PayloadSparkplugB:
+		c.publishDeviceData()
+		return
+	case PayloadHomie:
+		c.publishHomieValues(c.cfg.PublishMode == PublishInterval)
+		return
+	}
+	s := c.svc.Get()
+	dto := toSnapshotDTO(s, c.cfg.DeviceID)
+
+	c.pubMu.Lock()
+	record(c.published, dto.attributes())
+	var changed []attribute
+	if c.cfg.AttributeTopics {
+		changed = changedAttributes(c.publishedAttrs, dto, c.cfg.Deadband)
+		record(c.publishedAttrs, changed)
+	}
+	c.pubMu.Unlock()
+
+	topic, b := c.snapshotPayload(dto)
+	version := versionProperty(s.Version)
+	c.publish(topic, c.cfg.RetainSnapshot, b, version)
+	for _, a := range changed {
+		b, _ := json.Marshal(a.value)
+		c.publish(c.topic("state/"+a.name), c.cfg.RetainSnapshot, b, version)
+	}
+
+	if c.cfg.HomeAssistant {
+		c.refreshDiscovery(s)
+	}
+}
+
+// replySnapshot answers an MQTT 5 get/snapshot request on its Response
+// Topic, and reports false if it has none.
+func (c *Controller) replySnapshot(msg mqtt.Message) bool {
+	s := c.svc.Get()
+	_, b := c.snapshotPayload(toSnapshotDTO(s, c.cfg.DeviceID))
+	return c.reply(msg, b, versionProperty(s.Version))
+}
+
+// snapshotPayload returns the snapshot topic and payload, in the template
+// schema with PayloadTemplate.
+func (c *Controller) snapshotPayload(dto snapshotDTO) (string, []byte) {
+	if c.cfg.PayloadFormat == PayloadTemplate {
+		b, _ := json.Marshal(c.cfg.Template.render(dto))
+		return c.cfg.Template.SnapshotTopic, b
+	}
+	b, _ := json.Marshal(dto)
+	return c.topic("snapshot"), b
+}
+
+// publishIfChanged publishes the snapshot when it differs from the last one
+// published, whichever controller or the regulation loop changed it.
+func (c *Controller) publishIfChanged() {
+	dto := toSnapshotDTO(c.svc.Get(), c.cfg.DeviceID)
+	c.pubMu.Lock()
+	changed := changedAttributes(c.published, dto, c.cfg.Deadband)
+	c.pubMu.Unlock()
+	if len(changed) > 0 {
+		c.publishSnapshot()
+	}
+}
@@ -0,0 +1,103 @@
+package mqttctrl
+
+import "testing"
+
+func TestChangedAttributes(t *testing.T) {
+	base := snapshotDTO{Mode: "auto", FanSpeed: "auto", TemperatureSetpoint: 21, AmbientTemperature: 20}
+	last := make(map[string]any)
+	record(last, base.attributes())
+
+	if got := changedAttributes(map[string]any{}, base, 0); len(got) != 8 {
+		t.Fatalf("expected every attribute on first publish, got %v", got)
+	}
+	if got := changedAttributes(last, base, 0); len(got) != 0 {
+		t.Fatalf("expected no change, got %v", got)
+	}
+
+	cur := base
+	cur.AmbientTemperature = 20.2
+	if got := changedAttributes(last, cur, 0.5); len(got) != 0 {
+		t.Fatalf("expected ambient within deadband to be ignored, got %v", got)
+	}
+	if got := changedAttributes(last, cur, 0); len(got) != 1 || got[0].name != "ambient_temperature" {
+		t.Fatalf("expected ambient change without deadband, got %v", got)
+	}
+	cur.AmbientTemperature = 19.5
+	if got := changedAttributes(last, cur, 0.5); len(got) != 1 {
+		t.Fatalf("expected ambient change at the deadband, got %v", got)
+	}
+
+	// The deadband only filters the measured value.
+	cur = base
+	cur.TemperatureSetpoint = 21.1
+	if got := changedAttributes(last, cur, 0.5); len(got) != 1 || got[0].name != "temperature_setpoint" {
+		t.Fatalf("expected setpoint change, got %v", got)
+	}
+}
+
+func TestPublishIfChanged_DetectsExternalChanges(t *testing.T) {
+	svc := newDefaultSvc()
+	svc.S.AmbientTemperature = 20
+	c, _ := New(svc, Config{DeviceID: "room101", Deadband: 0.5}, nil)
+	fc := &fakeClient{}
+	c.client = fc
+	c.publishSnapshot()
+
+	c.publishIfChanged()
+	if _, n := findPublish(fc, "thermocktat/room101/snapshot"); n != 1 {
+		t.Fatalf("expected no publish while unchanged, got %d snapshots", n)
+	}
+
+	// Slow drift from the regulation loop, published once it adds up.
+	for _, v := range []float64{20.2, 20.4, 20.6} {
+		svc.S.AmbientTemperature = v
+		c.publishIfChanged()
+	}
+	if _, n := findPublish(fc, "thermocktat/room101/snapshot"); n != 2 {
+		t.Fatalf("expected drift past the deadband to be published once, got %d snapshots", n)
+	}
+
+	// A write made through another controller.
+	svc.S.FanSpeed++
+	c.publishIfChanged()
+	if _, n := findPublish(fc, "thermocktat/room101/snapshot"); n != 3 {
+		t.Fatalf("expected external write to be published, got %d snapshots", n)
+	}
+}
+
+func TestPublishSnapshot_AttributeTopics(t *testing.T) {
+	svc := newDefaultSvc()
+	svc.S.AmbientTemperature = 20
+	c, _ := New(svc, Config{DeviceID: "room101", AttributeTopics: true, Deadband: 0.5, RetainSnapshot: true}, nil)
+	fc := &fakeClient{}
+	c.client = fc
+
+	c.publishSnapshot()
+	p, n := findPublish(fc, "thermocktat/room101/state/mode")
+	if n != 1 || string(p.payload) != `"auto"` || !p.retain {
+		t.Fatalf("expected retained mode state, got %+v (%d)", p, n)
+	}
+	if p, _ := findPublish(fc, "thermocktat/room101/state/ambient_temperature"); string(p.payload) != "20" {
+		t.Fatalf("ambient_temperature payload = %s", p.payload)
+	}
+
+	fc.publishes = nil
+	svc.S.AmbientTemperature = 20.3
+	svc.S.TemperatureSetpoint = 23
+	c.publishSnapshot()
+	if _, n := findPublish(fc, "thermocktat/room101/state/ambient_temperature"); n != 0 {
+		t.Fatal("ambient_temperature published within the deadband")
+	}
+	if p, n := findPublish(fc, "thermocktat/room101/state/temperature_setpoint"); n != 1 || string(p.payload) != "23" {
+		t.Fatalf("expected setpoint state 23, got %+v (%d)", p, n)
+	}
+	if _, n := findPublish(fc, "thermocktat/room101/state/mode"); n != 0 {
+		t.Fatal("unchanged mode published again")
+	}
+}
+
+func TestNewValidation_Deadband(t *testing.T) {
+	if _, err := New(newDefaultSvc(), Config{DeviceID: "x", Deadband: -1}, nil); err == nil {
+		t.Fatal("expected error for negative deadband")
+	}
+}
@@ -0,0 +1,341 @@
+package mqttctrl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Agrid-Dev/thermocktat/internal/controllers/wire"
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// With PayloadTemplate, the snapshot and the commands follow the schema of
+// Config.Template instead of snapshotDTO and {"value": ...}, to mimic the
+// payloads of a vendor's thermostat, e.g. Zigbee2MQTT's:
+//
+//	{"local_temperature": 20.8, "occupied_heating_setpoint": 21.5, "system_mode": "heat"}
+//
+// Commands are partial payloads in the same schema, applied atomically.
+// Every other topic (availability, responses, get/...) is unchanged.
+
+// Template maps the snapshot attributes to a vendor payload schema.
+type Template struct {
+	// SnapshotTopic is where the payload is published, <base>/snapshot by
+	// default. It may be BaseTopic itself, as with Zigbee2MQTT.
+	SnapshotTopic string
+	// CommandTopic takes partial payloads, <base>/set by default, and
+	// <CommandTopic>/<path> the bare value of one field.
+	CommandTopic string
+	Fields       []TemplateField
+}
+
+// TemplateField publishes an attribute at Path. Attributes without a field
+// are not published, and several fields may publish the same attribute.
+type TemplateField struct {
+	// Attribute is a snapshot attribute, e.g. "temperature_setpoint", or
+	// "device_id". ambient_temperature and device_id are read-only.
+	Attribute string
+	// Path is the dot-separated location in the payload, e.g.
+	// "occupied_heating_setpoint" or "state.setpoint".
+	Path string
+	// Unit is the temperature unit, UnitCelsius (default) or
+	// UnitFahrenheit; Scale multiplies temperatures, e.g. 100 for
+	// hundredths of a degree (1 when zero).
+	Unit  string
+	Scale float64
+	// Values maps attribute values to vendor values, e.g. "fan" to
+	// "fan_only"; unmapped values are published as is. The keys of
+	// enabled are "true" and "false".
+	Values map[string]any
+	// Off, if set, is published instead of the value while the thermostat
+	// is disabled. Writing it disables the thermostat, and writing any
+	// other value enables it, as Zigbee2MQTT's system_mode "off".
+	Off any
+}
+
+const (
+	UnitCelsius    string = "°C"
+	UnitFahrenheit string = "°F"
+)
+
+func isTemperature(attr string) bool {
+	switch attr {
+	case "temperature_setpoint", "temperature_setpoint_min", "temperature_setpoint_max", "ambient_temperature":
+		return true
+	}
+	return false
+}
+
+func isReadOnly(attr string) bool {
+	return attr == "ambient_temperature" || attr == "device_id"
+}
+
+// validateTemplate checks cfg.Template, whose topics have their defaults.
+func validateTemplate(cfg Config) error {
+	tpl := cfg.Template
+	switch {
+	case len(tpl.Fields) == 0:
+		return errors.New("mqtt: template requires at least one field")
+	case cfg.HomeAssistant:
+		return errors.New("mqtt: template cannot be combined with HomeAssistant, whose discovery reads the native snapshot")
+	case tpl.SnapshotTopic == tpl.CommandTopic:
+		return fmt.Errorf("mqtt: template snapshot and command topics are both %q", tpl.SnapshotTopic)
+	}
+
+	known := map[string]bool{"device_id": true}
+	for _, a := range (snapshotDTO{}).attributes() {
+		known[a.name] = true
+	}
+	for i, f := range tpl.Fields {
+		switch {
+		case !known[f.Attribute]:
+			return fmt.Errorf("mqtt: template field %d: unknown attribute %q", i, f.Attribute)
+		case f.Path == "" || strings.HasPrefix(f.Path, ".") || strings.HasSuffix(f.Path, ".") || strings.Contains(f.Path, ".."):
+			return fmt.Errorf("mqtt: template field %d: invalid path %q", i, f.Path)
+		case f.Unit != "" && f.Unit != UnitCelsius && f.Unit != UnitFahrenheit:
+			return fmt.Errorf("mqtt: template field %q: invalid unit %q (expected %s or %s)", f.Path, f.Unit, UnitCelsius, UnitFahrenheit)
+		case (f.Unit != "" || f.Scale != 0) && !isTemperature(f.Attribute):
+			return fmt.Errorf("mqtt: template field %q: unit and scale only apply to temperatures", f.Path)
+		case f.Scale < 0:
+			return fmt.Errorf("mqtt: template field %q: scale must not be negative", f.Path)
+		case len(f.Values) > 0 && isTemperature(f.Attribute):
+			return fmt.Errorf("mqtt: template field %q: values do not apply to temperatures", f.Path)
+		case f.Off != nil && f.Attribute == "enabled":
+			return fmt.Errorf("mqtt: template field %q: off does not apply to enabled", f.Path)
+		}
+		vendor := make(map[string]string, len(f.Values))
+		for k, v := range f.Values {
+			if prev, ok := vendor[fmt.Sprint(v)]; ok {
+				return fmt.Errorf("mqtt: template field %q: %q and %q both map to %v", f.Path, prev, k, v)
+			}
+			vendor[fmt.Sprint(v)] = k
+		}
+		for _, g := range tpl.Fields[:i] {
+			if g.Path == f.Path || strings.HasPrefix(f.Path, g.Path+".") || strings.HasPrefix(g.Path, f.Path+".") {
+				return fmt.Errorf("mqtt: template paths %q and %q overlap", g.Path, f.Path)
+			}
+		}
+	}
+	return nil
+}
+
+// field returns the field at path, or nil.
+func (t Template) field(path string) *TemplateField {
+	for i := range t.Fields {
+		if t.Fields[i].Path == path {
+			return &t.Fields[i]
+		}
+	}
+	return nil
+}
+
+// render returns the payload of a snapshot.
+func (t Template) render(dto snapshotDTO) map[string]any {
+	values := map[string]any{"device_id": dto.DeviceId}
+	for _, a := range dto.attributes() {
+		values[a.name] = a.value
+	}
+
+	payload := make(map[string]any)
+	for _, f := range t.Fields {
+		// Walk down the path, creating the enclosing objects.
+		obj := payload
+		keys := strings.Split(f.Path, ".")
+		for _, k := range keys[:len(keys)-1] {
+			next, ok := obj[k].(map[string]any)
+			if !ok {
+				next = make(map[string]any)
+				obj[k] = next
+			}
+			obj = next
+		}
+		obj[keys[len(keys)-1]] = f.encode(values[f.Attribute], dto.Enabled)
+	}
+	return payload
+}
+
+func (f TemplateField) scale() float64 {
+	if f.Scale == 0 {
+		return 1
+	}
+	return f.Scale
+}
+
+// encode converts an attribute value to the vendor's.
+func (f TemplateField) encode(v any, enabled bool) any {
+	if f.Off != nil && !enabled {
+		return f.Off
+	}
+	if x, ok := v.(float64); ok {
+		if f.Unit == UnitFahrenheit {
+			x = x*9/5 + 32
+		}
+		return roundConverted(x * f.scale())
+	}
+	if m, ok := f.Values[fmt.Sprint(v)]; ok {
+		return m
+	}
+	return v
+}
+
+// roundConverted drops the floating point noise of unit conversions, e.g.
+// 71.60000000000001 °F.
+func roundConverted(x float64) float64 {
+	return math.Round(x*1e6) / 1e6
+}
+
+// sameValue compares values decoded from YAML and JSON, whose numbers
+// differ in type.
+func sameValue(a, b any) bool {
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+// decode sets the attribute of f in p from the vendor value v, in the native
+// payload's units and names.
+func (f TemplateField) decode(v any, p *wire.Patch) error {
+	if isReadOnly(f.Attribute) {
+		return fmt.Errorf("field %q is read-only", f.Path)
+	}
+	if f.Off != nil {
+		enabled := !sameValue(v, f.Off)
+		p.Enabled = &enabled
+		if !enabled {
+			return nil
+		}
+	}
+	for k, m := range f.Values {
+		if sameValue(v, m) {
+			v = k
+			break
+		}
+	}
+
+	switch f.Attribute {
+	case "enabled":
+		b, ok := v.(bool)
+		if s, isString := v.(string); isString {
+			var err error
+			b, err = strconv.ParseBool(s)
+			ok = err == nil
+		}
+		if !ok {
+			return fmt.Errorf("field %q: invalid value %v", f.Path, v)
+		}
+		p.Enabled = &b
+
+	case "temperature_setpoint", "temperature_setpoint_min", "temperature_setpoint_max":
+		x, ok := v.(float64)
+		if !ok {
+			return fmt.Errorf("field %q: expected a number, got %v", f.Path, v)
+		}
+		x /= f.scale()
+		if f.Unit == UnitFahrenheit {
+			x = (x - 32) * 5 / 9
+		}
+		x = roundConverted(x)
+		switch f.Attribute {
+		case "temperature_setpoint":
+			p.TemperatureSetpoint = &x
+		case "temperature_setpoint_min":
+			p.TemperatureSetpointMin = &x
+		default:
+			p.TemperatureSetpointMax = &x
+		}
+
+	case "mode":
+		m := fmt.Sprint(v)
+		p.Mode = &m
+
+	case "fan_speed":
+		s := fmt.Sprint(v)
+		p.FanSpeed = &s
+
+	case "fault_code":
+		n, err := strconv.Atoi(fmt.Sprint(v))
+		if err != nil {
+			return fmt.Errorf("field %q: expected an integer, got %v", f.Path, v)
+		}
+		p.FaultCode = &n
+	}
+	return nil
+}
+
+// patch converts the values of a command, by path, into a patch. Fields are
+// decoded in their declared order, so when several of them set the same
+// attribute the last one declared wins, whatever the order of the payload.
+func (t Template) patch(values map[string]any) (thermostat.Patch, error) {
+	for _, path := range slices.Sorted(maps.Keys(values)) {
+		if t.field(path) == nil {
+			return thermostat.Patch{}, fmt.Errorf("unknown field %q", path)
+		}
+	}
+	var p wire.Patch
+	for _, f := range t.Fields {
+		v, ok := values[f.Path]
+		if !ok {
+			continue
+		}
+		if err := f.decode(v, &p); err != nil {
+			return thermostat.Patch{}, err
+		}
+	}
+	return p.ToPatch()
+}
+
+// flatten collects the leaf values of a JSON object by dot-separated path.
+func flatten(prefix string, obj map[string]any, out map[string]any) {
+	for k, v := range obj {
+		if nested, ok := v.(map[string]any); ok {
+			flatten(prefix+k+".", nested, out)
+			continue
+		}
+		out[prefix+k] = v
+	}
+}
+
+// onTemplateCommand applies a command on the template's command topics, and
+// reports false for any other topic.
+func (c *Controller) onTemplateCommand(msg mqtt.Message) bool {
+	tpl := c.cfg.Template
+	values := make(map[string]any)
+	var field string
+	path, isField := strings.CutPrefix(msg.Topic(), tpl.CommandTopic+"/")
+	switch {
+	case msg.Topic() == tpl.CommandTopic:
+		var obj map[string]any
+		if err := json.Unmarshal(msg.Payload(), &obj); err != nil {
+			c.respond(msg, "", thermostat.CodeInvalidRequest, err)
+			return true
+		}
+		delete(obj, "request_id")
+		flatten("", obj, values)
+
+	case isField && tpl.field(path) != nil:
+		// The bare value: JSON, or a plain string such as heat.
+		var v any
+		if err := json.Unmarshal(msg.Payload(), &v); err != nil {
+			v = string(msg.Payload())
+		}
+		values[path] = v
+		field = path
+
+	default:
+		return false
+	}
+
+	p, err := tpl.patch(values)
+	if err != nil {
+		c.respond(msg, field, requestErrorCode(err), err)
+		return true
+	}
+	err = c.svc.ApplyPatch(p)
+	c.publishSnapshot()
+	c.respond(msg, field, thermostat.Code(err), err)
+	return true
+}
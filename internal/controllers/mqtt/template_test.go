@@ -0,0 +1,221 @@
+package mqttctrl
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/Agrid-Dev/thermocktat/internal/testutil"
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+)
+
+// zigbee2mqttTemplate mimics a Zigbee2MQTT thermostat, with a nested
+// Fahrenheit setpoint and a fault code in the vendor's numbering.
+var zigbee2mqttTemplate = Template{
+	SnapshotTopic: "zigbee2mqtt/room101",
+	CommandTopic:  "zigbee2mqtt/room101/set",
+	Fields: []TemplateField{
+		{Attribute: "temperature_setpoint", Path: "occupied_heating_setpoint"},
+		{Attribute: "ambient_temperature", Path: "local_temperature"},
+		{Attribute: "mode", Path: "system_mode", Values: map[string]any{"fan": "fan_only"}, Off: "off"},
+		{Attribute: "fan_speed", Path: "fan_mode"},
+		{Attribute: "temperature_setpoint", Path: "display.setpoint_f", Unit: UnitFahrenheit},
+		{Attribute: "temperature_setpoint_min", Path: "min_heat_setpoint_limit", Scale: 100},
+		{Attribute: "fault_code", Path: "error", Values: map[string]any{"0": "none"}},
+		{Attribute: "device_id", Path: "device.friendly_name"},
+	},
+}
+
+func newTemplateController(t *testing.T) (*Controller, *testutil.FakeThermostatService, *fakeClient) {
+	t.Helper()
+	svc := newDefaultSvc()
+	c, err := New(svc, Config{DeviceID: "room101", PayloadFormat: PayloadTemplate, Template: zigbee2mqttTemplate}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc := &fakeClient{}
+	c.client = fc
+	return c, svc, fc
+}
+
+func templateSnapshot(t *testing.T, fc *fakeClient) map[string]any {
+	t.Helper()
+	p, n := findPublish(fc, "zigbee2mqtt/room101")
+	if n == 0 {
+		t.Fatalf("snapshot not published, got %+v", fc.publishes)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(p.payload, &got); err != nil {
+		t.Fatalf("invalid payload %s: %v", p.payload, err)
+	}
+	return got
+}
+
+func TestTemplate_Snapshot(t *testing.T) {
+	c, svc, fc := newTemplateController(t)
+	svc.S.Mode = thermostat.ModeFan
+	c.publishSnapshot()
+
+	want := map[string]any{
+		"occupied_heating_setpoint": 22.0,
+		"local_temperature":         21.0,
+		"system_mode":               "fan_only",
+		"fan_mode":                  "auto",
+		"display":                   map[string]any{"setpoint_f": 71.6},
+		"min_heat_setpoint_limit":   1600.0,
+		"error":                     "none",
+		"device":                    map[string]any{"friendly_name": "room101"},
+	}
+	if got := templateSnapshot(t, fc); !reflect.DeepEqual(got, want) {
+		t.Fatalf("snapshot = %v, want %v", got, want)
+	}
+
+	fc.publishes = nil
+	svc.S.Enabled = false
+	c.publishSnapshot()
+	if got := templateSnapshot(t, fc); got["system_mode"] != "off" {
+		t.Fatalf("system_mode = %v while disabled, want off", got["system_mode"])
+	}
+}
+
+func TestTemplate_Command(t *testing.T) {
+	c, svc, fc := newTemplateController(t)
+	c.onMessage(nil, fakeMessage{
+		topic:   "zigbee2mqtt/room101/set",
+		payload: []byte(`{"system_mode":"fan_only","display":{"setpoint_f":68},"request_id":7}`),
+	})
+	if svc.S.Mode != thermostat.ModeFan || svc.S.TemperatureSetpoint != 20 || !svc.S.Enabled {
+		t.Fatalf("unexpected snapshot after command: %+v", svc.S)
+	}
+	if got := templateSnapshot(t, fc); got["occupied_heating_setpoint"] != 20.0 {
+		t.Fatalf("snapshot not republished: %v", got)
+	}
+	assertResponse(t, fc, responseDTO{RequestID: json.RawMessage("7"), Topic: "zigbee2mqtt/room101/set", Status: statusAccepted})
+
+	// Turning off, then on again through the mode.
+	c.onMessage(nil, fakeMessage{topic: "zigbee2mqtt/room101/set", payload: []byte(`{"system_mode":"off"}`)})
+	if svc.S.Enabled || svc.S.Mode != thermostat.ModeFan {
+		t.Fatalf("expected disabled in fan mode, got %+v", svc.S)
+	}
+	c.onMessage(nil, fakeMessage{topic: "zigbee2mqtt/room101/set", payload: []byte(`{"system_mode":"heat"}`)})
+	if !svc.S.Enabled || svc.S.Mode != thermostat.ModeHeat {
+		t.Fatalf("expected enabled in heat mode, got %+v", svc.S)
+	}
+}
+
+func TestTemplate_FieldCommand(t *testing.T) {
+	c, svc, fc := newTemplateController(t)
+	c.onMessage(nil, fakeMessage{topic: "zigbee2mqtt/room101/set/fan_mode", payload: []byte("high")})
+	if svc.S.FanSpeed != thermostat.FanHigh {
+		t.Fatalf("fan_speed = %v, want high", svc.S.FanSpeed)
+	}
+	c.onMessage(nil, fakeMessage{topic: "zigbee2mqtt/room101/set/min_heat_setpoint_limit", payload: []byte("1750")})
+	if svc.S.TemperatureSetpointMin != 17.5 {
+		t.Fatalf("temperature_setpoint_min = %v, want 17.5", svc.S.TemperatureSetpointMin)
+	}
+	if len(fc.publishes) == 0 {
+		t.Fatal("expected the snapshot to be republished")
+	}
+}
+
+func TestTemplate_CommandFieldOrder(t *testing.T) {
+	// Both paths set temperature_setpoint: display.setpoint_f is declared
+	// last, so it wins on every run, whatever the order of the map.
+	for range 50 {
+		c, svc, _ := newTemplateController(t)
+		c.onMessage(nil, fakeMessage{
+			topic:   "zigbee2mqtt/room101/set",
+			payload: []byte(`{"occupied_heating_setpoint":22,"display":{"setpoint_f":68}}`),
+		})
+		if svc.S.TemperatureSetpoint != 20 {
+			t.Fatalf("temperature_setpoint = %v, want 20 from display.setpoint_f", svc.S.TemperatureSetpoint)
+		}
+	}
+}
+
+func TestTemplate_CommandRejected(t *testing.T) {
+	for _, payload := range []string{
+		`{"local_temperature":25}`,             // read-only
+		`{"occupied_heating_setpoint":"warm"}`, // not a number
+		`{"system_mode":"turbo"}`,              // unknown mode
+		`{"temperature_setpoint":22}`,          // native name, not in the template
+		`{"display":{"setpoint_f":68,"x":1}}`,  // unknown nested field
+		`{"occupied_heating_setpoint":`,        // malformed
+	} {
+		c, svc, fc := newTemplateController(t)
+		c.onMessage(nil, fakeMessage{topic: "zigbee2mqtt/room101/set", payload: []byte(payload)})
+		if svc.ApplyPatchCalled {
+			t.Errorf("%s: expected ApplyPatch not called", payload)
+		}
+		if _, n := findPublish(fc, "thermocktat/room101/error"); n != 1 {
+			t.Errorf("%s: expected an error published", payload)
+		}
+	}
+}
+
+func TestTemplate_DefaultTopics(t *testing.T) {
+	svc := newDefaultSvc()
+	c, err := New(svc, Config{
+		DeviceID:      "room101",
+		PayloadFormat: PayloadTemplate,
+		Template:      Template{Fields: []TemplateField{{Attribute: "temperature_setpoint", Path: "setpoint"}}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc := &fakeClient{}
+	c.client = fc
+
+	// The template takes over <base>/set, other native topics still work.
+	c.onMessage(nil, fakeMessage{topic: "thermocktat/room101/set", payload: []byte(`{"setpoint":24}`)})
+	if svc.S.TemperatureSetpoint != 24 {
+		t.Fatalf("setpoint = %v, want 24", svc.S.TemperatureSetpoint)
+	}
+	c.onMessage(nil, fakeMessage{topic: "thermocktat/room101/set/mode", payload: []byte(`{"value":"cool"}`)})
+	if svc.S.Mode != thermostat.ModeCool {
+		t.Fatalf("mode = %v, want cool", svc.S.Mode)
+	}
+	p, _ := findPublish(fc, "thermocktat/room101/snapshot")
+	if string(p.payload) != `{"setpoint":24}` {
+		t.Fatalf("snapshot = %s", p.payload)
+	}
+}
+
+func TestTemplate_Validation(t *testing.T) {
+	field := func(f TemplateField) Template { return Template{Fields: []TemplateField{f}} }
+	tests := map[string]Template{
+		"no fields":         {},
+		"unknown attribute": field(TemplateField{Attribute: "humidity", Path: "humidity"}),
+		"empty path":        field(TemplateField{Attribute: "mode"}),
+		"invalid path":      field(TemplateField{Attribute: "mode", Path: "state..mode"}),
+		"invalid unit":      field(TemplateField{Attribute: "temperature_setpoint", Path: "sp", Unit: "K"}),
+		"unit on mode":      field(TemplateField{Attribute: "mode", Path: "mode", Unit: UnitFahrenheit}),
+		"negative scale":    field(TemplateField{Attribute: "temperature_setpoint", Path: "sp", Scale: -1}),
+		"off on enabled":    field(TemplateField{Attribute: "enabled", Path: "state", Off: "OFF"}),
+		"ambiguous values":  field(TemplateField{Attribute: "mode", Path: "mode", Values: map[string]any{"heat": "h", "auto": "h"}}),
+		"overlapping paths": {Fields: []TemplateField{
+			{Attribute: "mode", Path: "state"},
+			{Attribute: "fan_speed", Path: "state.fan"},
+		}},
+		"same topics": {
+			SnapshotTopic: "zigbee2mqtt/room101",
+			CommandTopic:  "zigbee2mqtt/room101",
+			Fields:        []TemplateField{{Attribute: "mode", Path: "mode"}},
+		},
+	}
+	for name, tpl := range tests {
+		if _, err := New(newDefaultSvc(), Config{DeviceID: "room101", PayloadFormat: PayloadTemplate, Template: tpl}, nil); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	_, err := New(newDefaultSvc(), Config{
+		DeviceID:      "room101",
+		PayloadFormat: PayloadTemplate,
+		Template:      zigbee2mqttTemplate,
+		HomeAssistant: true,
+	}, nil)
+	if err == nil {
+		t.Error("expected an error with HomeAssistant")
+	}
+}
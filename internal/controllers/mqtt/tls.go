@@ -0,0 +1,64 @@
+package mqttctrl
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/Agrid-Dev/thermocktat/internal/tlsutil"
+)
+
+// TLSConfig secures ssl:// and wss:// broker connections. CAFile verifies
+// the broker against a private CA instead of the system roots;
+// CertFile/KeyFile present a client certificate (mutual TLS).
+type TLSConfig struct {
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+}
+
+func (c TLSConfig) enabled() bool {
+	return c.CAFile != "" || c.CertFile != "" || c.KeyFile != "" || c.InsecureSkipVerify
+}
+
+// tlsSchemes are the broker URL schemes connected over TLS by both paho
+// clients.
+var tlsSchemes = map[string]bool{"ssl": true, "tls": true, "mqtts": true, "tcps": true, "wss": true}
+
+// newTLSConfig returns nil when no TLS option is set: TLS brokers are then
+// verified against the system roots.
+func newTLSConfig(cfg TLSConfig, brokerURL string) (*tls.Config, error) {
+	if !cfg.enabled() {
+		return nil, nil
+	}
+	u, err := url.Parse(brokerURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid broker url: %w", err)
+	}
+	if !tlsSchemes[u.Scheme] {
+		return nil, fmt.Errorf("TLS options need an ssl:// or wss:// broker, got %s://", u.Scheme)
+	}
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return nil, errors.New("cert_file and key_file must be set together")
+	}
+
+	c := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	if cfg.CAFile != "" {
+		if c.RootCAs, err = tlsutil.LoadCertPool(cfg.CAFile); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+	return c, nil
+}
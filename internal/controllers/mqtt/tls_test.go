@@ -0,0 +1,124 @@
+package mqttctrl
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Agrid-Dev/thermocktat/internal/tlsutil"
+)
+
+// writeCert writes cert and its key as PEM files, and returns their paths.
+func writeCert(t *testing.T, name string, cert tls.Certificate) (certFile, keyFile string) {
+	t.Helper()
+	dir := t.TempDir()
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = filepath.Join(dir, name+".pem")
+	keyFile = filepath.Join(dir, name+"-key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestTLS_MutualHandshake(t *testing.T) {
+	brokerCert, err := tlsutil.SelfSigned("broker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCert, err := tlsutil.SelfSigned("room101")
+	if err != nil {
+		t.Fatal(err)
+	}
+	caFile, _ := writeCert(t, "broker", brokerCert)
+	certFile, keyFile := writeCert(t, "client", clientCert)
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{brokerCert},
+		ClientAuth:   tls.RequireAnyClientCert,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	peer := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tc := conn.(*tls.Conn)
+		if err := tc.Handshake(); err != nil {
+			peer <- "handshake: " + err.Error()
+			return
+		}
+		peer <- tc.ConnectionState().PeerCertificates[0].Subject.CommonName
+	}()
+
+	c, err := New(newDefaultSvc(), Config{
+		DeviceID:  "room101",
+		BrokerURL: "ssl://" + ln.Addr().String(),
+		TLS:       TLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile},
+	}, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if opts := c.clientOptions(); opts.TLSConfig != c.tls {
+		t.Fatal("TLS config not set on the client options")
+	}
+
+	cfg := c.tls.Clone()
+	cfg.ServerName = "localhost"
+	conn, err := tls.Dial("tcp", ln.Addr().String(), cfg)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	if got := <-peer; got != "room101" {
+		t.Fatalf("broker saw client certificate %q", got)
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	cert, err := tlsutil.SelfSigned("broker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := writeCert(t, "broker", cert)
+
+	if c, err := newTLSConfig(TLSConfig{}, "tcp://localhost:1883"); c != nil || err != nil {
+		t.Fatalf("expected no TLS config by default, got %v, %v", c, err)
+	}
+	c, err := newTLSConfig(TLSConfig{InsecureSkipVerify: true}, "wss://broker:8884/mqtt")
+	if err != nil || !c.InsecureSkipVerify {
+		t.Fatalf("expected insecure config, got %+v, %v", c, err)
+	}
+
+	tests := []struct {
+		name   string
+		cfg    TLSConfig
+		broker string
+	}{
+		{"plain tcp broker", TLSConfig{CAFile: certFile}, "tcp://localhost:1883"},
+		{"cert without key", TLSConfig{CertFile: certFile}, "ssl://localhost:8883"},
+		{"missing CA file", TLSConfig{CAFile: filepath.Join(t.TempDir(), "ca.pem")}, "ssl://localhost:8883"},
+		{"key is not a certificate", TLSConfig{CAFile: keyFile}, "ssl://localhost:8883"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(newDefaultSvc(), Config{DeviceID: "x", BrokerURL: tt.broker, TLS: tt.cfg}, nil); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
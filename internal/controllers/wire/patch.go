@@ -0,0 +1,64 @@
+package wire
+
+import (
+	"io"
+
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+)
+
+// Patch is a partial snapshot: only the fields present are updated.
+type Patch struct {
+	Enabled                *bool    `json:"enabled"`
+	TemperatureSetpoint    *float64 `json:"temperature_setpoint"`
+	TemperatureSetpointMin *float64 `json:"temperature_setpoint_min"`
+	TemperatureSetpointMax *float64 `json:"temperature_setpoint_max"`
+	Mode                   *string  `json:"mode"`
+	FanSpeed               *string  `json:"fan_speed"`
+	FaultCode              *int     `json:"fault_code"`
+}
+
+// ToPatch parses the mode and fan speed; their errors carry the codes of
+// thermostat.ParseMode and thermostat.ParseFanSpeed.
+func (d Patch) ToPatch() (thermostat.Patch, error) {
+	p := thermostat.Patch{
+		Enabled:                d.Enabled,
+		TemperatureSetpoint:    d.TemperatureSetpoint,
+		TemperatureSetpointMin: d.TemperatureSetpointMin,
+		TemperatureSetpointMax: d.TemperatureSetpointMax,
+		FaultCode:              d.FaultCode,
+	}
+	if d.Mode != nil {
+		m, err := thermostat.ParseMode(*d.Mode)
+		if err != nil {
+			return thermostat.Patch{}, err
+		}
+		p.Mode = &m
+	}
+	if d.FanSpeed != nil {
+		f, err := thermostat.ParseFanSpeed(*d.FanSpeed)
+		if err != nil {
+			return thermostat.Patch{}, err
+		}
+		p.FanSpeed = &f
+	}
+	return p, nil
+}
+
+// DecodePatch decodes a partial snapshot. Malformed JSON and unknown fields
+// are reported with CodeInvalidRequest.
+func DecodePatch(r io.Reader) (thermostat.Patch, error) {
+	var d Patch
+	if err := Decode(r, &d); err != nil {
+		return thermostat.Patch{}, InvalidJSON(err)
+	}
+	return d.ToPatch()
+}
+
+// InvalidJSON reports a decoding error with CodeInvalidRequest.
+func InvalidJSON(err error) error {
+	return invalidRequest("invalid json: " + err.Error())
+}
+
+func invalidRequest(msg string) error {
+	return &thermostat.Error{Code: thermostat.CodeInvalidRequest, Message: msg}
+}
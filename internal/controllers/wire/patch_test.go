@@ -0,0 +1,52 @@
+package wire
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+)
+
+func TestDecodePatch(t *testing.T) {
+	p, err := DecodePatch(strings.NewReader(`{"temperature_setpoint": 21.5, "mode": "cool", "fan_speed": "high", "fault_code": 2}`))
+	if err != nil {
+		t.Fatalf("DecodePatch: %v", err)
+	}
+	if p.TemperatureSetpoint == nil || *p.TemperatureSetpoint != 21.5 {
+		t.Fatalf("setpoint=%v want 21.5", p.TemperatureSetpoint)
+	}
+	if p.Mode == nil || *p.Mode != thermostat.ModeCool {
+		t.Fatalf("mode=%v want cool", p.Mode)
+	}
+	if p.FanSpeed == nil || *p.FanSpeed != thermostat.FanHigh {
+		t.Fatalf("fan_speed=%v want high", p.FanSpeed)
+	}
+	if p.FaultCode == nil || *p.FaultCode != 2 {
+		t.Fatalf("fault_code=%v want 2", p.FaultCode)
+	}
+	if p.Enabled != nil || p.TemperatureSetpointMin != nil || p.TemperatureSetpointMax != nil {
+		t.Fatalf("absent fields set: %+v", p)
+	}
+}
+
+func TestDecodePatchErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		body string
+		want thermostat.ErrorCode
+	}{
+		{"malformed", `{"mode":`, thermostat.CodeInvalidRequest},
+		{"unknown field", `{"foo": 1}`, thermostat.CodeInvalidRequest},
+		{"wrong type", `{"enabled": "yes"}`, thermostat.CodeInvalidRequest},
+		{"invalid mode", `{"mode": "dry"}`, thermostat.CodeInvalidMode},
+		{"invalid fan speed", `{"fan_speed": "turbo"}`, thermostat.CodeInvalidFanSpeed},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := DecodePatch(strings.NewReader(tc.body))
+			if got := thermostat.Code(err); got != tc.want {
+				t.Fatalf("code=%q want %q (err=%v)", got, tc.want, err)
+			}
+		})
+	}
+}
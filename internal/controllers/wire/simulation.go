@@ -0,0 +1,109 @@
+package wire
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+)
+
+// Simulation holds the parameters of the regulator and of the heat loss
+// model.
+type Simulation struct {
+	Regulator Regulator `json:"regulator"`
+	HeatLoss  HeatLoss  `json:"heat_loss"`
+}
+
+type Regulator struct {
+	Kp                   float64 `json:"kp"`
+	Ki                   float64 `json:"ki"`
+	Kd                   float64 `json:"kd"`
+	TargetHysteresis     float64 `json:"target_hysteresis"`
+	ModeChangeHysteresis float64 `json:"mode_change_hysteresis"`
+	Interval             string  `json:"interval"`
+}
+
+type HeatLoss struct {
+	Coefficient                float64  `json:"coefficient"`
+	OutdoorTemperature         float64  `json:"outdoor_temperature"`
+	OutdoorTemperatureOverride *float64 `json:"outdoor_temperature_override"`
+}
+
+func FromSimulation(p thermostat.SimulationParams) Simulation {
+	return Simulation{
+		Regulator: Regulator{
+			Kp:                   p.Regulator.Kp,
+			Ki:                   p.Regulator.Ki,
+			Kd:                   p.Regulator.Kd,
+			TargetHysteresis:     p.Regulator.TargetHysteresis,
+			ModeChangeHysteresis: p.Regulator.ModeChangeHysteresis,
+			Interval:             p.RegulationInterval.String(),
+		},
+		HeatLoss: HeatLoss{
+			Coefficient:                p.HeatLossCoefficient,
+			OutdoorTemperature:         p.OutdoorTemperature,
+			OutdoorTemperatureOverride: p.OutdoorTemperatureOverride,
+		},
+	}
+}
+
+// SimulationPatch mirrors Simulation with optional fields.
+type SimulationPatch struct {
+	Regulator *struct {
+		Kp                   *float64 `json:"kp"`
+		Ki                   *float64 `json:"ki"`
+		Kd                   *float64 `json:"kd"`
+		TargetHysteresis     *float64 `json:"target_hysteresis"`
+		ModeChangeHysteresis *float64 `json:"mode_change_hysteresis"`
+		Interval             *string  `json:"interval"`
+	} `json:"regulator"`
+	HeatLoss *struct {
+		Coefficient *float64 `json:"coefficient"`
+		// null clears the override, a number sets it.
+		OutdoorTemperatureOverride json.RawMessage `json:"outdoor_temperature_override"`
+	} `json:"heat_loss"`
+}
+
+// ToPatch parses the interval and the override; their errors are reported
+// with CodeInvalidRequest.
+func (d SimulationPatch) ToPatch() (thermostat.SimulationPatch, error) {
+	var p thermostat.SimulationPatch
+	if r := d.Regulator; r != nil {
+		p.Kp, p.Ki, p.Kd = r.Kp, r.Ki, r.Kd
+		p.TargetHysteresis, p.ModeChangeHysteresis = r.TargetHysteresis, r.ModeChangeHysteresis
+		if r.Interval != nil {
+			d, err := time.ParseDuration(*r.Interval)
+			if err != nil {
+				return thermostat.SimulationPatch{}, invalidRequest("invalid regulator.interval: " + err.Error())
+			}
+			p.RegulationInterval = &d
+		}
+	}
+	if h := d.HeatLoss; h != nil {
+		p.HeatLossCoefficient = h.Coefficient
+		switch raw := bytes.TrimSpace(h.OutdoorTemperatureOverride); {
+		case raw == nil:
+		case string(raw) == "null":
+			p.ClearOutdoorTemperatureOverride = true
+		default:
+			var v float64
+			if err := json.Unmarshal(raw, &v); err != nil {
+				return thermostat.SimulationPatch{}, invalidRequest("invalid heat_loss.outdoor_temperature_override: expected a number or null")
+			}
+			p.OutdoorTemperatureOverride = &v
+		}
+	}
+	return p, nil
+}
+
+// DecodeSimulationPatch decodes a partial update of the simulation; every
+// error is reported with CodeInvalidRequest.
+func DecodeSimulationPatch(r io.Reader) (thermostat.SimulationPatch, error) {
+	var d SimulationPatch
+	if err := Decode(r, &d); err != nil {
+		return thermostat.SimulationPatch{}, InvalidJSON(err)
+	}
+	return d.ToPatch()
+}
@@ -0,0 +1,47 @@
+package wire
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+)
+
+func TestDecodeSimulationPatch(t *testing.T) {
+	p, err := DecodeSimulationPatch(strings.NewReader(`{"regulator": {"kp": 0.5, "interval": "250ms"}, "heat_loss": {"outdoor_temperature_override": null}}`))
+	if err != nil {
+		t.Fatalf("DecodeSimulationPatch: %v", err)
+	}
+	if p.Kp == nil || *p.Kp != 0.5 {
+		t.Fatalf("kp=%v want 0.5", p.Kp)
+	}
+	if p.RegulationInterval == nil || *p.RegulationInterval != 250*time.Millisecond {
+		t.Fatalf("interval=%v want 250ms", p.RegulationInterval)
+	}
+	if !p.ClearOutdoorTemperatureOverride || p.OutdoorTemperatureOverride != nil {
+		t.Fatalf("override: clear=%v value=%v, want cleared", p.ClearOutdoorTemperatureOverride, p.OutdoorTemperatureOverride)
+	}
+
+	p, err = DecodeSimulationPatch(strings.NewReader(`{"heat_loss": {"outdoor_temperature_override": -3.5}}`))
+	if err != nil {
+		t.Fatalf("DecodeSimulationPatch: %v", err)
+	}
+	if p.ClearOutdoorTemperatureOverride || p.OutdoorTemperatureOverride == nil || *p.OutdoorTemperatureOverride != -3.5 {
+		t.Fatalf("override: clear=%v value=%v, want -3.5", p.ClearOutdoorTemperatureOverride, p.OutdoorTemperatureOverride)
+	}
+}
+
+func TestDecodeSimulationPatchErrors(t *testing.T) {
+	for _, body := range []string{
+		`{"regulator":`,
+		`{"regulator": {"kp": 1, "foo": 2}}`,
+		`{"regulator": {"interval": "soon"}}`,
+		`{"heat_loss": {"outdoor_temperature_override": "cold"}}`,
+	} {
+		_, err := DecodeSimulationPatch(strings.NewReader(body))
+		if got := thermostat.Code(err); got != thermostat.CodeInvalidRequest {
+			t.Fatalf("%s: code=%q want %q (err=%v)", body, got, thermostat.CodeInvalidRequest, err)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+// Package wire holds the JSON documents shared by the HTTP and MQTT
+// controllers, so that both accept and produce the same payloads.
+package wire
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Decode decodes a single JSON document into v, rejecting unknown fields.
+// Controllers embed the shared documents to add their own fields, e.g. a
+// request id.
+func Decode(r io.Reader, v any) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
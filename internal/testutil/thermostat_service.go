@@ -1,6 +1,10 @@
 package testutil
 
-import "github.com/Agrid-Dev/thermocktat/internal/thermostat"
+import (
+	"time"
+
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+)
 
 var _ thermostat.Service = (*FakeThermostatService)(nil)
 
@@ -31,6 +35,27 @@ type FakeThermostatService struct {
 
 	SetFaultCodeCalled bool
 	SetFaultCodeArg    int
+
+	ApplyPatchCalled bool
+	ApplyPatchArg    thermostat.Patch
+	ApplyPatchErr    error
+
+	Weather thermostat.WeatherStatus
+
+	Sim                        thermostat.SimulationParams
+	ApplySimulationPatchCalled bool
+	ApplySimulationPatchArg    thermostat.SimulationPatch
+	ApplySimulationPatchErr    error
+
+	State thermostat.SimulationState
+
+	SetAmbientTemperatureCalled bool
+	SetAmbientTemperatureArg    float64
+	SetAmbientTemperatureErr    error
+
+	StepCalled bool
+	StepArg    time.Duration
+	StepErr    error
 }
 
 func NewFakeThermostatService() *FakeThermostatService {
@@ -102,3 +127,103 @@ func (f *FakeThermostatService) SetFaultCode(code int) {
 	f.SetFaultCodeArg = code
 	f.S.FaultCode = code
 }
+
+// ApplyPatch honours IfVersion and bumps the version when the snapshot
+// changes, like the real thermostat.
+func (f *FakeThermostatService) ApplyPatch(p thermostat.Patch) error {
+	f.ApplyPatchCalled = true
+	f.ApplyPatchArg = p
+	if f.ApplyPatchErr != nil {
+		return f.ApplyPatchErr
+	}
+	if p.IfVersion != nil && *p.IfVersion != f.S.Version {
+		return thermostat.ErrVersionMismatch
+	}
+	prev := f.S
+	if p.Enabled != nil {
+		f.S.Enabled = *p.Enabled
+	}
+	if p.TemperatureSetpoint != nil {
+		f.S.TemperatureSetpoint = *p.TemperatureSetpoint
+	}
+	if p.TemperatureSetpointMin != nil {
+		f.S.TemperatureSetpointMin = *p.TemperatureSetpointMin
+	}
+	if p.TemperatureSetpointMax != nil {
+		f.S.TemperatureSetpointMax = *p.TemperatureSetpointMax
+	}
+	if p.Mode != nil {
+		f.S.Mode = *p.Mode
+	}
+	if p.FanSpeed != nil {
+		f.S.FanSpeed = *p.FanSpeed
+	}
+	if p.FaultCode != nil {
+		f.S.FaultCode = *p.FaultCode
+	}
+	if f.S != prev {
+		f.S.Version++
+	}
+	return nil
+}
+
+func (f *FakeThermostatService) WeatherStatus() thermostat.WeatherStatus { return f.Weather }
+
+func (f *FakeThermostatService) Simulation() thermostat.SimulationParams { return f.Sim }
+
+func (f *FakeThermostatService) ApplySimulationPatch(p thermostat.SimulationPatch) error {
+	f.ApplySimulationPatchCalled = true
+	f.ApplySimulationPatchArg = p
+	if f.ApplySimulationPatchErr != nil {
+		return f.ApplySimulationPatchErr
+	}
+	if p.Kp != nil {
+		f.Sim.Regulator.Kp = *p.Kp
+	}
+	if p.Ki != nil {
+		f.Sim.Regulator.Ki = *p.Ki
+	}
+	if p.Kd != nil {
+		f.Sim.Regulator.Kd = *p.Kd
+	}
+	if p.TargetHysteresis != nil {
+		f.Sim.Regulator.TargetHysteresis = *p.TargetHysteresis
+	}
+	if p.ModeChangeHysteresis != nil {
+		f.Sim.Regulator.ModeChangeHysteresis = *p.ModeChangeHysteresis
+	}
+	if p.HeatLossCoefficient != nil {
+		f.Sim.HeatLossCoefficient = *p.HeatLossCoefficient
+	}
+	if p.OutdoorTemperatureOverride != nil {
+		f.Sim.OutdoorTemperatureOverride = p.OutdoorTemperatureOverride
+		f.Sim.OutdoorTemperature = *p.OutdoorTemperatureOverride
+	}
+	if p.ClearOutdoorTemperatureOverride {
+		f.Sim.OutdoorTemperatureOverride = nil
+	}
+	if p.RegulationInterval != nil {
+		f.Sim.RegulationInterval = *p.RegulationInterval
+	}
+	return nil
+}
+
+func (f *FakeThermostatService) SimulationState() thermostat.SimulationState { return f.State }
+
+func (f *FakeThermostatService) SetPaused(paused bool) { f.State.Paused = paused }
+
+func (f *FakeThermostatService) SetAmbientTemperature(v float64) error {
+	f.SetAmbientTemperatureCalled = true
+	f.SetAmbientTemperatureArg = v
+	if f.SetAmbientTemperatureErr != nil {
+		return f.SetAmbientTemperatureErr
+	}
+	f.S.AmbientTemperature = v
+	return nil
+}
+
+func (f *FakeThermostatService) Step(d time.Duration) error {
+	f.StepCalled = true
+	f.StepArg = d
+	return f.StepErr
+}
@@ -2,13 +2,64 @@ package thermostat
 
 import "errors"
 
+// ErrorCode is a stable, machine-readable identifier for a domain error.
+// Controllers expose it to clients (HTTP problem details, MQTT error topic)
+// so they do not have to match error messages.
+type ErrorCode string
+
+const (
+	CodeInvalidMode                  ErrorCode = "invalid_mode"
+	CodeInvalidFanSpeed              ErrorCode = "invalid_fan_speed"
+	CodeInvalidSetpoint              ErrorCode = "invalid_setpoint"
+	CodeInvalidMinMax                ErrorCode = "invalid_min_max"
+	CodeSetpointOutOfRange           ErrorCode = "setpoint_out_of_range"
+	CodeInvalidRegulatorHysteresis   ErrorCode = "invalid_regulator_hysteresis"
+	CodeInvalidRegulatorCoefficients ErrorCode = "invalid_regulator_coefficients"
+	CodeNegativeHeatLossCoefficient  ErrorCode = "negative_heat_loss_coefficient"
+	CodeInvalidRegulationInterval    ErrorCode = "invalid_regulation_interval"
+	CodeInvalidStepDuration          ErrorCode = "invalid_step_duration"
+	CodeInvalidTemperature           ErrorCode = "invalid_temperature"
+	CodeVersionMismatch              ErrorCode = "version_mismatch"
+	CodeInternal                     ErrorCode = "internal_error"
+
+	// CodeInvalidRequest is used by controllers for malformed payloads
+	// (invalid JSON, unknown or missing fields, wrong types).
+	CodeInvalidRequest ErrorCode = "invalid_request"
+)
+
+// Error is a domain error with a stable code. The Err* values below are
+// *Error, so errors.Is keeps working on wrapped errors.
+type Error struct {
+	Code    ErrorCode
+	Message string
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Code returns the code of the first *Error in err's chain, CodeInternal if
+// there is none, and "" for a nil error.
+func Code(err error) ErrorCode {
+	if err == nil {
+		return ""
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return CodeInternal
+}
+
 var (
-	ErrInvalidMode                    = errors.New("invalid mode")
-	ErrInvalidFanSpeed                = errors.New("invalid fan speed")
-	ErrInvalidSetpoint                = errors.New("invalid temperature setpoint")
-	ErrInvalidMinMax                  = errors.New("invalid min/max setpoints")
-	ErrSetpointOutOfRange             = errors.New("setpoint out of range")
-	ErrInvalidRegulatorHysteresis     = errors.New("Mode Change hysteresis must be strictly greater than Target hysteresis")
-	ErrorInvalidRegulatorCoefficients = errors.New("Regulation PID coefficients must be greater or equal to zero")
-	ErrNegativeHeatLossCoefficient    = errors.New("Heat loss coefficient must be greater or equal to zero")
+	ErrInvalidMode                    = &Error{CodeInvalidMode, "invalid mode"}
+	ErrInvalidFanSpeed                = &Error{CodeInvalidFanSpeed, "invalid fan speed"}
+	ErrInvalidSetpoint                = &Error{CodeInvalidSetpoint, "invalid temperature setpoint"}
+	ErrInvalidMinMax                  = &Error{CodeInvalidMinMax, "invalid min/max setpoints"}
+	ErrSetpointOutOfRange             = &Error{CodeSetpointOutOfRange, "setpoint out of range"}
+	ErrInvalidRegulatorHysteresis     = &Error{CodeInvalidRegulatorHysteresis, "Mode Change hysteresis must be strictly greater than Target hysteresis"}
+	ErrorInvalidRegulatorCoefficients = &Error{CodeInvalidRegulatorCoefficients, "Regulation PID coefficients must be greater or equal to zero"}
+	ErrNegativeHeatLossCoefficient    = &Error{CodeNegativeHeatLossCoefficient, "Heat loss coefficient must be greater or equal to zero"}
+	ErrInvalidRegulationInterval      = &Error{CodeInvalidRegulationInterval, "Regulation interval must be greater than zero"}
+	ErrInvalidStepDuration            = &Error{CodeInvalidStepDuration, "Step duration must be greater than zero"}
+	ErrInvalidTemperature             = &Error{CodeInvalidTemperature, "invalid temperature"}
+	ErrVersionMismatch                = &Error{CodeVersionMismatch, "version mismatch"}
 )
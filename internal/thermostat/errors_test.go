@@ -0,0 +1,32 @@
+package thermostat
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCode(t *testing.T) {
+	_, parseErr := ParseMode("weird")
+	tests := []struct {
+		name string
+		err  error
+		want ErrorCode
+	}{
+		{"nil", nil, ""},
+		{"sentinel", ErrSetpointOutOfRange, CodeSetpointOutOfRange},
+		{"wrapped", fmt.Errorf("apply: %w", ErrInvalidMinMax), CodeInvalidMinMax},
+		{"parse error", parseErr, CodeInvalidMode},
+		{"uncoded", errors.New("boom"), CodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Code(tt.err); got != tt.want {
+				t.Fatalf("Code(%v)=%q want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+	if !errors.Is(parseErr, ErrInvalidMode) {
+		t.Fatalf("ParseMode error should wrap ErrInvalidMode, got %v", parseErr)
+	}
+}
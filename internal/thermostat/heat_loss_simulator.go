@@ -20,6 +20,7 @@ func (params *HeatLossSimulatorParams) Validate() error {
 type HeatLossSimulator struct {
 	mu          sync.RWMutex
 	outdoorTemp float64
+	override    *float64 // replaces outdoorTemp while set
 	coefficient float64
 }
 
@@ -41,15 +42,69 @@ func (heatLoss *HeatLossSimulator) SetOutdoorTemperature(t float64) {
 	heatLoss.mu.Unlock()
 }
 
+// OutdoorTemperature returns the temperature the simulation uses: the override
+// when set, otherwise the last value from SetOutdoorTemperature.
 func (heatLoss *HeatLossSimulator) OutdoorTemperature() float64 {
 	heatLoss.mu.RLock()
 	defer heatLoss.mu.RUnlock()
+	return heatLoss.outdoor()
+}
+
+// weatherOutdoorTemperature ignores the override.
+func (heatLoss *HeatLossSimulator) weatherOutdoorTemperature() float64 {
+	heatLoss.mu.RLock()
+	defer heatLoss.mu.RUnlock()
+	return heatLoss.outdoorTemp
+}
+
+func (heatLoss *HeatLossSimulator) outdoor() float64 {
+	if heatLoss.override != nil {
+		return *heatLoss.override
+	}
 	return heatLoss.outdoorTemp
 }
 
+// SetOutdoorOverride pins the outdoor temperature regardless of the weather
+// provider; nil clears the override.
+func (heatLoss *HeatLossSimulator) SetOutdoorOverride(t *float64) {
+	heatLoss.mu.Lock()
+	if t != nil {
+		v := *t
+		t = &v
+	}
+	heatLoss.override = t
+	heatLoss.mu.Unlock()
+}
+
+func (heatLoss *HeatLossSimulator) OutdoorOverride() *float64 {
+	heatLoss.mu.RLock()
+	defer heatLoss.mu.RUnlock()
+	if heatLoss.override == nil {
+		return nil
+	}
+	v := *heatLoss.override
+	return &v
+}
+
+func (heatLoss *HeatLossSimulator) Coefficient() float64 {
+	heatLoss.mu.RLock()
+	defer heatLoss.mu.RUnlock()
+	return heatLoss.coefficient
+}
+
+func (heatLoss *HeatLossSimulator) SetCoefficient(c float64) error {
+	if err := (&HeatLossSimulatorParams{Coefficient: c}).Validate(); err != nil {
+		return err
+	}
+	heatLoss.mu.Lock()
+	heatLoss.coefficient = c
+	heatLoss.mu.Unlock()
+	return nil
+}
+
 func (heatLoss *HeatLossSimulator) DeltaTemperature(indoorTemperature float64, dt time.Duration) float64 {
 	heatLoss.mu.RLock()
-	outdoor, coefficient := heatLoss.outdoorTemp, heatLoss.coefficient
+	outdoor, coefficient := heatLoss.outdoor(), heatLoss.coefficient
 	heatLoss.mu.RUnlock()
 
 	diff := outdoor - indoorTemperature
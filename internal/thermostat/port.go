@@ -1,6 +1,9 @@
 package thermostat
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // Service is the inbound (driving) port: the thermostat's control API,
 // implemented by *Thermostat and consumed by the controllers
@@ -14,6 +17,14 @@ type Service interface {
 	SetMode(Mode) error
 	SetFanSpeed(FanSpeed) error
 	SetFaultCode(int)
+	ApplyPatch(Patch) error
+	WeatherStatus() WeatherStatus
+	Simulation() SimulationParams
+	ApplySimulationPatch(SimulationPatch) error
+	SimulationState() SimulationState
+	SetPaused(bool)
+	SetAmbientTemperature(float64) error
+	Step(time.Duration) error
 }
 
 // WeatherProvider is the outbound (driven) port: the outdoor temperature the
@@ -22,5 +33,30 @@ type WeatherProvider interface {
 	OutdoorTemperature(ctx context.Context) (float64, error)
 }
 
+// NamedWeatherProvider is optionally implemented by a WeatherProvider to report
+// which source served the last value (a fallback chain reports its active
+// member). It is surfaced as WeatherStatus.Provider.
+type NamedWeatherProvider interface {
+	WeatherProvider
+	Name() string
+}
+
+// PushWeatherProvider is optionally implemented by a WeatherProvider that
+// receives values rather than fetching them (e.g. over MQTT). Updates signals
+// every new value, which RunWeatherRefresh then applies right away instead of
+// at the next interval.
+type PushWeatherProvider interface {
+	WeatherProvider
+	Updates() <-chan struct{}
+}
+
+// SwitchingWeatherProvider is optionally implemented by a WeatherProvider
+// that chooses between sources (a fallback chain): Switches counts the
+// changes of active source. It is surfaced as WeatherStatus.ProviderSwitches.
+type SwitchingWeatherProvider interface {
+	WeatherProvider
+	Switches() int
+}
+
 // The core implements its own inbound port.
 var _ Service = (*Thermostat)(nil)
@@ -0,0 +1,215 @@
+package thermostat
+
+import (
+	"math"
+	"time"
+)
+
+// SimulationParams are the room simulation settings that can be tuned at
+// runtime, without losing the thermostat state.
+type SimulationParams struct {
+	Regulator           PIDRegulatorParams
+	HeatLossCoefficient float64
+	// OutdoorTemperature is the value the heat-loss simulation uses: the
+	// override when set, otherwise the weather provider's.
+	OutdoorTemperature         float64
+	OutdoorTemperatureOverride *float64
+	// RegulationInterval is the tick of Run; zero until Run has started.
+	RegulationInterval time.Duration
+}
+
+// SimulationPatch changes the fields that are set, all or nothing.
+type SimulationPatch struct {
+	Kp                   *float64
+	Ki                   *float64
+	Kd                   *float64
+	TargetHysteresis     *float64
+	ModeChangeHysteresis *float64
+	HeatLossCoefficient  *float64
+	// OutdoorTemperatureOverride pins the outdoor temperature;
+	// ClearOutdoorTemperatureOverride hands it back to the weather provider.
+	OutdoorTemperatureOverride      *float64
+	ClearOutdoorTemperatureOverride bool
+	RegulationInterval              *time.Duration
+}
+
+func (p SimulationPatch) regulator(r PIDRegulatorParams) PIDRegulatorParams {
+	if p.Kp != nil {
+		r.Kp = *p.Kp
+	}
+	if p.Ki != nil {
+		r.Ki = *p.Ki
+	}
+	if p.Kd != nil {
+		r.Kd = *p.Kd
+	}
+	if p.TargetHysteresis != nil {
+		r.TargetHysteresis = *p.TargetHysteresis
+	}
+	if p.ModeChangeHysteresis != nil {
+		r.ModeChangeHysteresis = *p.ModeChangeHysteresis
+	}
+	return r
+}
+
+func (t *Thermostat) Simulation() SimulationParams {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return SimulationParams{
+		Regulator:                  t.reg.params,
+		HeatLossCoefficient:        t.heatLoss.Coefficient(),
+		OutdoorTemperature:         t.heatLoss.OutdoorTemperature(),
+		OutdoorTemperatureOverride: t.heatLoss.OutdoorOverride(),
+		RegulationInterval:         t.interval,
+	}
+}
+
+// ApplySimulationPatch validates the changed parameters like their Validate
+// methods do at startup; on error nothing changes.
+func (t *Thermostat) ApplySimulationPatch(p SimulationPatch) error {
+	t.mu.Lock()
+	prev := t.reg.params
+	next := p.regulator(prev)
+	if next != prev {
+		if err := next.Validate(); err != nil {
+			t.mu.Unlock()
+			return err
+		}
+	}
+	if p.HeatLossCoefficient != nil {
+		if err := (&HeatLossSimulatorParams{Coefficient: *p.HeatLossCoefficient}).Validate(); err != nil {
+			t.mu.Unlock()
+			return err
+		}
+	}
+	if p.RegulationInterval != nil && *p.RegulationInterval <= 0 {
+		t.mu.Unlock()
+		return ErrInvalidRegulationInterval
+	}
+
+	t.reg.params = next
+	if p.HeatLossCoefficient != nil {
+		_ = t.heatLoss.SetCoefficient(*p.HeatLossCoefficient)
+	}
+	switch {
+	case p.OutdoorTemperatureOverride != nil:
+		t.heatLoss.SetOutdoorOverride(p.OutdoorTemperatureOverride)
+	case p.ClearOutdoorTemperatureOverride:
+		t.heatLoss.SetOutdoorOverride(nil)
+	}
+	intervalChanged := p.RegulationInterval != nil && *p.RegulationInterval != t.interval
+	if intervalChanged {
+		t.interval = *p.RegulationInterval
+	}
+	t.mu.Unlock()
+
+	if intervalChanged {
+		select {
+		case t.intervalChanged <- struct{}{}:
+		default: // Run has a wake-up pending already
+		}
+	}
+	if prev != next {
+		t.log.Info("regulator params changed",
+			"kp", next.Kp, "ki", next.Ki, "kd", next.Kd,
+			"target_hysteresis", next.TargetHysteresis,
+			"mode_change_hysteresis", next.ModeChangeHysteresis,
+		)
+	}
+	if p.HeatLossCoefficient != nil {
+		t.log.Info("heat loss coefficient changed", "to", *p.HeatLossCoefficient)
+	}
+	switch {
+	case p.OutdoorTemperatureOverride != nil:
+		t.log.Info("outdoor temperature overridden", "to", *p.OutdoorTemperatureOverride)
+	case p.ClearOutdoorTemperatureOverride:
+		t.log.Info("outdoor temperature override cleared")
+	}
+	if intervalChanged {
+		t.log.Info("regulation interval changed", "to", p.RegulationInterval.String())
+	}
+	return nil
+}
+
+// RegulationState is what the simulated HVAC equipment is doing.
+type RegulationState int
+
+const (
+	RegulationIdle RegulationState = iota
+	RegulationHeating
+	RegulationCooling
+)
+
+func (r RegulationState) String() string {
+	return [...]string{"idle", "heating", "cooling"}[r]
+}
+
+// SimulationState reports the simulation loop, as opposed to its parameters.
+type SimulationState struct {
+	Paused     bool
+	Regulation RegulationState
+}
+
+// maxStepTicks bounds the work of one Step call; longer steps use a coarser tick.
+const maxStepTicks = 100_000
+
+func (t *Thermostat) SimulationState() SimulationState {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	st := SimulationState{Paused: t.paused}
+	switch heating, cooling := t.reg.activation(); {
+	case heating:
+		st.Regulation = RegulationHeating
+	case cooling:
+		st.Regulation = RegulationCooling
+	}
+	return st
+}
+
+// SetPaused freezes the regulation loop of Run; Step still advances it.
+func (t *Thermostat) SetPaused(paused bool) {
+	t.mu.Lock()
+	prev := t.paused
+	t.paused = paused
+	t.mu.Unlock()
+	if prev != paused {
+		t.log.Info("simulation paused changed", "from", prev, "to", paused)
+	}
+}
+
+// SetAmbientTemperature forces the simulated room temperature; the simulation
+// continues from there.
+func (t *Thermostat) SetAmbientTemperature(temp float64) error {
+	if math.IsNaN(temp) || math.IsInf(temp, 0) {
+		return ErrInvalidTemperature
+	}
+	t.mu.Lock()
+	prev := t.s.AmbientTemperature
+	t.setAmbient(temp)
+	t.mu.Unlock()
+	if prev != temp {
+		t.log.Info("ambient temperature forced", "from", prev, "to", temp)
+	}
+	return nil
+}
+
+// Step advances the simulation by d at once, in ticks of the regulation
+// interval (1s before Run has started), whether paused or not.
+func (t *Thermostat) Step(d time.Duration) error {
+	if d <= 0 {
+		return ErrInvalidStepDuration
+	}
+	t.mu.RLock()
+	tick := t.interval
+	t.mu.RUnlock()
+	if tick <= 0 {
+		tick = time.Second
+	}
+	tick = max(tick, d/maxStepTicks)
+
+	for remaining := d; remaining > 0; remaining -= tick {
+		t.UpdateAmbient(min(tick, remaining))
+	}
+	t.log.Info("simulation stepped", "duration", d.String(), "ambient", t.Get().AmbientTemperature)
+	return nil
+}
@@ -0,0 +1,183 @@
+package thermostat
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+var testPIDParams = PIDRegulatorParams{Kp: 1, Ki: 0.1, Kd: 0.01, TargetHysteresis: 1, ModeChangeHysteresis: 2}
+
+func TestApplySimulationPatch(t *testing.T) {
+	th := newTestThermostat(t, testPIDParams, HeatLossSimulatorParams{Coefficient: 0.001, OutdoorTemperature: 10})
+
+	err := th.ApplySimulationPatch(SimulationPatch{
+		Kp:                  ptr(2.0),
+		TargetHysteresis:    ptr(0.5),
+		HeatLossCoefficient: ptr(0.01),
+	})
+	assertError(t, err, nil)
+
+	sim := th.Simulation()
+	assertEqual(t, "kp", sim.Regulator.Kp, 2.0)
+	assertEqual(t, "ki untouched", sim.Regulator.Ki, 0.1)
+	assertEqual(t, "target hysteresis", sim.Regulator.TargetHysteresis, 0.5)
+	assertEqual(t, "coefficient", sim.HeatLossCoefficient, 0.01)
+	assertEqual(t, "outdoor", sim.OutdoorTemperature, 10.0)
+}
+
+func TestApplySimulationPatchIsAllOrNothing(t *testing.T) {
+	th := newTestThermostat(t, testPIDParams, HeatLossSimulatorParams{Coefficient: 0.001, OutdoorTemperature: 10})
+	before := th.Simulation()
+
+	tests := []struct {
+		name  string
+		patch SimulationPatch
+		want  error
+	}{
+		{"hysteresis order", SimulationPatch{Kp: ptr(5.0), TargetHysteresis: ptr(3.0)}, ErrInvalidRegulatorHysteresis},
+		{"negative coefficient", SimulationPatch{Kd: ptr(-1.0)}, ErrorInvalidRegulatorCoefficients},
+		{"negative heat loss", SimulationPatch{Kp: ptr(5.0), HeatLossCoefficient: ptr(-0.1)}, ErrNegativeHeatLossCoefficient},
+		{"zero interval", SimulationPatch{OutdoorTemperatureOverride: ptr(30.0), RegulationInterval: ptr(time.Duration(0))}, ErrInvalidRegulationInterval},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertError(t, th.ApplySimulationPatch(tt.patch), tt.want)
+			after := th.Simulation()
+			assertEqual(t, "regulator unchanged", after.Regulator, before.Regulator)
+			assertEqual(t, "coefficient unchanged", after.HeatLossCoefficient, before.HeatLossCoefficient)
+			if after.OutdoorTemperatureOverride != nil {
+				t.Fatal("override must not be applied")
+			}
+		})
+	}
+}
+
+func TestOutdoorTemperatureOverride(t *testing.T) {
+	th := newTestThermostat(t, PIDRegulatorParams{}, HeatLossSimulatorParams{Coefficient: 0.1, OutdoorTemperature: 10}, func(s *Snapshot) {
+		s.Enabled = false
+		s.AmbientTemperature = 20
+	})
+
+	assertError(t, th.ApplySimulationPatch(SimulationPatch{OutdoorTemperatureOverride: ptr(30.0)}), nil)
+	// The weather provider keeps refreshing underneath the override.
+	th.SetOutdoorTemperature(0)
+	assertEqual(t, "overridden outdoor", th.Simulation().OutdoorTemperature, 30.0)
+
+	th.UpdateAmbient(time.Second)
+	if got := th.Get().AmbientTemperature; got <= 20 {
+		t.Fatalf("override above ambient should warm the room, got %v", got)
+	}
+
+	assertError(t, th.ApplySimulationPatch(SimulationPatch{ClearOutdoorTemperatureOverride: true}), nil)
+	sim := th.Simulation()
+	if sim.OutdoorTemperatureOverride != nil {
+		t.Fatal("override should be cleared")
+	}
+	assertEqual(t, "weather outdoor", sim.OutdoorTemperature, 0.0)
+}
+
+func TestRunPicksUpNewInterval(t *testing.T) {
+	th := newTestThermostat(t, PIDRegulatorParams{}, HeatLossSimulatorParams{Coefficient: 0.1, OutdoorTemperature: 10}, func(s *Snapshot) {
+		s.Enabled = false
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = th.Run(ctx, time.Hour) }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for th.Simulation().RegulationInterval != time.Hour {
+		if time.Now().After(deadline) {
+			t.Fatal("Run did not start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	before := th.Get().AmbientTemperature
+
+	assertError(t, th.ApplySimulationPatch(SimulationPatch{RegulationInterval: ptr(time.Millisecond)}), nil)
+	for th.Get().AmbientTemperature == before {
+		if time.Now().After(deadline) {
+			t.Fatal("regulation did not tick at the new interval")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	assertEqual(t, "interval", th.Simulation().RegulationInterval, time.Millisecond)
+}
+
+func TestSetAmbientTemperature(t *testing.T) {
+	th := newTestThermostat(t, testPIDParams, HeatLossSimulatorParams{})
+	assertError(t, th.SetAmbientTemperature(30), nil)
+	assertEqual(t, "ambient", th.Get().AmbientTemperature, 30.0)
+	assertError(t, th.SetAmbientTemperature(math.NaN()), ErrInvalidTemperature)
+	assertEqual(t, "ambient unchanged", th.Get().AmbientTemperature, 30.0)
+}
+
+func TestStepAdvancesSimulation(t *testing.T) {
+	th := newTestThermostat(t, PIDRegulatorParams{}, HeatLossSimulatorParams{Coefficient: 0.001, OutdoorTemperature: 10}, func(s *Snapshot) {
+		s.Enabled = false
+		s.AmbientTemperature = 20
+	})
+	th.SetPaused(true) // Step ignores the pause
+
+	assertError(t, th.Step(time.Hour), nil)
+
+	// Exact solution of dT/dt = k (Tout - T), which the 1s ticks approximate.
+	want := 10 + 10*math.Exp(-0.001*3600)
+	if got := th.Get().AmbientTemperature; math.Abs(got-want) > 0.01 {
+		t.Fatalf("ambient after 1h: got %.4f, want %.4f", got, want)
+	}
+	assertError(t, th.Step(0), ErrInvalidStepDuration)
+}
+
+func TestStepLongDurationIsBounded(t *testing.T) {
+	th := newTestThermostat(t, PIDRegulatorParams{}, HeatLossSimulatorParams{Coefficient: 0.0001, OutdoorTemperature: 10}, func(s *Snapshot) {
+		s.Enabled = false
+	})
+	start := time.Now()
+	assertError(t, th.Step(365*24*time.Hour), nil)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("a one-year step took %v", elapsed)
+	}
+	if got := th.Get().AmbientTemperature; math.Abs(got-10) > 0.01 {
+		t.Fatalf("ambient should settle at the outdoor temperature, got %v", got)
+	}
+}
+
+func TestSimulationStateReportsRegulation(t *testing.T) {
+	th := newTestThermostat(t, testPIDParams, HeatLossSimulatorParams{}, func(s *Snapshot) {
+		s.Mode = ModeHeat
+		s.AmbientTemperature = 15
+	})
+	assertEqual(t, "initial", th.SimulationState().Regulation, RegulationIdle)
+
+	th.UpdateAmbient(time.Millisecond)
+	assertEqual(t, "regulation", th.SimulationState().Regulation, RegulationHeating)
+	assertEqual(t, "label", th.SimulationState().Regulation.String(), "heating")
+}
+
+func TestRunSkipsTicksWhilePaused(t *testing.T) {
+	th := newTestThermostat(t, PIDRegulatorParams{}, HeatLossSimulatorParams{Coefficient: 0.1, OutdoorTemperature: 10}, func(s *Snapshot) {
+		s.Enabled = false
+	})
+	th.SetPaused(true)
+	if !th.SimulationState().Paused {
+		t.Fatal("expected paused state")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = th.Run(ctx, time.Millisecond) }()
+
+	before := th.Get().AmbientTemperature
+	time.Sleep(20 * time.Millisecond)
+	assertEqual(t, "ambient while paused", th.Get().AmbientTemperature, before)
+
+	th.SetPaused(false)
+	deadline := time.Now().Add(2 * time.Second)
+	for th.Get().AmbientTemperature == before {
+		if time.Now().After(deadline) {
+			t.Fatal("regulation did not resume")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -2,6 +2,7 @@ package thermostat
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"sync"
 	"time"
@@ -16,6 +17,62 @@ type Snapshot struct {
 	FanSpeed               FanSpeed
 	AmbientTemperature     float64
 	FaultCode              int
+
+	// Version increases on every change of a writable attribute. Ambient
+	// temperature changes, driven by the simulation, leave it unchanged.
+	Version uint64
+}
+
+// Patch is a partial update applied atomically by ApplyPatch. Nil fields are
+// left unchanged.
+type Patch struct {
+	Enabled                *bool
+	TemperatureSetpoint    *float64
+	TemperatureSetpointMin *float64
+	TemperatureSetpointMax *float64
+	Mode                   *Mode
+	FanSpeed               *FanSpeed
+	FaultCode              *int
+
+	// IfVersion makes ApplyPatch fail with ErrVersionMismatch unless the
+	// current version is *IfVersion (optimistic concurrency).
+	IfVersion *uint64
+}
+
+func (p Patch) apply(s Snapshot) Snapshot {
+	if p.Enabled != nil {
+		s.Enabled = *p.Enabled
+	}
+	if p.TemperatureSetpoint != nil {
+		s.TemperatureSetpoint = *p.TemperatureSetpoint
+	}
+	if p.TemperatureSetpointMin != nil {
+		s.TemperatureSetpointMin = *p.TemperatureSetpointMin
+	}
+	if p.TemperatureSetpointMax != nil {
+		s.TemperatureSetpointMax = *p.TemperatureSetpointMax
+	}
+	if p.Mode != nil {
+		s.Mode = *p.Mode
+	}
+	if p.FanSpeed != nil {
+		s.FanSpeed = *p.FanSpeed
+	}
+	if p.FaultCode != nil {
+		s.FaultCode = *p.FaultCode
+	}
+	return s
+}
+
+// WeatherStatus reports the health of the outdoor-temperature refresh.
+type WeatherStatus struct {
+	Provider            string // source of the last refresh attempt
+	OutdoorTemperature  float64
+	LastSuccess         time.Time
+	LastError           string
+	LastErrorAt         time.Time
+	ConsecutiveFailures int
+	ProviderSwitches    int // changes of active member of a fallback chain
 }
 
 type Thermostat struct {
@@ -23,14 +80,19 @@ type Thermostat struct {
 	s        Snapshot
 	reg      PIDRegulator
 	heatLoss *HeatLossSimulator
+	weather  WeatherStatus
 	log      *slog.Logger
+
+	interval        time.Duration // regulation tick, set by Run
+	intervalChanged chan struct{} // wakes Run to reset its ticker
+	paused          bool          // Run skips its ticks
 }
 
 func New(initial Snapshot, pidParams PIDRegulatorParams, heatLossParams HeatLossSimulatorParams, logger *slog.Logger) (*Thermostat, error) {
 	if logger == nil {
 		logger = slog.New(slog.DiscardHandler)
 	}
-	t := &Thermostat{log: logger}
+	t := &Thermostat{log: logger, intervalChanged: make(chan struct{}, 1)}
 	if err := validateSnapshot(initial); err != nil {
 		return nil, err
 	}
@@ -70,6 +132,7 @@ func (t *Thermostat) SetEnabled(on bool) {
 	t.mu.Lock()
 	prev := t.s.Enabled
 	t.s.Enabled = on
+	t.bumpVersion(prev != on)
 	t.mu.Unlock()
 	if prev != on {
 		t.log.Info("enabled changed", "from", prev, "to", on)
@@ -91,6 +154,7 @@ func (t *Thermostat) SetMode(m Mode) error {
 	t.mu.Lock()
 	prev := t.s.Mode
 	t.s.Mode = m
+	t.bumpVersion(prev != m)
 	t.mu.Unlock()
 	if prev != m {
 		t.log.Info("mode changed", "from", prev.String(), "to", m.String())
@@ -105,6 +169,7 @@ func (t *Thermostat) SetFanSpeed(f FanSpeed) error {
 	t.mu.Lock()
 	prev := t.s.FanSpeed
 	t.s.FanSpeed = f
+	t.bumpVersion(prev != f)
 	t.mu.Unlock()
 	if prev != f {
 		t.log.Info("fan_speed changed", "from", prev.String(), "to", f.String())
@@ -116,6 +181,7 @@ func (t *Thermostat) SetFaultCode(code int) {
 	t.mu.Lock()
 	prev := t.s.FaultCode
 	t.s.FaultCode = code
+	t.bumpVersion(prev != code)
 	t.mu.Unlock()
 	if prev != code {
 		t.log.Info("fault_code changed", "from", prev, "to", code)
@@ -136,6 +202,7 @@ func (t *Thermostat) SetMinMax(min, max float64) error {
 	prevMin, prevMax := t.s.TemperatureSetpointMin, t.s.TemperatureSetpointMax
 	t.s.TemperatureSetpointMin = min
 	t.s.TemperatureSetpointMax = max
+	t.bumpVersion(prevMin != min || prevMax != max)
 	t.mu.Unlock()
 	if prevMin != min || prevMax != max {
 		t.log.Info("setpoint bounds changed", "min", min, "max", max)
@@ -151,6 +218,7 @@ func (t *Thermostat) SetSetpoint(sp float64) error {
 	}
 	prev := t.s.TemperatureSetpoint
 	t.s.TemperatureSetpoint = sp
+	t.bumpVersion(prev != sp)
 	t.mu.Unlock()
 	if prev != sp {
 		t.log.Info("setpoint changed", "from", prev, "to", sp)
@@ -158,6 +226,57 @@ func (t *Thermostat) SetSetpoint(sp float64) error {
 	return nil
 }
 
+// ApplyPatch updates several attributes at once under a single lock. The
+// patched snapshot is validated as a whole, so e.g. new bounds and a setpoint
+// inside them can be set together regardless of order; on error nothing changes.
+func (t *Thermostat) ApplyPatch(p Patch) error {
+	t.mu.Lock()
+	prev := t.s
+	if p.IfVersion != nil && *p.IfVersion != prev.Version {
+		t.mu.Unlock()
+		return ErrVersionMismatch
+	}
+	next := p.apply(prev)
+	if err := validateSnapshot(next); err != nil {
+		t.mu.Unlock()
+		return err
+	}
+	t.s = next
+	t.bumpVersion(next != prev)
+	t.mu.Unlock()
+
+	t.logChanges(prev, next)
+	return nil
+}
+
+func (t *Thermostat) logChanges(prev, next Snapshot) {
+	if prev.Enabled != next.Enabled {
+		t.log.Info("enabled changed", "from", prev.Enabled, "to", next.Enabled)
+	}
+	if prev.TemperatureSetpointMin != next.TemperatureSetpointMin || prev.TemperatureSetpointMax != next.TemperatureSetpointMax {
+		t.log.Info("setpoint bounds changed", "min", next.TemperatureSetpointMin, "max", next.TemperatureSetpointMax)
+	}
+	if prev.TemperatureSetpoint != next.TemperatureSetpoint {
+		t.log.Info("setpoint changed", "from", prev.TemperatureSetpoint, "to", next.TemperatureSetpoint)
+	}
+	if prev.Mode != next.Mode {
+		t.log.Info("mode changed", "from", prev.Mode.String(), "to", next.Mode.String())
+	}
+	if prev.FanSpeed != next.FanSpeed {
+		t.log.Info("fan_speed changed", "from", prev.FanSpeed.String(), "to", next.FanSpeed.String())
+	}
+	if prev.FaultCode != next.FaultCode {
+		t.log.Info("fault_code changed", "from", prev.FaultCode, "to", next.FaultCode)
+	}
+}
+
+// bumpVersion increments the version if changed; lock held by caller.
+func (t *Thermostat) bumpVersion(changed bool) {
+	if changed {
+		t.s.Version++
+	}
+}
+
 // Internal: used by simulator
 func (t *Thermostat) setAmbient(temp float64) {
 	// lock held by caller
@@ -200,7 +319,17 @@ func activationLabel(heating, cooling bool) string {
 	}
 }
 
+// Run advances the simulation every interval until ctx is cancelled, except
+// while paused. The interval can be changed while running with
+// ApplySimulationPatch.
 func (t *Thermostat) Run(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return ErrInvalidRegulationInterval
+	}
+	t.mu.Lock()
+	t.interval = interval
+	t.mu.Unlock()
+
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
@@ -208,14 +337,24 @@ func (t *Thermostat) Run(ctx context.Context, interval time.Duration) error {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
+		case <-t.intervalChanged:
+			t.mu.RLock()
+			interval = t.interval
+			t.mu.RUnlock()
+			ticker.Reset(interval)
 		case <-ticker.C:
-			t.UpdateAmbient(interval)
+			t.mu.RLock()
+			paused := t.paused
+			t.mu.RUnlock()
+			if !paused {
+				t.UpdateAmbient(interval)
+			}
 		}
 	}
 }
 
 func (t *Thermostat) SetOutdoorTemperature(temp float64) {
-	prev := t.heatLoss.OutdoorTemperature()
+	prev := t.heatLoss.weatherOutdoorTemperature()
 	t.heatLoss.SetOutdoorTemperature(temp)
 	if prev != temp {
 		t.log.Info("outdoor temperature changed", "from", prev, "to", temp)
@@ -223,8 +362,9 @@ func (t *Thermostat) SetOutdoorTemperature(temp float64) {
 }
 
 // RunWeatherRefresh polls provider into the heat-loss simulation, fetching once
-// immediately then every interval until ctx is cancelled. A nil provider or
-// non-positive interval disables it.
+// immediately then every interval until ctx is cancelled, and whenever a
+// PushWeatherProvider signals a new value. A nil provider or non-positive
+// interval disables it.
 func (t *Thermostat) RunWeatherRefresh(ctx context.Context, provider WeatherProvider, interval time.Duration) error {
 	if provider == nil || interval <= 0 {
 		return nil
@@ -235,21 +375,68 @@ func (t *Thermostat) RunWeatherRefresh(ctx context.Context, provider WeatherProv
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	// Stays nil, never ready, for polled providers.
+	var updates <-chan struct{}
+	if push, ok := provider.(PushWeatherProvider); ok {
+		updates = push.Updates()
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		case <-ticker.C:
 			t.refreshOutdoorTemperature(ctx, provider)
+		case <-updates:
+			t.refreshOutdoorTemperature(ctx, provider)
 		}
 	}
 }
 
 func (t *Thermostat) refreshOutdoorTemperature(ctx context.Context, provider WeatherProvider) {
 	temp, err := provider.OutdoorTemperature(ctx)
+	name := providerName(provider)
+	now := time.Now()
+
+	t.mu.Lock()
+	t.weather.Provider = name
+	if switching, ok := provider.(SwitchingWeatherProvider); ok {
+		t.weather.ProviderSwitches = switching.Switches()
+	}
 	if err != nil {
-		t.log.Warn("outdoor temperature refresh failed", "err", err)
+		t.weather.LastError = err.Error()
+		t.weather.LastErrorAt = now
+		t.weather.ConsecutiveFailures++
+	} else {
+		t.weather.OutdoorTemperature = temp
+		t.weather.LastSuccess = now
+		t.weather.ConsecutiveFailures = 0
+	}
+	failures := t.weather.ConsecutiveFailures
+	t.mu.Unlock()
+
+	if err != nil {
+		t.log.Warn("outdoor temperature refresh failed",
+			"provider", name,
+			"consecutive_failures", failures,
+			"err", err,
+		)
 		return
 	}
 	t.SetOutdoorTemperature(temp)
 }
+
+// WeatherStatus returns the health of the outdoor-temperature refresh. It is
+// the zero value until RunWeatherRefresh has polled a provider once.
+func (t *Thermostat) WeatherStatus() WeatherStatus {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.weather
+}
+
+func providerName(p WeatherProvider) string {
+	if named, ok := p.(NamedWeatherProvider); ok {
+		return named.Name()
+	}
+	return fmt.Sprintf("%T", p)
+}
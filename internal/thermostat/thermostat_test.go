@@ -227,3 +227,85 @@ func TestDisabledNoRegulation(t *testing.T) {
 		t.Fatalf("disabled thermostat should only apply heat loss: got %.9f, want %.9f", got, expectedAmbient)
 	}
 }
+
+func ptr[T any](v T) *T { return &v }
+
+func TestApplyPatchBoundsAndSetpointTogether(t *testing.T) {
+	th := newTestThermostat(t, PIDRegulatorParams{}, HeatLossSimulatorParams{})
+
+	// SetMinMax(10, 15) alone would fail: the current setpoint (22) is outside.
+	err := th.ApplyPatch(Patch{
+		TemperatureSetpointMin: ptr(10.0),
+		TemperatureSetpointMax: ptr(15.0),
+		TemperatureSetpoint:    ptr(12.0),
+		Mode:                   ptr(ModeHeat),
+	})
+	assertError(t, err, nil)
+
+	s := th.Get()
+	assertEqual(t, "min", s.TemperatureSetpointMin, 10.0)
+	assertEqual(t, "max", s.TemperatureSetpointMax, 15.0)
+	assertEqual(t, "setpoint", s.TemperatureSetpoint, 12.0)
+	assertEqual(t, "mode", s.Mode, ModeHeat)
+	assertEqual(t, "fan speed untouched", s.FanSpeed, FanAuto)
+}
+
+func TestApplyPatchIsAllOrNothing(t *testing.T) {
+	th := newTestThermostat(t, PIDRegulatorParams{}, HeatLossSimulatorParams{})
+	before := th.Get()
+
+	tests := []struct {
+		name  string
+		patch Patch
+		want  error
+	}{
+		{"setpoint outside new bounds", Patch{TemperatureSetpointMax: ptr(20.0), Enabled: ptr(false)}, ErrSetpointOutOfRange},
+		{"inverted bounds", Patch{TemperatureSetpointMin: ptr(25.0), TemperatureSetpointMax: ptr(20.0)}, ErrInvalidMinMax},
+		{"invalid mode", Patch{Mode: ptr(Mode(999)), FaultCode: ptr(3)}, ErrInvalidMode},
+		{"invalid fan speed", Patch{FanSpeed: ptr(FanSpeed(999))}, ErrInvalidFanSpeed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertError(t, th.ApplyPatch(tt.patch), tt.want)
+			assertEqual(t, "snapshot unchanged", th.Get(), before)
+		})
+	}
+}
+
+func TestApplyPatchEmptyIsNoOp(t *testing.T) {
+	th := newTestThermostat(t, PIDRegulatorParams{}, HeatLossSimulatorParams{})
+	before := th.Get()
+	assertError(t, th.ApplyPatch(Patch{}), nil)
+	assertEqual(t, "snapshot", th.Get(), before)
+}
+
+func TestVersionBumpsOnChangesOnly(t *testing.T) {
+	th := newTestThermostat(t, PIDRegulatorParams{}, HeatLossSimulatorParams{})
+	v := th.Get().Version
+
+	th.SetEnabled(true) // unchanged
+	assertError(t, th.SetSetpoint(22), nil)
+	assertEqual(t, "version after no-op writes", th.Get().Version, v)
+
+	assertError(t, th.SetSetpoint(23), nil)
+	assertEqual(t, "version after setpoint", th.Get().Version, v+1)
+
+	assertError(t, th.ApplyPatch(Patch{Mode: ptr(ModeHeat), FaultCode: ptr(2)}), nil)
+	assertEqual(t, "version after patch", th.Get().Version, v+2)
+
+	assertError(t, th.SetAmbientTemperature(30), nil)
+	th.UpdateAmbient(time.Second)
+	assertEqual(t, "version after ambient changes", th.Get().Version, v+2)
+}
+
+func TestApplyPatchIfVersion(t *testing.T) {
+	th := newTestThermostat(t, PIDRegulatorParams{}, HeatLossSimulatorParams{})
+	v := th.Get().Version
+
+	assertError(t, th.ApplyPatch(Patch{TemperatureSetpoint: ptr(20.0), IfVersion: ptr(v)}), nil)
+
+	// A second writer still holding the old version is rejected.
+	before := th.Get()
+	assertError(t, th.ApplyPatch(Patch{TemperatureSetpoint: ptr(25.0), IfVersion: ptr(v)}), ErrVersionMismatch)
+	assertEqual(t, "snapshot unchanged", th.Get(), before)
+}
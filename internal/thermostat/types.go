@@ -44,7 +44,7 @@ func ParseMode(s string) (Mode, error) {
 	case "auto":
 		return ModeAuto, nil
 	default:
-		return ModeUnknown, fmt.Errorf("invalid mode: %q", s)
+		return ModeUnknown, fmt.Errorf("%w: %q", ErrInvalidMode, s)
 	}
 }
 
@@ -89,6 +89,6 @@ func ParseFanSpeed(s string) (FanSpeed, error) {
 	case "high":
 		return FanHigh, nil
 	default:
-		return FanUnknown, fmt.Errorf("invalid fan speed: %q", s)
+		return FanUnknown, fmt.Errorf("%w: %q", ErrInvalidFanSpeed, s)
 	}
 }
@@ -2,6 +2,7 @@ package thermostat_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -107,3 +108,84 @@ func TestRunWeatherRefreshNoOpWhenDisabled(t *testing.T) {
 		t.Fatalf("provider should not be called when disabled, got %d calls", provider.CallCount())
 	}
 }
+
+func TestRunWeatherRefreshReportsStatus(t *testing.T) {
+	th := newDisabledThermostat(t, 20, 20, 0.5)
+	provider := testutil.NewFakeWeatherProvider(12)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = th.RunWeatherRefresh(ctx, provider, 5*time.Millisecond) }()
+
+	waitFor(t, func() bool { return !th.WeatherStatus().LastSuccess.IsZero() })
+
+	st := th.WeatherStatus()
+	if st.OutdoorTemperature != 12 || st.ConsecutiveFailures != 0 || st.LastError != "" {
+		t.Fatalf("unexpected healthy status: %+v", st)
+	}
+	if st.Provider == "" {
+		t.Fatal("expected provider to be reported")
+	}
+	cancel()
+}
+
+func TestRunWeatherRefreshCountsFailures(t *testing.T) {
+	th := newDisabledThermostat(t, 20, 20, 0.5)
+	provider := testutil.NewFakeWeatherProvider(12)
+	provider.Err = errors.New("upstream down")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = th.RunWeatherRefresh(ctx, provider, 5*time.Millisecond) }()
+
+	waitFor(t, func() bool { return th.WeatherStatus().ConsecutiveFailures >= 2 })
+	cancel()
+
+	st := th.WeatherStatus()
+	if st.LastError != "upstream down" || st.LastErrorAt.IsZero() {
+		t.Fatalf("expected last error to be recorded, got %+v", st)
+	}
+	if !st.LastSuccess.IsZero() {
+		t.Fatalf("expected no success, got %v", st.LastSuccess)
+	}
+}
+
+// pushProvider is a push-based provider: values arrive on set.
+type pushProvider struct {
+	*testutil.FakeWeatherProvider
+	updates chan struct{}
+}
+
+func (p pushProvider) Updates() <-chan struct{} { return p.updates }
+
+func TestRunWeatherRefreshAppliesPushedValues(t *testing.T) {
+	th := newDisabledThermostat(t, 20, 20, 0.5)
+	provider := pushProvider{
+		FakeWeatherProvider: &testutil.FakeWeatherProvider{Temps: []float64{20, 35}},
+		updates:             make(chan struct{}, 1),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// An hour-long interval: only the pushed update can refresh in time.
+	go func() { _ = th.RunWeatherRefresh(ctx, provider, time.Hour) }()
+
+	waitFor(t, func() bool { return provider.CallCount() >= 1 })
+	provider.updates <- struct{}{}
+	waitFor(t, func() bool { return th.WeatherStatus().OutdoorTemperature == 35 })
+}
+
+type switchingProvider struct{ *testutil.FakeWeatherProvider }
+
+func (switchingProvider) Switches() int { return 3 }
+
+func TestRunWeatherRefreshReportsProviderSwitches(t *testing.T) {
+	th := newDisabledThermostat(t, 20, 20, 0.5)
+	provider := switchingProvider{testutil.NewFakeWeatherProvider(12)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = th.RunWeatherRefresh(ctx, provider, time.Hour) }()
+
+	waitFor(t, func() bool { return th.WeatherStatus().ProviderSwitches == 3 })
+}
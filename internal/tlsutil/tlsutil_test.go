@@ -0,0 +1,61 @@
+package tlsutil
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSelfSignedCoversHosts(t *testing.T) {
+	cert, err := SelfSigned("test", "thermo.local", "10.0.0.7")
+	if err != nil {
+		t.Fatalf("SelfSigned: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert.Leaf)
+	for _, host := range []string{"localhost", "127.0.0.1", "thermo.local", "10.0.0.7"} {
+		if _, err := cert.Leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: pool}); err != nil {
+			t.Fatalf("verify for %s: %v", host, err)
+		}
+	}
+	if _, err := cert.Leaf.Verify(x509.VerifyOptions{DNSName: "other.local", Roots: pool}); err == nil {
+		t.Fatal("certificate must not be valid for other hosts")
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	cert, err := SelfSigned("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp, err := Fingerprint(cert)
+	if err != nil || len(fp) != 64 {
+		t.Fatalf("Fingerprint = %q, %v", fp, err)
+	}
+}
+
+func TestLoadCertPool(t *testing.T) {
+	cert, err := SelfSigned("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	good := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(good, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCertPool(good); err != nil {
+		t.Fatalf("LoadCertPool: %v", err)
+	}
+
+	bad := filepath.Join(dir, "empty.pem")
+	if err := os.WriteFile(bad, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCertPool(bad); err == nil {
+		t.Fatal("expected error for file without certificates")
+	}
+}
@@ -0,0 +1,191 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+)
+
+const defaultFailureThreshold = 3
+
+type FallbackConfig struct {
+	// Providers in priority order; the first one is the primary.
+	Providers []thermostat.WeatherProvider
+
+	// FailureThreshold is the number of consecutive failures of the active
+	// provider before moving to the next one. Defaults to 3.
+	FailureThreshold int
+
+	Logger *slog.Logger
+}
+
+// Fallback serves the outdoor temperature from the highest-priority provider
+// that works. The active provider is only abandoned after FailureThreshold
+// consecutive failures, for the first of the next providers that works, and
+// every higher-priority provider is probed again on each call so the chain
+// returns to the primary as soon as it recovers.
+type Fallback struct {
+	providers []thermostat.WeatherProvider
+	threshold int
+	log       *slog.Logger
+
+	// updates forwards the signals of push-based members, while Run runs.
+	updates chan struct{}
+
+	mu       sync.Mutex
+	active   int
+	failures int
+	switches int
+}
+
+var (
+	_ Runner                              = (*Fallback)(nil)
+	_ thermostat.PushWeatherProvider      = (*Fallback)(nil)
+	_ thermostat.SwitchingWeatherProvider = (*Fallback)(nil)
+)
+
+func NewFallback(cfg FallbackConfig) (*Fallback, error) {
+	if len(cfg.Providers) == 0 {
+		return nil, errors.New("weather fallback: at least one provider is required")
+	}
+	threshold := cfg.FailureThreshold
+	if threshold <= 0 {
+		threshold = defaultFailureThreshold
+	}
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	return &Fallback{
+		providers: cfg.Providers,
+		threshold: threshold,
+		log:       logger,
+		updates:   make(chan struct{}, 1),
+	}, nil
+}
+
+func (f *Fallback) OutdoorTemperature(ctx context.Context) (float64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := 0; i < f.active; i++ {
+		temp, err := f.providers[i].OutdoorTemperature(ctx)
+		if err == nil {
+			f.log.Info("weather provider recovered",
+				"from", f.nameAt(f.active),
+				"to", f.nameAt(i),
+			)
+			f.switchTo(i)
+			return temp, nil
+		}
+	}
+
+	temp, err := f.providers[f.active].OutdoorTemperature(ctx)
+	if err == nil {
+		f.failures = 0
+		return temp, nil
+	}
+
+	f.failures++
+	if f.failures < f.threshold || f.active == len(f.providers)-1 {
+		return 0, fmt.Errorf("%s: %w", f.nameAt(f.active), err)
+	}
+
+	// Fall back to the first of the next providers that works, rather
+	// than to each in turn at the pace of the refreshes.
+	activeErr := err
+	for next := f.active + 1; next < len(f.providers); next++ {
+		temp, err := f.providers[next].OutdoorTemperature(ctx)
+		if err != nil {
+			f.log.Debug("weather fallback provider failing", "provider", f.nameAt(next), "err", err)
+			continue
+		}
+		f.log.Warn("weather provider failing, falling back",
+			"from", f.nameAt(f.active),
+			"to", f.nameAt(next),
+			"consecutive_failures", f.failures,
+			"err", activeErr,
+		)
+		f.switchTo(next)
+		return temp, nil
+	}
+	// None works: stay on the active provider, and probe again next time.
+	return 0, fmt.Errorf("%s: %w", f.nameAt(f.active), activeErr)
+}
+
+func (f *Fallback) switchTo(i int) {
+	f.active, f.failures = i, 0
+	f.switches++
+}
+
+// Switches counts the changes of active provider, fallbacks and recoveries.
+func (f *Fallback) Switches() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.switches
+}
+
+// Updates signals the values received by push-based members, so that the
+// chain is refreshed as they arrive.
+func (f *Fallback) Updates() <-chan struct{} { return f.updates }
+
+// Run runs every member that implements Runner, and forwards the updates of
+// push-based members, returning on the first failure or once ctx is
+// cancelled. It returns nil right away when no member needs to run.
+func (f *Fallback) Run(ctx context.Context) error {
+	for _, p := range f.providers {
+		if push, ok := p.(thermostat.PushWeatherProvider); ok {
+			go f.forward(ctx, push.Updates())
+		}
+	}
+
+	errCh := make(chan error, len(f.providers))
+	running := 0
+	for _, p := range f.providers {
+		if r, ok := p.(Runner); ok {
+			running++
+			go func() { errCh <- r.Run(ctx) }()
+		}
+	}
+	for range running {
+		if err := <-errCh; err != nil && !errors.Is(err, context.Canceled) {
+			return err
+		}
+	}
+	if running == 0 {
+		return nil
+	}
+	return ctx.Err()
+}
+
+func (f *Fallback) forward(ctx context.Context, updates <-chan struct{}) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-updates:
+			select {
+			case f.updates <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// Name reports the provider currently serving values.
+func (f *Fallback) Name() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.nameAt(f.active)
+}
+
+func (f *Fallback) nameAt(i int) string {
+	if named, ok := f.providers[i].(thermostat.NamedWeatherProvider); ok {
+		return named.Name()
+	}
+	return fmt.Sprintf("%T", f.providers[i])
+}
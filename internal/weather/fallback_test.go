@@ -0,0 +1,158 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Agrid-Dev/thermocktat/internal/testutil"
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+)
+
+func newTestFallback(t *testing.T, threshold int, providers ...thermostat.WeatherProvider) *Fallback {
+	t.Helper()
+	f, err := NewFallback(FallbackConfig{Providers: providers, FailureThreshold: threshold})
+	if err != nil {
+		t.Fatalf("NewFallback: %v", err)
+	}
+	return f
+}
+
+func TestFallbackRequiresProviders(t *testing.T) {
+	if _, err := NewFallback(FallbackConfig{}); err == nil {
+		t.Fatal("expected error for empty chain")
+	}
+}
+
+func TestFallbackServesPrimaryWhenHealthy(t *testing.T) {
+	primary := testutil.NewFakeWeatherProvider(18)
+	secondary := testutil.NewFakeWeatherProvider(5)
+	f := newTestFallback(t, 2, primary, secondary)
+
+	got, err := f.OutdoorTemperature(context.Background())
+	if err != nil || got != 18 {
+		t.Fatalf("got %v, err %v; want 18", got, err)
+	}
+	if secondary.CallCount() != 0 {
+		t.Fatalf("secondary should not be called, got %d calls", secondary.CallCount())
+	}
+}
+
+func TestFallbackSwitchesAfterThresholdAndRecovers(t *testing.T) {
+	primary := testutil.NewFakeWeatherProvider(18)
+	primary.Err = errors.New("upstream down")
+	secondary := NewStatic(5)
+	f := newTestFallback(t, 2, primary, secondary)
+	ctx := context.Background()
+
+	// First failure stays on the primary and surfaces the error.
+	if _, err := f.OutdoorTemperature(ctx); err == nil {
+		t.Fatal("expected error below failure threshold")
+	}
+	if f.Name() == "static" {
+		t.Fatal("should not fall back before the threshold")
+	}
+
+	// Second consecutive failure falls back and serves the next provider.
+	got, err := f.OutdoorTemperature(ctx)
+	if err != nil || got != 5 {
+		t.Fatalf("got %v, err %v; want fallback value 5", got, err)
+	}
+	if f.Name() != "static" {
+		t.Fatalf("active provider = %q, want static", f.Name())
+	}
+
+	// Primary recovers: the next call returns to it.
+	primary.Err = nil
+	got, err = f.OutdoorTemperature(ctx)
+	if err != nil || got != 18 {
+		t.Fatalf("got %v, err %v; want primary value 18", got, err)
+	}
+	if f.Name() == "static" {
+		t.Fatal("expected chain to return to the primary")
+	}
+}
+
+func TestFallbackLastProviderErrorsSurface(t *testing.T) {
+	only := testutil.NewFakeWeatherProvider(0)
+	only.Err = errors.New("boom")
+	f := newTestFallback(t, 1, only)
+
+	for i := 0; i < 3; i++ {
+		if _, err := f.OutdoorTemperature(context.Background()); err == nil {
+			t.Fatalf("call %d: expected error from exhausted chain", i)
+		}
+	}
+}
+
+// pushFake is a push-based member, without a connection to run.
+type pushFake struct {
+	*testutil.FakeWeatherProvider
+	updates chan struct{}
+}
+
+func (p pushFake) Updates() <-chan struct{} { return p.updates }
+
+func TestFallbackForwardsMemberUpdates(t *testing.T) {
+	member := pushFake{testutil.NewFakeWeatherProvider(7), make(chan struct{}, 1)}
+	f := newTestFallback(t, 2, testutil.NewFakeWeatherProvider(18), member)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := f.Run(ctx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	member.updates <- struct{}{}
+	select {
+	case <-f.Updates():
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the member's update to be forwarded")
+	}
+}
+
+func TestFallbackSkipsFailingProvidersInOneCall(t *testing.T) {
+	primary := testutil.NewFakeWeatherProvider(18)
+	primary.Err = errors.New("upstream down")
+	secondary := testutil.NewFakeWeatherProvider(12)
+	secondary.Err = errors.New("file missing")
+	last := NewStatic(5)
+	f := newTestFallback(t, 2, primary, secondary, last)
+	ctx := context.Background()
+
+	if _, err := f.OutdoorTemperature(ctx); err == nil {
+		t.Fatal("expected error below failure threshold")
+	}
+	// The threshold is reached: the failing secondary is passed over within
+	// the same call.
+	got, err := f.OutdoorTemperature(ctx)
+	if err != nil || got != 5 {
+		t.Fatalf("got %v, err %v; want last provider value 5", got, err)
+	}
+	if f.Name() != "static" || f.Switches() != 1 {
+		t.Fatalf("active = %q after %d switches, want static after 1", f.Name(), f.Switches())
+	}
+
+	// The secondary recovers: the chain moves up to it.
+	secondary.Err = nil
+	if got, _ := f.OutdoorTemperature(ctx); got != 12 || f.Switches() != 2 {
+		t.Fatalf("got %v after %d switches, want 12 after 2", got, f.Switches())
+	}
+}
+
+func TestFallbackStaysWhenNoProviderWorks(t *testing.T) {
+	primary := testutil.NewFakeWeatherProvider(18)
+	primary.Err = errors.New("upstream down")
+	secondary := testutil.NewFakeWeatherProvider(12)
+	secondary.Err = errors.New("file missing")
+	f := newTestFallback(t, 1, primary, secondary)
+
+	_, err := f.OutdoorTemperature(context.Background())
+	if err == nil || !errors.Is(err, primary.Err) {
+		t.Fatalf("expected the primary's error, got %v", err)
+	}
+	if f.Switches() != 0 {
+		t.Fatalf("expected no switch, got %d", f.Switches())
+	}
+}
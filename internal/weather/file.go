@@ -0,0 +1,33 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// File reads the outdoor temperature from a text file holding a single number,
+// re-read on every call so an external process (or a test) can update it.
+type File struct {
+	path string
+}
+
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+func (f *File) OutdoorTemperature(context.Context) (float64, error) {
+	b, err := os.ReadFile(f.path)
+	if err != nil {
+		return 0, fmt.Errorf("read weather file: %w", err)
+	}
+	temp, err := strconv.ParseFloat(strings.TrimSpace(string(b)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse weather file %s: %w", f.path, err)
+	}
+	return temp, nil
+}
+
+func (f *File) Name() string { return "file" }
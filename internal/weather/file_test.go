@@ -0,0 +1,46 @@
+package weather
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileReadsCurrentValue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outdoor")
+	if err := os.WriteFile(path, []byte("12.5\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f := NewFile(path)
+
+	got, err := f.OutdoorTemperature(context.Background())
+	if err != nil || got != 12.5 {
+		t.Fatalf("got %v, err %v; want 12.5", got, err)
+	}
+
+	// The file is re-read on every call.
+	if err := os.WriteFile(path, []byte("-3"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err = f.OutdoorTemperature(context.Background())
+	if err != nil || got != -3 {
+		t.Fatalf("got %v, err %v; want -3", got, err)
+	}
+}
+
+func TestFileErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := NewFile(filepath.Join(dir, "missing")).OutdoorTemperature(context.Background()); err == nil {
+		t.Fatal("expected error for missing file")
+	}
+
+	path := filepath.Join(dir, "garbage")
+	if err := os.WriteFile(path, []byte("warm"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFile(path).OutdoorTemperature(context.Background()); err == nil {
+		t.Fatal("expected error for non-numeric content")
+	}
+}
@@ -0,0 +1,206 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Agrid-Dev/thermocktat/internal/thermostat"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Runner is implemented by providers that hold a connection open and must run
+// for the lifetime of the app (e.g. MQTT). Run blocks until ctx is cancelled.
+type Runner interface {
+	Run(ctx context.Context) error
+}
+
+var (
+	_ Runner                         = (*MQTT)(nil)
+	_ thermostat.PushWeatherProvider = (*MQTT)(nil)
+)
+
+type MQTTConfig struct {
+	BrokerURL string
+	ClientID  string
+	Topic     string
+
+	// JSONPath selects the temperature in a JSON payload as dot-separated keys
+	// (e.g. "outdoor.temperature"). Empty expects a plain numeric payload.
+	JSONPath string
+
+	QoS byte
+
+	// MaxAge is how long a received value stays valid; older values are
+	// reported as errors so a fallback chain can take over. Zero never expires.
+	MaxAge time.Duration
+
+	Username string
+	Password string
+	Logger   *slog.Logger
+}
+
+// MQTT follows the outdoor temperature published on a broker topic, e.g. by a
+// building weather station. It serves the last received value, and signals
+// every new one on Updates.
+type MQTT struct {
+	cfg     MQTTConfig
+	log     *slog.Logger
+	updates chan struct{}
+
+	mu         sync.Mutex
+	last       float64
+	hasLast    bool
+	receivedAt time.Time
+	now        func() time.Time
+}
+
+func NewMQTT(cfg MQTTConfig) (*MQTT, error) {
+	if strings.TrimSpace(cfg.Topic) == "" {
+		return nil, errors.New("weather mqtt: Topic is required")
+	}
+	if cfg.BrokerURL == "" {
+		cfg.BrokerURL = "tcp://localhost:1883"
+	}
+	if cfg.ClientID == "" {
+		cfg.ClientID = "thermocktat-weather"
+	}
+	if cfg.QoS > 1 {
+		return nil, errors.New("weather mqtt: QoS must be 0 or 1")
+	}
+	cfg.JSONPath = strings.TrimPrefix(strings.TrimPrefix(cfg.JSONPath, "$"), ".")
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	return &MQTT{cfg: cfg, log: logger, updates: make(chan struct{}, 1), now: time.Now}, nil
+}
+
+func (m *MQTT) Name() string { return "mqtt" }
+
+// Updates signals received values. Signals coalesce: a value received while
+// the previous one is still pending is served by the same refresh.
+func (m *MQTT) Updates() <-chan struct{} { return m.updates }
+
+// Run connects to the broker and keeps the subscription alive until ctx is
+// cancelled.
+func (m *MQTT) Run(ctx context.Context) error {
+	opts := mqtt.NewClientOptions().
+		AddBroker(m.cfg.BrokerURL).
+		SetClientID(m.cfg.ClientID).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(2 * time.Second)
+
+	if m.cfg.Username != "" {
+		opts.SetUsername(m.cfg.Username)
+		opts.SetPassword(m.cfg.Password)
+	}
+
+	// Subscribe when connected/reconnected.
+	opts.OnConnect = func(cl mqtt.Client) {
+		m.log.Info("mqtt broker connected", "topic", m.cfg.Topic)
+		tok := cl.Subscribe(m.cfg.Topic, m.cfg.QoS, m.onMessage)
+		tok.Wait()
+		if err := tok.Error(); err != nil {
+			m.log.Warn("mqtt subscribe failed", "topic", m.cfg.Topic, "err", err)
+		}
+	}
+
+	client := mqtt.NewClient(opts)
+	tok := client.Connect()
+	// Connection attempts are retried until they succeed: give up on
+	// shutdown, e.g. while the broker is unreachable.
+	select {
+	case <-tok.Done():
+	case <-ctx.Done():
+		client.Disconnect(0)
+		return ctx.Err()
+	}
+	if err := tok.Error(); err != nil {
+		return fmt.Errorf("weather mqtt connect: %w", err)
+	}
+
+	<-ctx.Done()
+	client.Disconnect(250)
+	return ctx.Err()
+}
+
+func (m *MQTT) OutdoorTemperature(context.Context) (float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.hasLast {
+		return 0, fmt.Errorf("no value received yet on %s", m.cfg.Topic)
+	}
+	if age := m.now().Sub(m.receivedAt); m.cfg.MaxAge > 0 && age > m.cfg.MaxAge {
+		return 0, fmt.Errorf("last value on %s is %s old", m.cfg.Topic, age.Round(time.Second))
+	}
+	return m.last, nil
+}
+
+func (m *MQTT) onMessage(_ mqtt.Client, msg mqtt.Message) {
+	temp, err := parseTemperature(msg.Payload(), m.cfg.JSONPath)
+	if err != nil {
+		m.log.Warn("mqtt payload ignored", "topic", msg.Topic(), "err", err)
+		return
+	}
+
+	m.mu.Lock()
+	m.last = temp
+	m.hasLast = true
+	m.receivedAt = m.now()
+	m.mu.Unlock()
+
+	select {
+	case m.updates <- struct{}{}:
+	default:
+	}
+
+	m.log.Debug("mqtt outdoor temperature received", "topic", msg.Topic(), "temperature", temp)
+}
+
+// parseTemperature reads a plain numeric payload, or the number found at the
+// dot-separated path of a JSON payload. Numeric strings are accepted.
+func parseTemperature(payload []byte, path string) (float64, error) {
+	if path == "" {
+		return parseNumber(strings.TrimSpace(string(payload)))
+	}
+
+	var v any
+	if err := json.Unmarshal(payload, &v); err != nil {
+		return 0, fmt.Errorf("decode json payload: %w", err)
+	}
+	for key := range strings.SplitSeq(path, ".") {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return 0, fmt.Errorf("json path %q: %q is not inside an object", path, key)
+		}
+		if v, ok = obj[key]; !ok {
+			return 0, fmt.Errorf("json path %q: missing key %q", path, key)
+		}
+	}
+
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case string:
+		return parseNumber(n)
+	default:
+		return 0, fmt.Errorf("json path %q: value %v is not a number", path, v)
+	}
+}
+
+func parseNumber(s string) (float64, error) {
+	temp, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse temperature %q: %w", s, err)
+	}
+	return temp, nil
+}
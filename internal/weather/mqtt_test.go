@@ -0,0 +1,168 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+type fakeMessage struct {
+	topic   string
+	payload []byte
+}
+
+func (m fakeMessage) Duplicate() bool   { return false }
+func (m fakeMessage) Qos() byte         { return 0 }
+func (m fakeMessage) Retained() bool    { return false }
+func (m fakeMessage) Topic() string     { return m.topic }
+func (m fakeMessage) MessageID() uint16 { return 0 }
+func (m fakeMessage) Payload() []byte   { return m.payload }
+func (m fakeMessage) Ack()              {}
+
+func newTestMQTT(t *testing.T, cfg MQTTConfig) *MQTT {
+	t.Helper()
+	if cfg.Topic == "" {
+		cfg.Topic = "site/weather"
+	}
+	m, err := NewMQTT(cfg)
+	if err != nil {
+		t.Fatalf("NewMQTT: %v", err)
+	}
+	return m
+}
+
+func TestNewMQTTValidation(t *testing.T) {
+	if _, err := NewMQTT(MQTTConfig{}); err == nil {
+		t.Fatal("expected error when Topic missing")
+	}
+	if _, err := NewMQTT(MQTTConfig{Topic: "x", QoS: 2}); err == nil {
+		t.Fatal("expected error when QoS > 1")
+	}
+}
+
+func TestMQTTNoValueYet(t *testing.T) {
+	m := newTestMQTT(t, MQTTConfig{})
+	if _, err := m.OutdoorTemperature(context.Background()); err == nil {
+		t.Fatal("expected error before any message is received")
+	}
+}
+
+func TestMQTTPlainPayload(t *testing.T) {
+	m := newTestMQTT(t, MQTTConfig{})
+
+	m.onMessage(nil, fakeMessage{topic: "site/weather", payload: []byte(" 8.25\n")})
+
+	got, err := m.OutdoorTemperature(context.Background())
+	if err != nil || got != 8.25 {
+		t.Fatalf("got %v, err %v; want 8.25", got, err)
+	}
+}
+
+func TestMQTTJSONPathPayload(t *testing.T) {
+	m := newTestMQTT(t, MQTTConfig{JSONPath: "$.outdoor.temperature"})
+
+	m.onMessage(nil, fakeMessage{payload: []byte(`{"outdoor":{"temperature":-2.5,"humidity":80}}`)})
+
+	got, err := m.OutdoorTemperature(context.Background())
+	if err != nil || got != -2.5 {
+		t.Fatalf("got %v, err %v; want -2.5", got, err)
+	}
+}
+
+func TestMQTTInvalidPayloadKeepsLastValue(t *testing.T) {
+	m := newTestMQTT(t, MQTTConfig{JSONPath: "temp"})
+
+	m.onMessage(nil, fakeMessage{payload: []byte(`{"temp":"14.5"}`)})
+	m.onMessage(nil, fakeMessage{payload: []byte(`{"humidity":50}`)})
+	m.onMessage(nil, fakeMessage{payload: []byte(`not json`)})
+
+	got, err := m.OutdoorTemperature(context.Background())
+	if err != nil || got != 14.5 {
+		t.Fatalf("got %v, err %v; want 14.5", got, err)
+	}
+}
+
+func TestMQTTValueExpiresAfterMaxAge(t *testing.T) {
+	m := newTestMQTT(t, MQTTConfig{MaxAge: time.Minute})
+	now := time.Date(2026, 6, 26, 14, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	m.onMessage(nil, fakeMessage{payload: []byte("11")})
+
+	now = now.Add(30 * time.Second)
+	if _, err := m.OutdoorTemperature(context.Background()); err != nil {
+		t.Fatalf("fresh value: unexpected error %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := m.OutdoorTemperature(context.Background()); err == nil {
+		t.Fatal("expected error once the value is older than MaxAge")
+	}
+}
+
+func TestParseTemperatureErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		path    string
+	}{
+		{"plain not a number", "warm", ""},
+		{"path through scalar", `{"a":1}`, "a.b"},
+		{"boolean value", `{"a":true}`, "a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseTemperature([]byte(tt.payload), tt.path); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestMQTTSignalsUpdates(t *testing.T) {
+	m := newTestMQTT(t, MQTTConfig{})
+	m.onMessage(nil, fakeMessage{topic: "site/weather", payload: []byte("12")})
+	// Coalesced with the pending signal, without blocking.
+	m.onMessage(nil, fakeMessage{topic: "site/weather", payload: []byte("13")})
+
+	select {
+	case <-m.Updates():
+	default:
+		t.Fatal("expected an update signal")
+	}
+	select {
+	case <-m.Updates():
+		t.Fatal("expected signals to coalesce")
+	default:
+	}
+	if got, _ := m.OutdoorTemperature(context.Background()); got != 13 {
+		t.Fatalf("expected 13, got %v", got)
+	}
+}
+
+func TestMQTTRunStopsWhileBrokerUnreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close() // nothing listens there anymore
+
+	m := newTestMQTT(t, MQTTConfig{BrokerURL: "tcp://" + addr})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- m.Run(ctx) }()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return on shutdown")
+	}
+}
@@ -26,6 +26,10 @@ type OpenMeteoConfig struct {
 	// before the next network call. Zero fetches on every call.
 	RefreshInterval time.Duration
 
+	// MaxStale bounds how long the last known value keeps being served after
+	// refreshes start failing. Zero serves it indefinitely.
+	MaxStale time.Duration
+
 	BaseURL    string // defaults to the public Open-Meteo endpoint
 	HTTPClient *http.Client
 	Logger     *slog.Logger
@@ -35,6 +39,7 @@ type OpenMeteo struct {
 	latitude  float64
 	longitude float64
 	ttl       time.Duration
+	maxStale  time.Duration
 	baseURL   string
 	client    *http.Client
 	log       *slog.Logger
@@ -74,6 +79,7 @@ func NewOpenMeteo(cfg OpenMeteoConfig) *OpenMeteo {
 		latitude:  cfg.Latitude,
 		longitude: cfg.Longitude,
 		ttl:       cfg.RefreshInterval,
+		maxStale:  cfg.MaxStale,
 		baseURL:   baseURL,
 		client:    client,
 		log:       logger,
@@ -82,7 +88,8 @@ func NewOpenMeteo(cfg OpenMeteoConfig) *OpenMeteo {
 }
 
 // OutdoorTemperature serves the cached value while fresh; on a failed refresh
-// it keeps serving the last known good value and only errors when there is none.
+// it keeps serving the last known good value until it is older than MaxStale,
+// and errors when there is none left to serve.
 func (o *OpenMeteo) OutdoorTemperature(ctx context.Context) (float64, error) {
 	o.mu.Lock()
 	defer o.mu.Unlock()
@@ -93,13 +100,18 @@ func (o *OpenMeteo) OutdoorTemperature(ctx context.Context) (float64, error) {
 
 	temp, err := o.fetch(ctx)
 	if err != nil {
-		if o.hasLast {
+		age := o.now().Sub(o.fetchedAt)
+		if o.hasLast && (o.maxStale <= 0 || age < o.maxStale) {
 			o.log.Warn("open-meteo refresh failed, serving last known value",
 				"err", err,
 				"temperature", o.last,
+				"age", age,
 			)
 			return o.last, nil
 		}
+		if o.hasLast {
+			return 0, fmt.Errorf("last known value is %s old: %w", age.Round(time.Second), err)
+		}
 		return 0, err
 	}
 
@@ -109,6 +121,8 @@ func (o *OpenMeteo) OutdoorTemperature(ctx context.Context) (float64, error) {
 	return temp, nil
 }
 
+func (o *OpenMeteo) Name() string { return "open-meteo" }
+
 func (o *OpenMeteo) fetch(ctx context.Context) (float64, error) {
 	endpoint, err := o.requestURL()
 	if err != nil {
@@ -114,3 +114,34 @@ func TestOpenMeteoStaleFallbackOnError(t *testing.T) {
 		t.Fatalf("expected stale value 28.3, got %v", second)
 	}
 }
+
+func TestOpenMeteoStaleValueExpires(t *testing.T) {
+	var fail bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(okBody))
+	}))
+	defer srv.Close()
+
+	p := NewOpenMeteo(OpenMeteoConfig{BaseURL: srv.URL, MaxStale: time.Hour})
+	now := time.Date(2026, 6, 26, 14, 0, 0, 0, time.UTC)
+	p.now = func() time.Time { return now }
+
+	if _, err := p.OutdoorTemperature(context.Background()); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+
+	fail = true
+	now = now.Add(30 * time.Minute)
+	if got, err := p.OutdoorTemperature(context.Background()); err != nil || got != 28.3 {
+		t.Fatalf("within MaxStale: got %v, err %v; want stale 28.3", got, err)
+	}
+
+	now = now.Add(time.Hour)
+	if _, err := p.OutdoorTemperature(context.Background()); err == nil {
+		t.Fatal("expected error once the last known value is older than MaxStale")
+	}
+}
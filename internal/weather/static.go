@@ -1,5 +1,6 @@
 // Package weather provides thermostat.WeatherProvider implementations: a fixed
-// static value and a dynamic Open-Meteo client.
+// static value, a value read from a file, a dynamic Open-Meteo client, an MQTT
+// topic subscription, and a fallback chain combining them.
 package weather
 
 import (
@@ -9,8 +10,11 @@ import (
 )
 
 var (
-	_ thermostat.WeatherProvider = (*Static)(nil)
-	_ thermostat.WeatherProvider = (*OpenMeteo)(nil)
+	_ thermostat.NamedWeatherProvider = (*Static)(nil)
+	_ thermostat.NamedWeatherProvider = (*OpenMeteo)(nil)
+	_ thermostat.NamedWeatherProvider = (*File)(nil)
+	_ thermostat.NamedWeatherProvider = (*MQTT)(nil)
+	_ thermostat.NamedWeatherProvider = (*Fallback)(nil)
 )
 
 // Static always returns the same outdoor temperature.
@@ -25,3 +29,5 @@ func NewStatic(temperature float64) *Static {
 func (s *Static) OutdoorTemperature(context.Context) (float64, error) {
 	return s.temperature, nil
 }
+
+func (s *Static) Name() string { return "static" }